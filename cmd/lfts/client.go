@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// nodeURL builds the URL of an endpoint on the running node
+func nodeURL(path string) string {
+	return fmt.Sprintf("http://localhost:%s%s", rpcPort, path)
+}
+
+// callNode sends a request to the running node and decodes the JSON response into
+// out (if non-nil). Non-200 responses are returned as errors.
+func callNode(method, path string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, nodeURL(path), body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("node not reachable on port %s (is 'lfts start' running?): %v", rpcPort, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d - %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
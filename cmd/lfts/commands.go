@@ -8,12 +8,16 @@ import (
 	"lfts/internal/chain"
 	"lfts/internal/fdc"
 	"lfts/internal/ftso"
+	"lfts/internal/genesis"
+	"lfts/internal/network"
 	"lfts/internal/rpc"
+	"lfts/internal/state"
 	"lfts/internal/utils"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -22,6 +26,17 @@ import (
 
 var (
 	blockTime      int
+	blockRetention uint64
+	miningMode     string
+	freezeTime     bool
+	genesisFile    string
+	networkName    string
+	contractFlags  []string
+	votingEpoch    int64
+	fastSubmitters int
+	fastPrecision  float64
+	dataDir        string
+	stateHistory   uint64
 	rpcPort        string
 	autoUpdateFTSO bool
 	updateInterval int
@@ -113,15 +128,19 @@ var listFDCCmd = &cobra.Command{
 	Run:   runListFDC,
 }
 
-var stopCmd = &cobra.Command{
-	Use:   "stop",
-	Short: "Stop the chain",
-	Long:  "Stops the running chain loop",
-	Run:   runStop,
-}
-
 func init() {
 	startCmd.Flags().IntVarP(&blockTime, "block-time", "b", 1000, "Block generation interval in milliseconds")
+	startCmd.Flags().StringVar(&miningMode, "mining", "interval", "Mining mode: interval, auto (block per write) or manual")
+	startCmd.Flags().StringVar(&networkName, "network", network.DefaultProfile, "Network profile to imitate: local, flare, songbird, coston or coston2")
+	startCmd.Flags().StringArrayVar(&contractFlags, "contract", nil, "Registry entry Name=0xaddress; FtsoV2 and FdcVerification are served by the mocks at their address (repeatable)")
+	startCmd.Flags().Int64Var(&votingEpoch, "voting-epoch", 0, "FTSO voting epoch in seconds; values are published when their round ends (default: the network profile's, 0 publishes immediately)")
+	startCmd.Flags().IntVar(&fastSubmitters, "fast-updates", 0, "FTSO fast-update submitters per block; reads return block-latency values (0 disables)")
+	startCmd.Flags().Float64Var(&fastPrecision, "fast-update-precision", ftso.DefaultFastUpdatePrecision, "Percentage one fast-update delta moves a value by")
+	startCmd.Flags().StringVar(&genesisFile, "genesis", "", "Genesis file declaring chain ID, start time, initial feeds and auto-update settings")
+	startCmd.Flags().BoolVar(&freezeTime, "freeze-time", false, "Start with the chain clock frozen (advance it with 'lfts time increase')")
+	startCmd.Flags().StringVar(&dataDir, "data-dir", "", "Directory for persistent chain state (default: in-memory, wiped on exit)")
+	startCmd.Flags().Uint64Var(&stateHistory, "state-history", state.DefaultHistoryDepth, "Number of recent blocks whose state can be read or rolled back to (0 keeps all)")
+	startCmd.Flags().Uint64Var(&blockRetention, "block-retention", 0, "Number of recent blocks to keep (0 keeps all)")
 	startCmd.Flags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
 	startCmd.Flags().BoolVar(&autoUpdateFTSO, "auto-update-ftso", false, "Enable automatic FTSO price updates")
 	startCmd.Flags().IntVar(&updateInterval, "update-interval", 1800, "Auto-update interval in milliseconds (default: 1800ms)")
@@ -137,7 +156,6 @@ func init() {
 	rootCmd.AddCommand(historyCmd)
 	rootCmd.AddCommand(queryCmd)
 	rootCmd.AddCommand(listCmd)
-	rootCmd.AddCommand(stopCmd)
 
 	injectCmd.AddCommand(injectFTSOCmd)
 	injectCmd.AddCommand(injectFDCCmd)
@@ -146,18 +164,191 @@ func init() {
 	listCmd.AddCommand(listFDCCmd)
 }
 
+// applyGenesisSettings uses the genesis node settings for every start flag that
+// was not given explicitly on the command line
+func applyGenesisSettings(cmd *cobra.Command, g *genesis.Genesis) {
+	flags := cmd.Flags()
+	if g.BlockTime > 0 && !flags.Changed("block-time") {
+		blockTime = g.BlockTime
+	}
+	if g.VotingEpoch != nil && !flags.Changed("voting-epoch") {
+		votingEpoch = *g.VotingEpoch
+	}
+	if fu := g.FastUpdates; fu != nil {
+		if !flags.Changed("fast-updates") {
+			fastSubmitters = fu.Submitters
+		}
+		if fu.Precision > 0 && !flags.Changed("fast-update-precision") {
+			fastPrecision = fu.Precision
+		}
+	}
+
+	au := g.AutoUpdate
+	if au == nil {
+		return
+	}
+	if !flags.Changed("auto-update-ftso") {
+		autoUpdateFTSO = au.Enabled
+	}
+	if au.Interval > 0 && !flags.Changed("update-interval") {
+		updateInterval = au.Interval
+	}
+	if au.Pattern != "" && !flags.Changed("update-pattern") {
+		updatePattern = au.Pattern
+	}
+	if len(au.Assets) > 0 && !flags.Changed("update-assets") {
+		updateAssets = au.Assets
+	}
+	if au.Volatility > 0 && !flags.Changed("volatility") {
+		volatility = au.Volatility
+	}
+}
+
+// setContracts applies the genesis and --contract registry entries to profile
+func setContracts(profile *network.Profile, g *genesis.Genesis) error {
+	if g != nil {
+		for name, address := range g.Contracts {
+			if err := profile.SetContract(name, address); err != nil {
+				return fmt.Errorf("genesis: %v", err)
+			}
+		}
+	}
+	for _, entry := range contractFlags {
+		name, address, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid --contract %q (expected Name=0xaddress)", entry)
+		}
+		if err := profile.SetContract(name, address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func runStart(cmd *cobra.Command, args []string) {
+	profile, err := network.Lookup(networkName)
+	if err != nil {
+		utils.Error("%v", err)
+		os.Exit(1)
+	}
+	if !cmd.Flags().Changed("block-time") {
+		blockTime = int(profile.BlockTime.Milliseconds())
+	}
+	if !cmd.Flags().Changed("voting-epoch") {
+		votingEpoch = int64(profile.VotingEpoch.Seconds())
+	}
+
+	var genesisConfig *genesis.Genesis
+	if genesisFile != "" {
+		g, err := genesis.Load(genesisFile)
+		if err != nil {
+			utils.Error("%v", err)
+			os.Exit(1)
+		}
+		genesisConfig = g
+		applyGenesisSettings(cmd, g)
+	}
+
+	// Registry entries of the genesis, then of the command line, override the profile's
+	if err := setContracts(&profile, genesisConfig); err != nil {
+		utils.Error("%v", err)
+		os.Exit(1)
+	}
+	network.SetActive(profile)
+
+	mode, err := chain.ParseMiningMode(miningMode)
+	if err != nil {
+		utils.Error("%v", err)
+		os.Exit(1)
+	}
+	if err := ftso.ConfigureRounds(profile.FirstRoundStart, time.Duration(votingEpoch)*time.Second); err != nil {
+		utils.Error("%v", err)
+		os.Exit(1)
+	}
+	if err := ftso.ConfigureFastUpdates(ftso.FastUpdateConfig{Submitters: fastSubmitters, Precision: fastPrecision}); err != nil {
+		utils.Error("%v", err)
+		os.Exit(1)
+	}
+
 	utils.Info("Starting Local Flare Testnet Sandbox...")
+	utils.Info("Network: %s (chain ID %d)", profile.Name, profile.ChainID)
 	utils.Info("Block time: %d ms", blockTime)
+	utils.Info("Mining mode: %s", mode)
+	if votingEpoch > 0 {
+		utils.Info("Voting epoch: %d s", votingEpoch)
+	} else {
+		utils.Info("Voting epoch: disabled (FTSO values are published immediately)")
+	}
+	if fastSubmitters > 0 {
+		utils.Info("Fast updates: %d submitter(s) per block, %g%% per delta", fastSubmitters, fastPrecision)
+	}
 	utils.Info("RPC port: %s", rpcPort)
 
 	// Create and set chain instance
 	chainInstance := chain.NewChain(blockTime)
+	chainInstance.SetChainID(profile.ChainID)
+	chainInstance.SetBlockRetention(blockRetention)
+	state.GlobalState.SetHistoryDepth(stateHistory)
+	if dataDir != "" {
+		if err := openDataDir(chainInstance, dataDir); err != nil {
+			utils.Error("%v", err)
+			os.Exit(1)
+		}
+	}
+	resumed := chainInstance.GetHeight() > 0
+	chainInstance.SetMiningMode(mode)
+	chainInstance.AddSealHook(ftso.FinalizeRounds)
+	chainInstance.AddSealHook(ftso.ApplyFastUpdates)
+	chainInstance.AddDueHook(ftso.RoundsDue)
+	if freezeTime {
+		chainInstance.Clock().Freeze()
+	}
 	chain.SetInstance(chainInstance)
 
+	if genesisConfig != nil && resumed {
+		// The persisted chain already contains the genesis state
+		if err := genesisConfig.ApplySettings(chainInstance); err != nil {
+			utils.Error("Failed to apply genesis: %v", err)
+			os.Exit(1)
+		}
+	} else if genesisConfig != nil {
+		if err := genesisConfig.Apply(chainInstance); err != nil {
+			utils.Error("Failed to apply genesis: %v", err)
+			os.Exit(1)
+		}
+		utils.Info("Genesis loaded from %s (chain ID %d)", genesisFile, chainInstance.GetChainID())
+	}
+
+	// Settings changed at runtime are stored on chain; explicit flags override them
+	if resumed {
+		if cmd.Flags().Changed("voting-epoch") {
+			if err := ftso.SetVotingEpoch(time.Duration(votingEpoch) * time.Second); err != nil {
+				utils.Error("%v", err)
+				os.Exit(1)
+			}
+		} else if epoch := ftso.VotingEpoch(); epoch != time.Duration(votingEpoch)*time.Second {
+			utils.Info("Voting epoch: %d s (changed on chain)", int64(epoch.Seconds()))
+		}
+
+		config := ftso.FastUpdates()
+		if cmd.Flags().Changed("fast-updates") {
+			config.Submitters = fastSubmitters
+		}
+		if cmd.Flags().Changed("fast-update-precision") {
+			config.Precision = fastPrecision
+		}
+		if cmd.Flags().Changed("fast-updates") || cmd.Flags().Changed("fast-update-precision") {
+			if err := ftso.SetFastUpdates(config); err != nil {
+				utils.Error("%v", err)
+				os.Exit(1)
+			}
+		} else if config.Submitters != fastSubmitters || config.Precision != fastPrecision {
+			utils.Info("Fast updates: %d submitter(s) per block, %g%% per delta (changed on chain)", config.Submitters, config.Precision)
+		}
+	}
+
 	// Start chain
 	chainInstance.Start()
-	chain.StartLoop(chainInstance)
 
 	// Start RPC server
 	rpcServer := rpc.NewServer(rpcPort)
@@ -182,7 +373,7 @@ func runStart(cmd *cobra.Command, args []string) {
 		for _, asset := range assets {
 			price, err := ftso.GetPrice(asset)
 			if err == nil && price != nil {
-				basePrices[asset] = price.Price
+				basePrices[asset] = price.Float64()
 			}
 		}
 
@@ -207,9 +398,64 @@ func runStart(cmd *cobra.Command, args []string) {
 	utils.Info("Shutting down...")
 	close(stopChan) // Stop auto-update
 	chainInstance.Stop()
+	if dataDir != "" {
+		chainInstance.Exclusive(func() {
+			if err := chainInstance.CloseBlockLog(); err != nil {
+				utils.Error("Failed to close block log: %v", err)
+			}
+			if err := state.GlobalState.Close(); err != nil {
+				utils.Error("Failed to close state log: %v", err)
+			}
+		})
+	}
 	utils.Info("Chain stopped")
 }
 
+// openDataDir switches the chain and global state to the persistent logs in
+// dir and resumes from them. State written after the last persisted block
+// (never sealed before a crash or shutdown) is discarded.
+func openDataDir(chainInstance *chain.Chain, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	store, err := state.OpenLogStorage(filepath.Join(dir, "state.log"), stateHistory)
+	if err != nil {
+		return err
+	}
+	state.GlobalState = store
+
+	if err := chainInstance.OpenBlockLog(filepath.Join(dir, "blocks.log")); err != nil {
+		return err
+	}
+	if err := state.GlobalState.Rollback(chainInstance.GetHeight()); err != nil {
+		return fmt.Errorf("state log does not match the persisted blocks: %v", err)
+	}
+	if err := ftso.LoadRevealKey(filepath.Join(dir, "reveal.key")); err != nil {
+		return err
+	}
+
+	// Earlier versions stored each history as one value; the next block
+	// includes the move into the history rings
+	if n, err := ftso.MigrateHistory(); err != nil {
+		return fmt.Errorf("failed to migrate price history: %v", err)
+	} else if n > 0 {
+		utils.Info("Migrated the price history of %d feeds", n)
+	}
+	if n, err := fdc.MigrateHistory(); err != nil {
+		return fmt.Errorf("failed to migrate FDC history: %v", err)
+	} else if n > 0 {
+		utils.Info("Migrated the history of %d FDC feeds", n)
+	}
+
+	if height := chainInstance.GetHeight(); height > 0 {
+		utils.Info("Resuming from %s at block #%d", dir, height)
+	} else {
+		utils.Info("Persisting chain data to %s", dir)
+	}
+	return nil
+}
+
 func runInjectFTSO(cmd *cobra.Command, args []string) {
 	asset := args[0]
 	priceStr := args[1]
@@ -227,18 +473,12 @@ func runInjectFTSO(cmd *cobra.Command, args []string) {
 	resp, err := client.Do(req)
 	if err != nil {
 		// Chain might not be running, fall back to local injection
-		price, parseErr := strconv.ParseFloat(priceStr, 64)
-		if parseErr != nil {
-			utils.Error("Invalid price: %v", parseErr)
-			os.Exit(1)
-		}
-
-		err = ftso.SetPrice(asset, price)
+		price, err := ftso.SetPriceDecimal(asset, priceStr)
 		if err != nil {
 			utils.Error("Failed to inject price (chain not running?): %v", err)
 			os.Exit(1)
 		}
-		utils.Info("Injected FTSO price locally: %s = %.2f (Note: Chain must be running for RPC access)", asset, price)
+		utils.Info("Injected FTSO price locally: %s = %s (Note: Chain must be running for RPC access)", asset, price.Price)
 		return
 	}
 	defer resp.Body.Close()
@@ -250,6 +490,15 @@ func runInjectFTSO(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	var result struct {
+		Round *ftso.VotingRound `json:"round"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result.Round != nil {
+		utils.Info("Submitted FTSO price: %s = %s (pending until voting round %d ends at %s)",
+			asset, priceStr, result.Round.ID, utils.FormatTimestamp(result.Round.EndTime))
+		return
+	}
 	utils.Info("Injected FTSO price: %s = %s", asset, priceStr)
 }
 
@@ -284,7 +533,7 @@ func runStatus(cmd *cobra.Command, args []string) {
 			fmt.Println("No FTSO prices available")
 		} else {
 			for asset, price := range prices {
-				fmt.Printf("%s: %.2f (timestamp: %d)\n", asset, price.Price, price.Timestamp)
+				fmt.Printf("%s: %s (timestamp: %d)\n", asset, price.Price, price.Timestamp)
 			}
 		}
 		return
@@ -314,7 +563,7 @@ func runStatus(cmd *cobra.Command, args []string) {
 			if prices, ok := pricesData["prices"].(map[string]interface{}); ok {
 				for asset, priceData := range prices {
 					if priceMap, ok := priceData.(map[string]interface{}); ok {
-						fmt.Printf("%s: %.2f (timestamp: %.0f)\n",
+						fmt.Printf("%s: %v (timestamp: %.0f)\n",
 							asset,
 							priceMap["price"],
 							priceMap["timestamp"])
@@ -363,7 +612,7 @@ func runHistoryFTSO(cmd *cobra.Command, args []string) {
 
 		for i := len(history.History) - 1; i >= start; i-- {
 			point := history.History[i]
-			fmt.Printf("[%d] Price: %.2f, Timestamp: %d, Block: %d\n",
+			fmt.Printf("[%d] Price: %s, Timestamp: %d, Block: %d\n",
 				i+1, point.Price, point.Timestamp, point.BlockNum)
 		}
 		return
@@ -389,7 +638,7 @@ func runHistoryFTSO(cmd *cobra.Command, args []string) {
 
 		for i := len(historyPoints) - 1; i >= 0; i-- {
 		point := historyPoints[i]
-		fmt.Printf("Price: %.2f, Timestamp: %d, Block: %d\n",
+		fmt.Printf("Price: %s, Timestamp: %d, Block: %d\n",
 			point.Price, point.Timestamp, point.BlockNum)
 	}
 }
@@ -420,7 +669,7 @@ func runInjectFDC(cmd *cobra.Command, args []string) {
 	resp, err := client.Do(req)
 	if err != nil {
 		// Chain might not be running, fall back to local injection
-		err = fdc.SetFeed(feedName, data)
+		_, err = fdc.SetFeed(feedName, data)
 		if err != nil {
 			utils.Error("Failed to inject feed (chain not running?): %v", err)
 			os.Exit(1)
@@ -486,6 +735,9 @@ func runQueryFDC(cmd *cobra.Command, args []string) {
 	fmt.Printf("Data: %s\n", string(jsonData))
 	fmt.Printf("Timestamp: %d\n", feed.Timestamp)
 	fmt.Printf("Block: %d\n", feed.BlockNum)
+	if feed.ExpiresAt != 0 {
+		fmt.Printf("Status: %s (stale at %d, expires at %d)\n", feed.Status, feed.StaleAt, feed.ExpiresAt)
+	}
 }
 
 func runListFDC(cmd *cobra.Command, args []string) {
@@ -544,22 +796,6 @@ func runListFDC(cmd *cobra.Command, args []string) {
 	}
 }
 
-func runStop(cmd *cobra.Command, args []string) {
-	chainInstance := chain.GetInstance()
-	if chainInstance == nil {
-		fmt.Println("Chain is not running")
-		return
-	}
-
-	if !chainInstance.IsRunning() {
-		fmt.Println("Chain is not running")
-		return
-	}
-
-	chainInstance.Stop()
-	utils.Info("Chain stopped")
-}
-
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
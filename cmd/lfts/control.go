@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/utils"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var stopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the chain",
+	Long:  "Stops block production on the running node (same as 'lfts pause'). The RPC server keeps serving; 'lfts resume' continues from the same height.",
+	Args:  cobra.NoArgs,
+	Run:   runControl,
+}
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Pause block production",
+	Long:  "Pauses block production on the running node. Injected data stays pending until blocks are produced again or mined with 'lfts mine'.",
+	Args:  cobra.NoArgs,
+	Run:   runControl,
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume block production",
+	Args:  cobra.NoArgs,
+	Run:   runControl,
+}
+
+var restartCmd = &cobra.Command{
+	Use:   "restart",
+	Short: "Restart block production",
+	Long:  "Stops the chain loop of the running node, if any, and spawns a new one. Chain state and height are kept.",
+	Args:  cobra.NoArgs,
+	Run:   runControl,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{stopCmd, pauseCmd, resumeCmd, restartCmd} {
+		cmd.Flags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+		rootCmd.AddCommand(cmd)
+	}
+}
+
+func runControl(cmd *cobra.Command, args []string) {
+	action := cmd.Name()
+	if action == "stop" {
+		action = "pause"
+	}
+
+	var result struct {
+		Running bool   `json:"running"`
+		Changed bool   `json:"changed"`
+		Height  uint64 `json:"height"`
+	}
+	if err := callNode("POST", "/chain/"+action, nil, &result); err != nil {
+		utils.Error("Failed to %s chain: %v", cmd.Name(), err)
+		os.Exit(1)
+	}
+
+	switch {
+	case !result.Changed && result.Running:
+		fmt.Printf("Chain is already running (height %d)\n", result.Height)
+	case !result.Changed:
+		fmt.Printf("Chain is already paused (height %d)\n", result.Height)
+	case result.Running:
+		utils.Info("Block production running (height %d)", result.Height)
+	default:
+		utils.Info("Block production paused (height %d)", result.Height)
+	}
+}
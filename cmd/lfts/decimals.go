@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/utils"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var decimalsCmd = &cobra.Command{
+	Use:   "decimals <asset> [decimals]",
+	Short: "Show or set the decimals of an FTSO feed",
+	Long: `Shows or sets how many decimals new values of an FTSO feed are stored with
+(8 by default). Values already stored keep their decimals. Example: lfts decimals FLR 7`,
+	Args: cobra.RangeArgs(1, 2),
+	Run:  runDecimals,
+}
+
+func init() {
+	decimalsCmd.Flags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+
+	rootCmd.AddCommand(decimalsCmd)
+}
+
+func runDecimals(cmd *cobra.Command, args []string) {
+	query := url.Values{"asset": {args[0]}}
+	method := "GET"
+	if len(args) == 2 {
+		if _, err := strconv.ParseInt(args[1], 10, 8); err != nil {
+			utils.Error("Invalid decimals %q (expected -128 to 127)", args[1])
+			os.Exit(1)
+		}
+		method = "POST"
+		query.Set("decimals", args[1])
+	}
+
+	var result struct {
+		Asset    string `json:"asset"`
+		Decimals int8   `json:"decimals"`
+	}
+	if err := callNode(method, "/ftso/decimals?"+query.Encode(), nil, &result); err != nil {
+		utils.Error("Decimals request failed: %v", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %d decimals\n", result.Asset, result.Decimals)
+}
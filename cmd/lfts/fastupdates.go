@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/ftso"
+	"lfts/internal/utils"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	fastUpdateSubmitters int
+	fastUpdatePrecision  float64
+)
+
+var fastUpdatesCmd = &cobra.Command{
+	Use:   "fast-updates",
+	Short: "Show or configure FTSO block-latency fast updates",
+	Long: `Shows or changes the FTSO fast-update settings. Every block, each submitter
+moves every feed's block-latency value by one delta towards its latest published
+anchor value; reads return that value. Example: lfts fast-updates --submitters 3`,
+	Args: cobra.NoArgs,
+	Run:  runFastUpdates,
+}
+
+var anchorCmd = &cobra.Command{
+	Use:   "anchor <asset>",
+	Short: "Compare the anchor value of an FTSO feed with its block-latency value",
+	Long: `Shows the anchor value published by the last finalized voting round next to
+the block-latency value that reads return. Example: lfts anchor BTC`,
+	Args: cobra.ExactArgs(1),
+	Run:  runAnchor,
+}
+
+func init() {
+	fastUpdatesCmd.Flags().IntVar(&fastUpdateSubmitters, "submitters", 0, "Submitters per block (0 disables fast updates)")
+	fastUpdatesCmd.Flags().Float64Var(&fastUpdatePrecision, "precision", 0, "Percentage one delta moves a value by")
+	fastUpdatesCmd.Flags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+	anchorCmd.Flags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+
+	rootCmd.AddCommand(fastUpdatesCmd, anchorCmd)
+}
+
+func runFastUpdates(cmd *cobra.Command, args []string) {
+	query := url.Values{}
+	if cmd.Flags().Changed("submitters") {
+		query.Set("submitters", strconv.Itoa(fastUpdateSubmitters))
+	}
+	if cmd.Flags().Changed("precision") {
+		query.Set("precision", strconv.FormatFloat(fastUpdatePrecision, 'f', -1, 64))
+	}
+	method, path := "GET", "/ftso/fast-updates"
+	if len(query) > 0 {
+		method, path = "POST", path+"?"+query.Encode()
+	}
+
+	var config ftso.FastUpdateConfig
+	if err := callNode(method, path, nil, &config); err != nil {
+		utils.Error("Fast-updates request failed: %v", err)
+		os.Exit(1)
+	}
+	if config.Submitters == 0 {
+		fmt.Println("Fast updates disabled: reads return anchor values")
+		return
+	}
+	fmt.Printf("Fast updates: %d submitter(s) per block, %g%% per delta\n", config.Submitters, config.Precision)
+}
+
+func runAnchor(cmd *cobra.Command, args []string) {
+	query := url.Values{"asset": {args[0]}}.Encode()
+
+	var anchor, current ftso.FTSOPrice
+	if err := callNode("GET", "/ftso/anchor?"+query, nil, &anchor); err != nil {
+		utils.Error("Anchor request failed: %v", err)
+		os.Exit(1)
+	}
+	if err := callNode("GET", "/ftso/price?"+query, nil, &current); err != nil {
+		utils.Error("Price request failed: %v", err)
+		os.Exit(1)
+	}
+
+	round := ""
+	if anchor.VotingRoundID != 0 {
+		round = fmt.Sprintf("voting round %d, ", anchor.VotingRoundID)
+	}
+	fmt.Printf("%s anchor:        %s (%sblock #%d)\n", anchor.Asset, anchor.Price, round, anchor.BlockNum)
+	fmt.Printf("%s block-latency: %s (block #%d)\n", current.Asset, current.Price, current.BlockNum)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/feedid"
+	"lfts/internal/ftso"
+	"lfts/internal/utils"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var feedsCmd = &cobra.Command{
+	Use:   "feeds",
+	Short: "List FTSOv2 feed IDs and the assets they map to",
+	Long: `Lists the feed registry of the running node: every FTSOv2 feed of the network
+profile and every registered feed, with its bytes21 feed ID. Any command that takes an
+asset also accepts a feed name (BTC/USD) or a feed ID.`,
+	Args: cobra.NoArgs,
+	Run:  runFeeds,
+}
+
+var feedsRegisterCmd = &cobra.Command{
+	Use:   "register <category> <feed_name> <asset>",
+	Short: "Map a feed to the asset symbol its values are stored under",
+	Long: `Maps an FTSOv2 feed to an asset symbol. Only needed for feeds that are not
+USD-quoted crypto feeds, e.g. lfts feeds register forex EUR/USD EUR`,
+	Args: cobra.ExactArgs(3),
+	Run:  runFeedsRegister,
+}
+
+var feedsIDCmd = &cobra.Command{
+	Use:   "id <category> <feed_name>",
+	Short: "Print the bytes21 feed ID of a feed",
+	Args:  cobra.ExactArgs(2),
+	Run:   runFeedsID,
+}
+
+func init() {
+	feedsCmd.PersistentFlags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+
+	rootCmd.AddCommand(feedsCmd)
+	feedsCmd.AddCommand(feedsRegisterCmd)
+	feedsCmd.AddCommand(feedsIDCmd)
+}
+
+func runFeeds(cmd *cobra.Command, args []string) {
+	var result struct {
+		Feeds []ftso.Feed `json:"feeds"`
+	}
+	if err := callNode("GET", "/ftso/feeds", nil, &result); err != nil {
+		utils.Error("Feeds request failed: %v", err)
+		os.Exit(1)
+	}
+
+	for _, feed := range result.Feeds {
+		fmt.Printf("%s  %-9s %-12s %s\n", feed.ID, feed.Category, feed.Name, feed.Asset)
+	}
+}
+
+func runFeedsRegister(cmd *cobra.Command, args []string) {
+	query := url.Values{"category": {args[0]}, "name": {args[1]}, "asset": {args[2]}}
+	var feed ftso.Feed
+	if err := callNode("POST", "/ftso/feeds?"+query.Encode(), nil, &feed); err != nil {
+		utils.Error("Register request failed: %v", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Registered %s %s (%s) as %s\n", feed.Category, feed.Name, feed.ID, feed.Asset)
+}
+
+func runFeedsID(cmd *cobra.Command, args []string) {
+	category, err := feedid.ParseCategory(args[0])
+	if err != nil {
+		utils.Error("%v", err)
+		os.Exit(1)
+	}
+	id, err := feedid.New(category, args[1])
+	if err != nil {
+		utils.Error("%v", err)
+		os.Exit(1)
+	}
+	fmt.Println(id)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/utils"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	mineBlocks     int
+	miningInterval int
+)
+
+var mineCmd = &cobra.Command{
+	Use:   "mine",
+	Short: "Mine blocks on demand",
+	Long:  "Produces blocks immediately on the running node, regardless of mining mode",
+	Args:  cobra.NoArgs,
+	Run:   runMine,
+}
+
+var miningCmd = &cobra.Command{
+	Use:   "mining [interval|auto|manual]",
+	Short: "Show or change the mining mode",
+	Long:  "Shows the mining mode of the running node, or switches mode and block time at runtime. Example: lfts mining interval --block-time 500",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   runMining,
+}
+
+func init() {
+	mineCmd.Flags().IntVarP(&mineBlocks, "blocks", "n", 1, "Number of blocks to mine")
+	mineCmd.Flags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+
+	miningCmd.Flags().IntVarP(&miningInterval, "block-time", "b", 0, "New block generation interval in milliseconds")
+	miningCmd.Flags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+
+	rootCmd.AddCommand(mineCmd)
+	rootCmd.AddCommand(miningCmd)
+}
+
+func runMine(cmd *cobra.Command, args []string) {
+	var blocks []chain.Block
+	path := fmt.Sprintf("/chain/mine?blocks=%d", mineBlocks)
+	if err := callNode("POST", path, nil, &blocks); err != nil {
+		utils.Error("Failed to mine: %v", err)
+		os.Exit(1)
+	}
+
+	for _, block := range blocks {
+		fmt.Printf("Mined block #%d %s (%d updates)\n", block.Number, block.Hash, len(block.Data.StateUpdates))
+	}
+}
+
+func runMining(cmd *cobra.Command, args []string) {
+	method := "GET"
+	query := url.Values{}
+	if len(args) == 1 {
+		if _, err := chain.ParseMiningMode(args[0]); err != nil {
+			utils.Error("%v", err)
+			os.Exit(1)
+		}
+		query.Set("mode", args[0])
+		method = "POST"
+	}
+	if miningInterval > 0 {
+		query.Set("blockTime", strconv.Itoa(miningInterval))
+		method = "POST"
+	}
+
+	var config map[string]interface{}
+	if err := callNode(method, "/chain/mining?"+query.Encode(), nil, &config); err != nil {
+		utils.Error("Failed to update mining config: %v", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Mining mode: %v\n", config["mode"])
+	fmt.Printf("Block time: %.0f ms\n", config["blockTime"])
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/network"
+	"lfts/internal/utils"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+var networkCmd = &cobra.Command{
+	Use:   "network",
+	Short: "Show the network profile of the running node",
+	Long:  "Shows the network profile the running node imitates: chain ID, voting epoch, system contracts and feed IDs.",
+	Args:  cobra.NoArgs,
+	Run:   runNetworkShow,
+}
+
+var networkListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the built-in network profiles",
+	Args:  cobra.NoArgs,
+	Run:   runNetworkList,
+}
+
+func init() {
+	networkCmd.PersistentFlags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+
+	rootCmd.AddCommand(networkCmd)
+	networkCmd.AddCommand(networkListCmd)
+}
+
+func runNetworkShow(cmd *cobra.Command, args []string) {
+	var profile struct {
+		Name         string            `json:"name"`
+		ChainID      uint64            `json:"chainId"`
+		NativeSymbol string            `json:"nativeSymbol"`
+		VotingEpoch  int64             `json:"votingEpoch"`
+		BlockTime    int64             `json:"blockTime"`
+		Registry     string            `json:"registry"`
+		Contracts    map[string]string `json:"contracts"`
+		Feeds        []struct {
+			Name string `json:"name"`
+			ID   string `json:"id"`
+		} `json:"feeds"`
+	}
+	if err := callNode("GET", "/network", nil, &profile); err != nil {
+		utils.Error("Network request failed: %v", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Network: %s (chain ID %d, native %s)\n", profile.Name, profile.ChainID, profile.NativeSymbol)
+	fmt.Printf("Voting epoch: %d s, block time: %d ms\n", profile.VotingEpoch, profile.BlockTime)
+	fmt.Printf("FlareContractRegistry: %s\n", profile.Registry)
+	for _, name := range sortedNames(profile.Contracts) {
+		fmt.Printf("  %s: %s\n", name, profile.Contracts[name])
+	}
+	fmt.Println("Feeds:")
+	for _, feed := range profile.Feeds {
+		fmt.Printf("  %-10s %s\n", feed.Name, feed.ID)
+	}
+}
+
+func runNetworkList(cmd *cobra.Command, args []string) {
+	for _, name := range network.Names() {
+		profile, _ := network.Lookup(name)
+		fmt.Printf("%-9s chain ID %-6d %-6s voting epoch %s, block time %s, %d feeds\n",
+			profile.Name, profile.ChainID, profile.NativeSymbol, profile.VotingEpoch, profile.BlockTime, len(profile.Feeds))
+	}
+}
+
+// sortedNames returns the keys of m in ascending order
+func sortedNames(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/ftso"
+	"lfts/internal/utils"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	providerWeight   uint64
+	providerStrategy string
+	providerBias     float64
+	providerLag      uint64
+)
+
+var providersCmd = &cobra.Command{
+	Use:   "providers",
+	Short: "List the simulated FTSO data providers",
+	Long: `Lists the simulated FTSO data providers. When voting rounds are enabled,
+every provider commits a value for each feed submitted in a round and reveals it
+when the round ends; the weighted median of the reveals is published.`,
+	Args: cobra.NoArgs,
+	Run:  runProviders,
+}
+
+var providersSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Add or replace a simulated data provider",
+	Long: `Adds a simulated data provider or replaces the one with the same name.
+Strategies: honest, biased (--bias percent), lagging (--lag rounds) and offline.
+Example: lfts providers set whale --weight 3 --strategy biased --bias 2.5`,
+	Args: cobra.ExactArgs(1),
+	Run:  runProvidersSet,
+}
+
+var providersRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a simulated data provider",
+	Args:  cobra.ExactArgs(1),
+	Run:   runProvidersRemove,
+}
+
+var submissionsCmd = &cobra.Command{
+	Use:   "submissions <asset> [round]",
+	Short: "Show the provider submissions for an FTSO feed",
+	Long: `Shows the provider submissions, weighted median and reward bands of a feed
+in a voting round (the latest finalized round by default). Values of a running
+round stay hidden until the reveal. Example: lfts submissions BTC 19000`,
+	Args: cobra.RangeArgs(1, 2),
+	Run:  runSubmissions,
+}
+
+func init() {
+	providersSetCmd.Flags().Uint64Var(&providerWeight, "weight", 1, "Voting weight")
+	providersSetCmd.Flags().StringVar(&providerStrategy, "strategy", "honest", "Strategy (honest, biased, lagging or offline)")
+	providersSetCmd.Flags().Float64Var(&providerBias, "bias", 0, "Percent added to the value (biased strategy)")
+	providersSetCmd.Flags().Uint64Var(&providerLag, "lag", 1, "Rounds behind (lagging strategy)")
+
+	for _, cmd := range []*cobra.Command{providersCmd, providersSetCmd, providersRemoveCmd, submissionsCmd} {
+		cmd.Flags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+	}
+
+	providersCmd.AddCommand(providersSetCmd, providersRemoveCmd)
+	rootCmd.AddCommand(providersCmd, submissionsCmd)
+}
+
+// providersResponse is the response of /ftso/providers
+type providersResponse struct {
+	Providers []ftso.Provider `json:"providers"`
+}
+
+func runProviders(cmd *cobra.Command, args []string) {
+	var result providersResponse
+	if err := callNode("GET", "/ftso/providers", nil, &result); err != nil {
+		utils.Error("Providers request failed: %v", err)
+		os.Exit(1)
+	}
+	printProviders(result.Providers)
+}
+
+func runProvidersSet(cmd *cobra.Command, args []string) {
+	strategy, err := ftso.ParseStrategy(providerStrategy)
+	if err != nil {
+		utils.Error("%v", err)
+		os.Exit(1)
+	}
+
+	query := url.Values{
+		"name":     {args[0]},
+		"weight":   {strconv.FormatUint(providerWeight, 10)},
+		"strategy": {string(strategy)},
+		"bias":     {strconv.FormatFloat(providerBias, 'f', -1, 64)},
+		"lag":      {strconv.FormatUint(providerLag, 10)},
+	}
+	var result providersResponse
+	if err := callNode("POST", "/ftso/providers?"+query.Encode(), nil, &result); err != nil {
+		utils.Error("Failed to set provider: %v", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Provider %s set\n", args[0])
+	printProviders(result.Providers)
+}
+
+func runProvidersRemove(cmd *cobra.Command, args []string) {
+	query := url.Values{"name": {args[0]}}
+	var result providersResponse
+	if err := callNode("DELETE", "/ftso/providers?"+query.Encode(), nil, &result); err != nil {
+		utils.Error("Failed to remove provider: %v", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Provider %s removed\n", args[0])
+	printProviders(result.Providers)
+}
+
+// printProviders prints one line per provider
+func printProviders(providers []ftso.Provider) {
+	if len(providers) == 0 {
+		fmt.Println("No data providers: submitted values are published as is")
+		return
+	}
+	for _, p := range providers {
+		detail := ""
+		switch p.Strategy {
+		case ftso.StrategyBiased:
+			detail = fmt.Sprintf(" (%+g%%)", p.Bias)
+		case ftso.StrategyLagging:
+			lag := p.Lag
+			if lag == 0 {
+				lag = 1
+			}
+			detail = fmt.Sprintf(" (%d round(s) behind)", lag)
+		}
+		fmt.Printf("  %-16s weight %-6d %s%s\n", p.Name, p.Weight, p.Strategy, detail)
+	}
+}
+
+func runSubmissions(cmd *cobra.Command, args []string) {
+	query := url.Values{"asset": {args[0]}}
+	if len(args) == 2 {
+		if _, err := strconv.ParseUint(args[1], 10, 64); err != nil {
+			utils.Error("Invalid round %q", args[1])
+			os.Exit(1)
+		}
+		query.Set("round", args[1])
+	}
+
+	var result ftso.RoundResult
+	if err := callNode("GET", "/ftso/submissions?"+query.Encode(), nil, &result); err != nil {
+		utils.Error("Submissions request failed: %v", err)
+		os.Exit(1)
+	}
+
+	status := "finalized"
+	if !result.Finalized {
+		status = "committed, values hidden until the round ends"
+	}
+	fmt.Printf("%s voting round %d (%s)\n", result.Asset, result.VotingRoundID, status)
+	fmt.Printf("Submitted value: %s\n", ftso.FormatValue(result.Reference, result.Decimals))
+	if result.Finalized {
+		if result.Value == nil {
+			fmt.Println("Median:          none (no provider revealed a value)")
+		} else {
+			fmt.Printf("Median:          %s (weight %d)\n", result.Price, result.TotalWeight)
+			fmt.Printf("Primary band:    %s to %s\n", ftso.FormatValue(result.PrimaryBand.Low, result.Decimals), ftso.FormatValue(result.PrimaryBand.High, result.Decimals))
+			fmt.Printf("Secondary band:  %s to %s\n", ftso.FormatValue(result.SecondaryBand.Low, result.Decimals), ftso.FormatValue(result.SecondaryBand.High, result.Decimals))
+		}
+	}
+
+	fmt.Println("Submissions:")
+	for _, s := range result.Submissions {
+		switch {
+		case s.Commit == "":
+			fmt.Printf("  %-16s weight %-6d no submission\n", s.Provider, s.Weight)
+		case !result.Finalized:
+			fmt.Printf("  %-16s weight %-6d commit %s\n", s.Provider, s.Weight, s.Commit)
+		case !s.Revealed:
+			fmt.Printf("  %-16s weight %-6d commit %s (no matching reveal)\n", s.Provider, s.Weight, s.Commit)
+		default:
+			bands := ""
+			if s.PrimaryBand {
+				bands += " [primary]"
+			}
+			if s.SecondaryBand {
+				bands += " [secondary]"
+			}
+			fmt.Printf("  %-16s weight %-6d %s%s\n", s.Provider, s.Weight, s.Price, bands)
+		}
+	}
+}
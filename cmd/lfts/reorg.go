@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"lfts/internal/reorg"
+	"lfts/internal/utils"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	reorgDepth  uint64
+	reorgBlocks uint64
+	reorgFTSO   []string
+	reorgFDC    []string
+)
+
+var reorgCmd = &cobra.Command{
+	Use:   "reorg",
+	Short: "Simulate a chain reorganization",
+	Long: `Orphans the last N blocks, restores the state as it was before them and mines a replacement chain,
+optionally replaying alternate updates. Example:
+  lfts reorg --depth 3 --ftso BTC=60000 --fdc 'weather={"temp":20}'`,
+	Args: cobra.NoArgs,
+	Run:  runReorg,
+}
+
+func init() {
+	reorgCmd.Flags().Uint64VarP(&reorgDepth, "depth", "d", 1, "Number of blocks to orphan")
+	reorgCmd.Flags().Uint64Var(&reorgBlocks, "blocks", 0, "Length of the replacement chain (default: depth)")
+	reorgCmd.Flags().StringArrayVar(&reorgFTSO, "ftso", nil, "Alternate FTSO price as ASSET=PRICE (repeatable)")
+	reorgCmd.Flags().StringArrayVar(&reorgFDC, "fdc", nil, "Alternate FDC feed as NAME=JSON (repeatable)")
+	reorgCmd.Flags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+
+	rootCmd.AddCommand(reorgCmd)
+}
+
+func runReorg(cmd *cobra.Command, args []string) {
+	req := reorg.Request{
+		Depth:  reorgDepth,
+		Blocks: reorgBlocks,
+	}
+
+	for _, entry := range reorgFTSO {
+		asset, priceStr, ok := strings.Cut(entry, "=")
+		if _, err := strconv.ParseFloat(priceStr, 64); !ok || err != nil {
+			utils.Error("Invalid --ftso value %q (expected ASSET=PRICE)", entry)
+			os.Exit(1)
+		}
+		req.Updates = append(req.Updates, reorg.Update{Type: "ftso", Asset: asset, Price: json.Number(priceStr)})
+	}
+
+	for _, entry := range reorgFDC {
+		name, jsonStr, ok := strings.Cut(entry, "=")
+		var data map[string]interface{}
+		if !ok || json.Unmarshal([]byte(jsonStr), &data) != nil {
+			utils.Error("Invalid --fdc value %q (expected NAME=JSON)", entry)
+			os.Exit(1)
+		}
+		req.Updates = append(req.Updates, reorg.Update{Type: "fdc", Name: name, Data: data})
+	}
+
+	body, _ := json.Marshal(req)
+	var result reorg.Result
+	if err := callNode("POST", "/chain/reorg", bytes.NewReader(body), &result); err != nil {
+		utils.Error("Reorg failed: %v", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Removed %d blocks:\n", len(result.Removed))
+	for _, block := range result.Removed {
+		fmt.Printf("  #%d %s\n", block.Number, block.Hash)
+	}
+	fmt.Printf("Added %d blocks:\n", len(result.Added))
+	for _, block := range result.Added {
+		fmt.Printf("  #%d %s (%d updates)\n", block.Number, block.Hash, len(block.Data.StateUpdates))
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/utils"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Set how much history is kept per feed",
+	Long:  "Sets how many historical entries the running node keeps for an FTSO asset or FDC feed. Shrinking drops the oldest entries.",
+}
+
+var retentionFTSOCmd = &cobra.Command{
+	Use:   "ftso <asset> <entries>",
+	Short: "Set the price history retention of an FTSO asset",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runRetention("/ftso/retention", url.Values{"asset": {args[0]}}, args[1])
+	},
+}
+
+var retentionFDCCmd = &cobra.Command{
+	Use:   "fdc <feed_name> <entries>",
+	Short: "Set the history retention of an FDC feed",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runRetention("/fdc/retention", url.Values{"name": {args[0]}}, args[1])
+	},
+}
+
+func init() {
+	retentionCmd.PersistentFlags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+
+	rootCmd.AddCommand(retentionCmd)
+	retentionCmd.AddCommand(retentionFTSOCmd)
+	retentionCmd.AddCommand(retentionFDCCmd)
+}
+
+func runRetention(path string, query url.Values, entries string) {
+	if n, err := strconv.Atoi(entries); err != nil || n <= 0 {
+		utils.Error("Invalid entries %q (expected a positive number)", entries)
+		os.Exit(1)
+	}
+	query.Set("entries", entries)
+
+	var result struct {
+		Retention int `json:"retention"`
+	}
+	if err := callNode("POST", path+"?"+query.Encode(), nil, &result); err != nil {
+		utils.Error("Retention request failed: %v", err)
+		os.Exit(1)
+	}
+	fmt.Printf("History retention: %d entries\n", result.Retention)
+}
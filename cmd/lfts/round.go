@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/ftso"
+	"lfts/internal/utils"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var roundEpoch int64
+
+var roundCmd = &cobra.Command{
+	Use:   "round",
+	Short: "Show the current FTSO voting round",
+	Long: `Shows the current FTSO voting round and the values pending for it. Submitted
+values are published when their round ends. Example: lfts round --epoch 5`,
+	Args: cobra.NoArgs,
+	Run:  runRound,
+}
+
+func init() {
+	roundCmd.Flags().Int64Var(&roundEpoch, "epoch", 0, "Change the voting epoch to this many seconds (0 publishes values immediately)")
+	roundCmd.Flags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+
+	rootCmd.AddCommand(roundCmd)
+}
+
+func runRound(cmd *cobra.Command, args []string) {
+	method, path := "GET", "/ftso/round"
+	if cmd.Flags().Changed("epoch") {
+		method, path = "POST", fmt.Sprintf("/ftso/round?epoch=%d", roundEpoch)
+	}
+
+	var result struct {
+		VotingEpoch int64             `json:"votingEpoch"`
+		Round       *ftso.VotingRound `json:"round"`
+		Pending     []ftso.FTSOPrice  `json:"pending"`
+	}
+	if err := callNode(method, path, nil, &result); err != nil {
+		utils.Error("Round request failed: %v", err)
+		os.Exit(1)
+	}
+
+	if result.Round == nil {
+		fmt.Println("Voting rounds disabled: FTSO values are published immediately")
+	} else {
+		fmt.Printf("Voting round %d (%d s epoch)\n", result.Round.ID, result.VotingEpoch)
+		fmt.Printf("Started: %s\n", utils.FormatTimestamp(result.Round.StartTime))
+		fmt.Printf("Ends:    %s\n", utils.FormatTimestamp(result.Round.EndTime))
+	}
+
+	if len(result.Pending) == 0 {
+		fmt.Println("No pending values")
+		return
+	}
+	fmt.Println("Pending values:")
+	for _, price := range result.Pending {
+		fmt.Printf("  %s = %s (round %d)\n", price.Asset, price.Price, price.VotingRoundID)
+	}
+}
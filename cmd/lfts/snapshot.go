@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/snapshot"
+	"lfts/internal/utils"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save and revert sandbox snapshots",
+	Long:  "Captures the full sandbox (state, chain height, blocks, clock and auto-updater base prices) and rolls back to it",
+}
+
+var snapshotSaveCmd = &cobra.Command{
+	Use:   "save",
+	Short: "Save a snapshot",
+	Args:  cobra.NoArgs,
+	Run:   runSnapshotSave,
+}
+
+var snapshotRevertCmd = &cobra.Command{
+	Use:   "revert <id>",
+	Short: "Revert to a snapshot",
+	Long:  "Reverts the sandbox to a snapshot. The snapshot and all snapshots taken after it are discarded.",
+	Args:  cobra.ExactArgs(1),
+	Run:   runSnapshotRevert,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved snapshots",
+	Args:  cobra.NoArgs,
+	Run:   runSnapshotList,
+}
+
+func init() {
+	snapshotCmd.PersistentFlags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotSaveCmd)
+	snapshotCmd.AddCommand(snapshotRevertCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+}
+
+func runSnapshotSave(cmd *cobra.Command, args []string) {
+	var response map[string]uint64
+	if err := callNode("POST", "/snapshot", nil, &response); err != nil {
+		utils.Error("Failed to save snapshot: %v", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Snapshot saved: %d\n", response["id"])
+}
+
+func runSnapshotRevert(cmd *cobra.Command, args []string) {
+	if err := callNode("POST", "/snapshot/revert?id="+args[0], nil, nil); err != nil {
+		utils.Error("Failed to revert snapshot: %v", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Reverted to snapshot %s\n", args[0])
+}
+
+func runSnapshotList(cmd *cobra.Command, args []string) {
+	var response struct {
+		Snapshots []snapshot.Info `json:"snapshots"`
+	}
+	if err := callNode("GET", "/snapshot", nil, &response); err != nil {
+		utils.Error("Failed to list snapshots: %v", err)
+		os.Exit(1)
+	}
+
+	if len(response.Snapshots) == 0 {
+		fmt.Println("No snapshots saved")
+		return
+	}
+	for _, info := range response.Snapshots {
+		fmt.Printf("%d: block %d, %d keys\n", info.ID, info.Height, info.Keys)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lfts/internal/state"
+	"lfts/internal/utils"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	proofBlock string
+	keysLimit  int
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspect the sandbox state",
+}
+
+var stateProofCmd = &cobra.Command{
+	Use:   "proof <key>",
+	Short: "Fetch and verify an inclusion proof for a state key",
+	Long: `Fetches a Merkle inclusion proof for a state key (e.g. ftso:BTC:latest) and
+verifies it locally against the stateRoot in the block header.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runStateProof,
+}
+
+var stateWatchCmd = &cobra.Command{
+	Use:   "watch [prefix]",
+	Short: "Stream state changes",
+	Long: `Prints every state change of keys starting with prefix (all keys if omitted)
+as blocks are sealed, rolled back or reverted. Example: lfts state watch ftso:BTC:`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runStateWatch,
+}
+
+var stateExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export the sandbox state to a dump file",
+	Long: `Writes every state key, the chain height and the retained blocks to a versioned
+JSON dump (stdout if no file is given). The same sandbox always produces the same file.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runStateExport,
+}
+
+var stateImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Replace the sandbox state with a dump file",
+	Long:  "Loads a dump written by 'lfts state export' into the running node, replacing its chain and state.",
+	Args:  cobra.ExactArgs(1),
+	Run:   runStateImport,
+}
+
+var stateKeysCmd = &cobra.Command{
+	Use:   "keys [prefix]",
+	Short: "List state keys",
+	Long: `Lists the raw state keys starting with prefix (all keys if omitted) in ascending
+order with the size of their values. Example: lfts state keys ftso:BTC:`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runStateKeys,
+}
+
+var stateGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Show the raw value of a state key",
+	Long:  "Prints the value stored at a state key exactly as FTSO or FDC wrote it, pretty-printed if it is JSON.",
+	Args:  cobra.ExactArgs(1),
+	Run:   runStateGet,
+}
+
+func init() {
+	stateCmd.PersistentFlags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+	stateProofCmd.Flags().StringVar(&proofBlock, "block", "latest", "Block number, hash or tag")
+	stateKeysCmd.Flags().IntVar(&keysLimit, "limit", 0, "Maximum number of keys to list (0 = all)")
+
+	rootCmd.AddCommand(stateCmd)
+	stateCmd.AddCommand(stateProofCmd)
+	stateCmd.AddCommand(stateWatchCmd)
+	stateCmd.AddCommand(stateExportCmd)
+	stateCmd.AddCommand(stateImportCmd)
+	stateCmd.AddCommand(stateKeysCmd)
+	stateCmd.AddCommand(stateGetCmd)
+}
+
+func runStateProof(cmd *cobra.Command, args []string) {
+	var result struct {
+		state.Proof
+		BlockHash string `json:"blockHash"`
+	}
+	query := url.Values{"key": {args[0]}, "block": {proofBlock}}
+	if err := callNode("GET", "/state/proof?"+query.Encode(), nil, &result); err != nil {
+		utils.Error("Proof request failed: %v", err)
+		os.Exit(1)
+	}
+
+	var header struct {
+		StateRoot string `json:"stateRoot"`
+	}
+	if err := callNode("GET", fmt.Sprintf("/block/%d", result.Block), nil, &header); err != nil {
+		utils.Error("Block request failed: %v", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Key:        %s\n", result.Key)
+	fmt.Printf("Value:      %s\n", result.Value)
+	fmt.Printf("Block:      %d (%s)\n", result.Block, result.BlockHash)
+	fmt.Printf("State root: %s\n", result.StateRoot)
+	fmt.Printf("Leaf:       %s (%d of %d)\n", result.Leaf, result.Index+1, result.LeafCount)
+	for i, sibling := range result.Siblings {
+		fmt.Printf("  %2d %-5s %s\n", i, sibling.Position, sibling.Hash)
+	}
+
+	if header.StateRoot != result.StateRoot {
+		utils.Error("Proof root does not match the block header (%s)", header.StateRoot)
+		os.Exit(1)
+	}
+	if err := state.VerifyProof(&result.Proof); err != nil {
+		utils.Error("Proof is invalid: %v", err)
+		os.Exit(1)
+	}
+	fmt.Println("Proof is valid")
+}
+
+func runStateWatch(cmd *cobra.Command, args []string) {
+	prefix := ""
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+
+	resp, err := http.Get(nodeURL("/state/events?" + url.Values{"prefix": {prefix}}.Encode()))
+	if err != nil {
+		utils.Error("node not reachable on port %s (is 'lfts start' running?): %v", rpcPort, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		utils.Error("Watch request failed: status %d", resp.StatusCode)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Watching state changes of %q (Ctrl+C to stop)\n", prefix)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var change struct {
+			Key      string  `json:"key"`
+			Old      *string `json:"old"`
+			New      *string `json:"new"`
+			Block    uint64  `json:"block"`
+			Rollback bool    `json:"rollback"`
+		}
+		if err := json.Unmarshal([]byte(data), &change); err != nil {
+			continue
+		}
+
+		suffix := ""
+		if change.Rollback {
+			suffix = " (rollback)"
+		}
+		fmt.Printf("#%d %s: %s -> %s%s\n", change.Block, change.Key, valueText(change.Old), valueText(change.New), suffix)
+	}
+	if err := scanner.Err(); err != nil {
+		utils.Error("Watch stream failed: %v", err)
+		os.Exit(1)
+	}
+}
+
+// valueText formats a state value for display
+func valueText(value *string) string {
+	if value == nil {
+		return "(none)"
+	}
+	return *value
+}
+
+func runStateExport(cmd *cobra.Command, args []string) {
+	resp, err := http.Get(nodeURL("/state/export"))
+	if err != nil {
+		utils.Error("node not reachable on port %s (is 'lfts start' running?): %v", rpcPort, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		utils.Error("Export failed: status %d - %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+		os.Exit(1)
+	}
+
+	if len(args) == 0 {
+		io.Copy(os.Stdout, resp.Body)
+		return
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		utils.Error("Export failed: %v", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(args[0], data, 0644); err != nil {
+		utils.Error("Failed to write %s: %v", args[0], err)
+		os.Exit(1)
+	}
+	fmt.Printf("Exported state to %s\n", args[0])
+}
+
+func runStateImport(cmd *cobra.Command, args []string) {
+	f, err := os.Open(args[0])
+	if err != nil {
+		utils.Error("Failed to read dump: %v", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	var result struct {
+		Height uint64 `json:"height"`
+		Blocks int    `json:"blocks"`
+		Keys   int    `json:"keys"`
+	}
+	if err := callNode("POST", "/state/import", f, &result); err != nil {
+		utils.Error("Import failed: %v", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Imported %d keys and %d blocks, chain height %d\n", result.Keys, result.Blocks, result.Height)
+}
+
+func runStateKeys(cmd *cobra.Command, args []string) {
+	prefix := ""
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+
+	var result struct {
+		Count     int             `json:"count"`
+		Truncated bool            `json:"truncated"`
+		Keys      []state.KeyInfo `json:"keys"`
+	}
+	query := url.Values{"prefix": {prefix}, "limit": {fmt.Sprint(keysLimit)}}
+	if err := callNode("GET", "/debug/state/keys?"+query.Encode(), nil, &result); err != nil {
+		utils.Error("Keys request failed: %v", err)
+		os.Exit(1)
+	}
+
+	for _, key := range result.Keys {
+		fmt.Printf("%-48s %6d bytes\n", key.Key, key.Size)
+	}
+	if result.Truncated {
+		fmt.Printf("%d keys shown (limit reached)\n", result.Count)
+	} else {
+		fmt.Printf("%d keys\n", result.Count)
+	}
+}
+
+func runStateGet(cmd *cobra.Command, args []string) {
+	var result state.KeyValue
+	if err := callNode("GET", "/debug/state/"+url.PathEscape(args[0]), nil, &result); err != nil {
+		utils.Error("Get request failed: %v", err)
+		os.Exit(1)
+	}
+
+	value := string(result.Value)
+	switch result.Encoding {
+	case "json":
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, result.Value, "", "  ") == nil {
+			value = pretty.String()
+		}
+	default:
+		json.Unmarshal(result.Value, &value)
+	}
+	fmt.Printf("%s (%d bytes, %s)\n%s\n", result.Key, result.Size, result.Encoding, value)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/utils"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var timeCmd = &cobra.Command{
+	Use:   "time",
+	Short: "Show or manipulate the chain clock",
+	Long:  "Shows the chain clock of the running node. Subcommands move time forward, pin the next block timestamp or freeze the clock.",
+	Args:  cobra.NoArgs,
+	Run:   runTimeShow,
+}
+
+var timeIncreaseCmd = &cobra.Command{
+	Use:   "increase <seconds>",
+	Short: "Move the chain clock forward",
+	Args:  cobra.ExactArgs(1),
+	Run:   runTimeIncrease,
+}
+
+var timeNextBlockCmd = &cobra.Command{
+	Use:   "next-block <timestamp>",
+	Short: "Set the timestamp of the next block",
+	Long:  "Pins the unix timestamp of the next block. The clock continues from that time afterwards.",
+	Args:  cobra.ExactArgs(1),
+	Run:   runTimeNextBlock,
+}
+
+var timeFreezeCmd = &cobra.Command{
+	Use:   "freeze",
+	Short: "Freeze the chain clock",
+	Args:  cobra.NoArgs,
+	Run:   runTimeFreeze,
+}
+
+var timeUnfreezeCmd = &cobra.Command{
+	Use:   "unfreeze",
+	Short: "Let the chain clock run again",
+	Args:  cobra.NoArgs,
+	Run:   runTimeFreeze,
+}
+
+func init() {
+	timeCmd.PersistentFlags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+
+	rootCmd.AddCommand(timeCmd)
+	timeCmd.AddCommand(timeIncreaseCmd)
+	timeCmd.AddCommand(timeNextBlockCmd)
+	timeCmd.AddCommand(timeFreezeCmd)
+	timeCmd.AddCommand(timeUnfreezeCmd)
+}
+
+// printTime prints the clock state returned by the /time endpoints
+func printTime(clock map[string]interface{}) {
+	now := int64(clock["now"].(float64))
+	fmt.Printf("Chain time: %d (%s)\n", now, utils.FormatTimestamp(now))
+	fmt.Printf("Offset: %.0f s\n", clock["offset"])
+	fmt.Printf("Frozen: %v\n", clock["frozen"])
+	if next, _ := clock["nextBlockTimestamp"].(float64); next != 0 {
+		fmt.Printf("Next block timestamp: %.0f\n", next)
+	}
+}
+
+// timeRequest calls a /time endpoint and prints the resulting clock state
+func timeRequest(method, path string) {
+	var clock map[string]interface{}
+	if err := callNode(method, path, nil, &clock); err != nil {
+		utils.Error("Time request failed: %v", err)
+		os.Exit(1)
+	}
+	printTime(clock)
+}
+
+func runTimeShow(cmd *cobra.Command, args []string) {
+	timeRequest("GET", "/time")
+}
+
+func runTimeIncrease(cmd *cobra.Command, args []string) {
+	timeRequest("POST", "/time/increase?seconds="+args[0])
+}
+
+func runTimeNextBlock(cmd *cobra.Command, args []string) {
+	timeRequest("POST", "/time/next-block?timestamp="+args[0])
+}
+
+func runTimeFreeze(cmd *cobra.Command, args []string) {
+	timeRequest("POST", "/time/"+cmd.Name())
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"lfts/internal/utils"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var ttlStaleAfter int64
+
+var ttlCmd = &cobra.Command{
+	Use:   "ttl <feed_name> [seconds]",
+	Short: "Show or set how long FDC feed values stay valid",
+	Long: `Shows or sets the TTL of an FDC feed. A value is stale after --stale-after seconds
+(half the TTL by default) and expired after the TTL, counted from its timestamp on the
+chain clock. A TTL of 0 removes it. Example: lfts ttl weather 3600 --stale-after 600`,
+	Args: cobra.RangeArgs(1, 2),
+	Run:  runTTL,
+}
+
+func init() {
+	ttlCmd.Flags().StringVarP(&rpcPort, "port", "p", "9650", "RPC server port")
+	ttlCmd.Flags().Int64Var(&ttlStaleAfter, "stale-after", 0, "Seconds until a value is stale (0 = half the TTL)")
+
+	rootCmd.AddCommand(ttlCmd)
+}
+
+func runTTL(cmd *cobra.Command, args []string) {
+	query := url.Values{"name": {args[0]}}
+	method := "GET"
+	if len(args) == 2 {
+		if n, err := strconv.ParseInt(args[1], 10, 64); err != nil || n < 0 {
+			utils.Error("Invalid TTL %q (expected seconds)", args[1])
+			os.Exit(1)
+		}
+		method = "POST"
+		query.Set("ttl", args[1])
+		query.Set("staleAfter", strconv.FormatInt(ttlStaleAfter, 10))
+	}
+
+	var result struct {
+		TTL        int64 `json:"ttl"`
+		StaleAfter int64 `json:"staleAfter"`
+	}
+	if err := callNode(method, "/fdc/ttl?"+query.Encode(), nil, &result); err != nil {
+		utils.Error("TTL request failed: %v", err)
+		os.Exit(1)
+	}
+
+	if result.TTL == 0 {
+		fmt.Printf("%s: no TTL (values never expire)\n", args[0])
+		return
+	}
+	staleAfter := result.StaleAfter
+	if staleAfter == 0 {
+		staleAfter = result.TTL / 2
+	}
+	fmt.Printf("%s: stale after %ds, expired after %ds\n", args[0], staleAfter, result.TTL)
+}
@@ -6,6 +6,7 @@ import (
 	"lfts/internal/utils"
 	"math"
 	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -31,12 +32,52 @@ type Config struct {
 	StopChan   <-chan struct{}
 }
 
+var (
+	// basePricesMu guards the base prices of the running updater, which are also
+	// read and replaced by snapshots
+	basePricesMu sync.Mutex
+	basePrices   map[string]float64
+)
+
+// GetBasePrices returns a copy of the running updater's base prices (nil if none runs)
+func GetBasePrices() map[string]float64 {
+	basePricesMu.Lock()
+	defer basePricesMu.Unlock()
+	if basePrices == nil {
+		return nil
+	}
+	prices := make(map[string]float64, len(basePrices))
+	for asset, price := range basePrices {
+		prices[asset] = price
+	}
+	return prices
+}
+
+// SetBasePrices replaces the running updater's base prices
+func SetBasePrices(prices map[string]float64) {
+	basePricesMu.Lock()
+	defer basePricesMu.Unlock()
+	if basePrices == nil {
+		return
+	}
+	for asset := range basePrices {
+		delete(basePrices, asset)
+	}
+	for asset, price := range prices {
+		basePrices[asset] = price
+	}
+}
+
 // StartAutoUpdate starts the automatic price update loop
 func StartAutoUpdate(config Config) {
 	if !config.Enabled {
 		return
 	}
 
+	basePricesMu.Lock()
+	basePrices = config.BasePrices
+	basePricesMu.Unlock()
+
 	go func() {
 		ticker := time.NewTicker(config.Interval)
 		defer ticker.Stop()
@@ -44,12 +85,13 @@ func StartAutoUpdate(config Config) {
 		utils.Info("Auto-update started: pattern=%s, interval=%v, assets=%v", config.Pattern, config.Interval, config.Assets)
 
 		// Initialize prices if not set
+		basePricesMu.Lock()
 		for _, asset := range config.Assets {
 			if _, exists := config.BasePrices[asset]; !exists {
 				// Get current price or use default
 				current, err := ftso.GetPrice(asset)
 				if err == nil && current != nil {
-					config.BasePrices[asset] = current.Price
+					config.BasePrices[asset] = current.Float64()
 				} else {
 					// Default prices for common assets
 					defaultPrices := map[string]float64{
@@ -65,8 +107,9 @@ func StartAutoUpdate(config Config) {
 				}
 			}
 		}
+		basePricesMu.Unlock()
 
-		startTime := time.Now()
+		startTime := chain.Now()
 		updateCount := 0
 
 		for {
@@ -82,12 +125,16 @@ func StartAutoUpdate(config Config) {
 
 				// Update each asset
 				for _, asset := range config.Assets {
+					basePricesMu.Lock()
 					basePrice := config.BasePrices[asset]
+					basePricesMu.Unlock()
 					newPrice := calculateNewPrice(config.Pattern, basePrice, config.Volatility, startTime, updateCount)
-					
-					err := ftso.SetPrice(asset, newPrice)
+
+					_, err := ftso.SubmitPrice(asset, newPrice)
 					if err == nil {
+						basePricesMu.Lock()
 						config.BasePrices[asset] = newPrice
+						basePricesMu.Unlock()
 						utils.Info("Auto-updated %s: %.2f", asset, newPrice)
 					}
 				}
@@ -108,7 +155,7 @@ func calculateNewPrice(pattern Pattern, basePrice, volatility float64, startTime
 
 	case PatternSine:
 		// Sine wave: oscillating price
-		elapsed := time.Since(startTime).Seconds()
+		elapsed := chain.Now().Sub(startTime).Seconds()
 		amplitude := basePrice * volatility / 100
 		period := 60.0 // 60 second period
 		oscillation := math.Sin(2*math.Pi*elapsed/period) * amplitude
@@ -1,27 +1,162 @@
 package chain
 
-import "time"
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"lfts/internal/utils"
+	"strings"
+)
+
+const (
+	// DefaultGasLimit is the gas limit reported for every block
+	DefaultGasLimit = 8000000
+
+	// DefaultMiner is the coinbase reported for every block
+	DefaultMiner = "0x0000000000000000000000000000000000000000"
+)
+
+// Hash is a 32-byte Keccak-256 hash
+type Hash [32]byte
+
+var (
+	// EmptyRoot is the root of an empty Merkle Patricia trie (keccak256(rlp("")))
+	EmptyRoot = MustParseHash("0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// EmptyUncleHash is keccak256(rlp([])), reported as sha3Uncles
+	EmptyUncleHash = MustParseHash("0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347")
+)
+
+// String returns the 0x-prefixed hex form of the hash
+func (h Hash) String() string {
+	return "0x" + hex.EncodeToString(h[:])
+}
+
+// MarshalText encodes the hash as 0x-prefixed hex
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText decodes a 0x-prefixed hex hash
+func (h *Hash) UnmarshalText(text []byte) error {
+	parsed, err := ParseHash(string(text))
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
+
+// ParseHash parses a 0x-prefixed 32-byte hex string
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	raw := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(raw) != 64 {
+		return h, fmt.Errorf("invalid hash length: %s", s)
+	}
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return h, fmt.Errorf("invalid hash: %s", s)
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+// MustParseHash parses a hash and panics on error (for constants)
+func MustParseHash(s string) Hash {
+	h, err := ParseHash(s)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// Header contains the block metadata covered by the block hash
+type Header struct {
+	Number      uint64 `json:"number"`
+	ParentHash  Hash   `json:"parentHash"`
+	Timestamp   int64  `json:"timestamp"`
+	StateRoot   Hash   `json:"stateRoot"`
+	UpdatesRoot Hash   `json:"updatesRoot"` // commitment to the block body
+	Miner       string `json:"miner"`
+	GasLimit    uint64 `json:"gasLimit"`
+	GasUsed     uint64 `json:"gasUsed"`
+	Difficulty  uint64 `json:"difficulty"`
+	ExtraData   string `json:"extraData"`
+}
 
 // Block represents a single block in the chain
 type Block struct {
-	Number    uint64    `json:"number"`
-	Timestamp int64     `json:"timestamp"`
-	Data      BlockData `json:"data"`
+	Header
+	Hash Hash      `json:"hash"`
+	Data BlockData `json:"data"`
 }
 
-// BlockData contains the state updates included in the block
+// BlockData contains the state updates included in the block. Each key maps to
+// the value it was last set to in the block, or to nil (null in JSON) if it was
+// deleted.
 type BlockData struct {
-	StateUpdates map[string]string `json:"stateUpdates,omitempty"`
+	StateUpdates map[string]*string `json:"stateUpdates,omitempty"`
+}
+
+// Root returns the commitment to the block body
+func (d BlockData) Root() Hash {
+	if len(d.StateUpdates) == 0 {
+		return EmptyRoot
+	}
+	// encoding/json sorts map keys, so the encoding is deterministic
+	encoded, _ := json.Marshal(d.StateUpdates)
+	return utils.Keccak256(encoded)
 }
 
-// NewBlock creates a new block with the given number
-func NewBlock(number uint64) *Block {
+// Hash returns the Keccak-256 hash of the header's canonical JSON encoding
+func (h Header) Hash() Hash {
+	encoded, _ := json.Marshal(h)
+	return utils.Keccak256(encoded)
+}
+
+// NewBlock creates a new block with the given number, timestamp, state root and body on top of parent (nil for the first block)
+func NewBlock(number uint64, parent *Block, timestamp int64, stateRoot Hash, data BlockData) *Block {
+	if data.StateUpdates == nil {
+		data.StateUpdates = make(map[string]*string)
+	}
+
+	header := Header{
+		Number:      number,
+		Timestamp:   timestamp,
+		StateRoot:   stateRoot,
+		UpdatesRoot: data.Root(),
+		Miner:       DefaultMiner,
+		GasLimit:    DefaultGasLimit,
+		ExtraData:   "0x",
+	}
+	if parent != nil {
+		header.ParentHash = parent.Hash
+	}
+
 	return &Block{
-		Number:    number,
-		Timestamp: time.Now().Unix(),
-		Data: BlockData{
-			StateUpdates: make(map[string]string),
-		},
+		Header: header,
+		Hash:   header.Hash(),
+		Data:   data,
 	}
 }
 
+// Verify checks the block hash, body commitment and link to parent (nil for the first block)
+func (b *Block) Verify(parent *Block) error {
+	if root := b.Data.Root(); root != b.UpdatesRoot {
+		return fmt.Errorf("block %d: updates root mismatch: have %s, want %s", b.Number, b.UpdatesRoot, root)
+	}
+	if hash := b.Header.Hash(); hash != b.Hash {
+		return fmt.Errorf("block %d: hash mismatch: have %s, want %s", b.Number, b.Hash, hash)
+	}
+	if parent == nil {
+		return nil
+	}
+	if b.Number != parent.Number+1 {
+		return fmt.Errorf("block %d: not a child of block %d", b.Number, parent.Number)
+	}
+	if b.ParentHash != parent.Hash {
+		return fmt.Errorf("block %d: parent hash mismatch: have %s, want %s", b.Number, b.ParentHash, parent.Hash)
+	}
+	return nil
+}
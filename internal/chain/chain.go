@@ -1,51 +1,103 @@
 package chain
 
 import (
+	"errors"
+	"fmt"
+	"lfts/internal/state"
+	"lfts/internal/utils"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// DefaultChainID is the chain ID reported when none is configured
+const DefaultChainID = 31337
+
 // Chain represents the blockchain state
 type Chain struct {
 	mu            sync.RWMutex
+	chainID       uint64
+	sealMu        sync.RWMutex // held exclusively while a block is sealed
 	currentHeight uint64
 	latestBlock   *Block
+	blocks        *BlockStore
+	clock         *Clock
+	pending       BlockData     // body of the next block
+	newBlock      chan struct{} // closed and replaced whenever a block is created
 	running       bool
 	blockTime     time.Duration
+	miningMode    MiningMode
+	configChanged chan struct{} // signals the loop to reload block time and mode
+	subs          subscribers
+	sealHooks     []SealHook
+	dueHooks      []DueHook
 	stopChan      chan struct{}
+	loopDone      chan struct{} // closed when the running loop exits (nil without a loop)
 }
 
+// SealHook runs before a block is sealed, with the number and timestamp the block
+// will have. State writes it makes (recorded with RecordStateUpdate) belong to
+// that block. It runs while sealing is held off, so it must not call Update.
+type SealHook func(number uint64, timestamp int64) error
+
+// DueHook reports whether a block is due at the given chain time although nothing
+// was written, e.g. because a voting round ended with values waiting for a block
+type DueHook func(now int64) bool
+
 // NewChain creates a new chain instance
 func NewChain(blockTimeMs int) *Chain {
 	return &Chain{
+		chainID:       DefaultChainID,
 		currentHeight: 0,
+		blocks:        NewBlockStore(0),
+		clock:         NewClock(),
+		pending:       BlockData{StateUpdates: make(map[string]*string)},
+		newBlock:      make(chan struct{}),
 		blockTime:     time.Duration(blockTimeMs) * time.Millisecond,
+		miningMode:    MiningInterval,
+		configChanged: make(chan struct{}, 1),
 		stopChan:      make(chan struct{}),
 		running:       false,
 	}
 }
 
-// Start begins the block generation loop
-func (c *Chain) Start() {
+// Start marks the chain as running and spawns the block generation loop with a
+// fresh stop channel. It returns false if the chain was already running.
+func (c *Chain) Start() bool {
 	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.running {
-		c.mu.Unlock()
-		return
+		return false
 	}
+	// Set up the loop under mu, so a concurrent Stop always waits for it
 	c.running = true
 	c.stopChan = make(chan struct{})
-	c.mu.Unlock()
+	c.loopDone = make(chan struct{})
+	go c.runLoop(c.stopChan, c.loopDone)
+	return true
 }
 
-// Stop halts the block generation loop
-func (c *Chain) Stop() {
+// Stop halts the block generation loop and waits for it to exit, so no block is
+// sealed by the loop after Stop returns. It returns false if the chain was not
+// running.
+func (c *Chain) Stop() bool {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	if !c.running {
-		return
+		c.mu.Unlock()
+		return false
 	}
 	c.running = false
 	close(c.stopChan)
+	done := c.loopDone
+	c.loopDone = nil
+	c.mu.Unlock()
+
+	// The loop may be sealing a block, which needs mu
+	if done != nil {
+		<-done
+	}
+	return true
 }
 
 // IsRunning returns whether the chain is running
@@ -55,6 +107,20 @@ func (c *Chain) IsRunning() bool {
 	return c.running
 }
 
+// GetChainID returns the chain ID reported over JSON-RPC
+func (c *Chain) GetChainID() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.chainID
+}
+
+// SetChainID sets the chain ID reported over JSON-RPC
+func (c *Chain) SetChainID(chainID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chainID = chainID
+}
+
 // GetHeight returns the current block height
 func (c *Chain) GetHeight() uint64 {
 	c.mu.RLock()
@@ -69,6 +135,138 @@ func (c *Chain) GetLatestBlock() *Block {
 	return c.latestBlock
 }
 
+// Clock returns the chain clock
+func (c *Chain) Clock() *Clock {
+	return c.clock
+}
+
+// SetNextBlockTimestamp pins the timestamp of the next block, which must not be
+// older than the latest block
+func (c *Chain) SetNextBlockTimestamp(ts int64) error {
+	if last := c.GetLastBlockTime(); ts < last {
+		return fmt.Errorf("timestamp %d is older than the latest block (%d)", ts, last)
+	}
+	c.clock.SetNextBlockTimestamp(ts)
+	return nil
+}
+
+// GetBlockByNumber returns a retained block by number, or nil if unknown or pruned
+func (c *Chain) GetBlockByNumber(number uint64) *Block {
+	return c.blocks.GetByNumber(number)
+}
+
+// GetBlockByHash returns a retained block by hash, or nil if unknown or pruned
+func (c *Chain) GetBlockByHash(hash Hash) *Block {
+	return c.blocks.GetByHash(hash)
+}
+
+// GetBlocks returns the retained blocks in the inclusive range [from, to]
+func (c *Chain) GetBlocks(from, to uint64) []*Block {
+	return c.blocks.GetRange(from, to)
+}
+
+// GetEarliestBlock returns the oldest retained block
+func (c *Chain) GetEarliestBlock() *Block {
+	return c.blocks.Earliest()
+}
+
+// SetBlockRetention sets how many recent blocks are kept (0 keeps all)
+func (c *Chain) SetBlockRetention(retention uint64) {
+	c.blocks.SetRetention(retention)
+}
+
+// GetBlockRetention returns the block retention window (0 = unlimited)
+func (c *Chain) GetBlockRetention() uint64 {
+	return c.blocks.Retention()
+}
+
+// ResolveBlock looks up a block by tag ("latest", "earliest", "pending", "safe",
+// "finalized"), hash, hex number ("0x2a") or decimal number ("42")
+func (c *Chain) ResolveBlock(ref string) (*Block, error) {
+	if len(ref) == 66 {
+		hash, err := ParseHash(ref)
+		if err != nil {
+			return nil, err
+		}
+		return c.GetBlockByHash(hash), nil
+	}
+
+	switch strings.ToLower(ref) {
+	case "", "latest", "pending", "safe", "finalized":
+		// Blocks are final as soon as they are produced and there is no pending
+		// block to expose, so all of these resolve to the head
+		return c.GetLatestBlock(), nil
+	case "earliest":
+		return c.GetEarliestBlock(), nil
+	}
+
+	number, err := ParseBlockNumber(ref)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetBlockByNumber(number), nil
+}
+
+// ErrBlockNotFound is returned when a block reference matches no retained block
+var ErrBlockNotFound = errors.New("block not found")
+
+// ResolveBlockNumber resolves a block reference (see ResolveBlock) on the global
+// chain to the number of a retained block
+func ResolveBlockNumber(ref string) (uint64, error) {
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		return 0, fmt.Errorf("chain not initialized")
+	}
+	block, err := chainInstance.ResolveBlock(ref)
+	if err != nil {
+		return 0, err
+	}
+	if block == nil {
+		return 0, fmt.Errorf("%w: %s", ErrBlockNotFound, ref)
+	}
+	return block.Number, nil
+}
+
+// ParseBlockNumber parses a hex ("0x2a") or decimal ("42") block number
+func ParseBlockNumber(s string) (uint64, error) {
+	var (
+		number uint64
+		err    error
+	)
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		number, err = strconv.ParseUint(s[2:], 16, 64)
+	} else {
+		number, err = strconv.ParseUint(s, 10, 64)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid block number: %s", s)
+	}
+	return number, nil
+}
+
+// Verify checks hashes and parent links of all retained blocks, and the state root of the head
+func (c *Chain) Verify() (int, error) {
+	blocks := c.blocks.GetRange(0, c.GetHeight())
+	for i, block := range blocks {
+		var parent *Block
+		if i > 0 {
+			parent = blocks[i-1]
+		}
+		if err := block.Verify(parent); err != nil {
+			return i, err
+		}
+	}
+
+	// The head must commit to the current state
+	if n := len(blocks); n > 0 {
+		head := blocks[n-1]
+		if root, err := state.GlobalState.Root(head.Number); err == nil && Hash(root) != head.StateRoot {
+			return n - 1, fmt.Errorf("block %d: state root mismatch: have %s, want %s", head.Number, head.StateRoot, Hash(root))
+		}
+	}
+	return len(blocks), nil
+}
+
 // GetLastBlockTime returns the timestamp of the latest block
 func (c *Chain) GetLastBlockTime() int64 {
 	c.mu.RLock()
@@ -79,26 +277,213 @@ func (c *Chain) GetLastBlockTime() int64 {
 	return c.latestBlock.Timestamp
 }
 
-// GetStopChan returns the stop channel for the loop
-func (c *Chain) GetStopChan() <-chan struct{} {
-	return c.stopChan
-}
-
 // GetBlockTime returns the configured block time
 func (c *Chain) GetBlockTime() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.blockTime
 }
 
-// CreateBlock creates and stores a new block
+// CreateBlock seals the pending updates into a new block and stores it
 func (c *Chain) CreateBlock() *Block {
+	c.sealMu.Lock()
+	defer c.sealMu.Unlock()
+	return c.sealPending()
+}
+
+// SealBlocks seals count blocks, the first with the pending updates. Call it from
+// within Exclusive, so no other block is sealed in between.
+func (c *Chain) SealBlocks(count uint64) []*Block {
+	blocks := make([]*Block, 0, count)
+	for i := uint64(0); i < count; i++ {
+		block := c.sealPending()
+		utils.LogBlock(block.Number, block.Timestamp)
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// AddSealHook registers a hook that runs before every block is sealed
+func (c *Chain) AddSealHook(hook SealHook) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.currentHeight++
-	block := NewBlock(c.currentHeight)
+	c.sealHooks = append(c.sealHooks, hook)
+}
+
+// AddDueHook registers a hook that makes the chain loop seal a block in auto
+// mining mode once the hook reports one is due
+func (c *Chain) AddDueHook(hook DueHook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dueHooks = append(c.dueHooks, hook)
+}
+
+// blockDue reports whether any due hook wants a block sealed now
+func (c *Chain) blockDue() bool {
+	c.mu.RLock()
+	hooks := c.dueHooks
+	c.mu.RUnlock()
+
+	now := c.clock.Now().Unix()
+	for _, hook := range hooks {
+		if hook(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// sealPending builds the next block from the pending body (caller holds sealMu)
+func (c *Chain) sealPending() *Block {
+	c.mu.Lock()
+	var minTimestamp int64
+	if c.latestBlock != nil {
+		minTimestamp = c.latestBlock.Timestamp
+	}
+	number := c.currentHeight + 1
+	timestamp := c.clock.blockTimestamp(minTimestamp)
+	hooks := c.sealHooks
+	c.mu.Unlock()
+
+	// Hooks record their writes, so run them without holding mu
+	for _, hook := range hooks {
+		if err := hook(number, timestamp); err != nil {
+			utils.Error("Seal hook of block %d failed: %v", number, err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Commit the state first: on restart, state ahead of the persisted blocks is rolled back
+	state.GlobalState.Commit(number)
+	stateRoot, err := state.GlobalState.Root(number)
+	if err != nil {
+		utils.Error("State root of block %d unavailable: %v", number, err)
+	}
+
+	block := NewBlock(number, c.latestBlock, timestamp, stateRoot, c.pending)
+	c.currentHeight = number
+	c.pending = BlockData{StateUpdates: make(map[string]*string)}
 	c.latestBlock = block
+	c.blocks.Add(block)
+
+	close(c.newBlock)
+	c.newBlock = make(chan struct{})
+	c.publish(BlockEvent{Type: EventNewBlock, Block: block})
 	return block
 }
 
+// Rewind removes the last depth blocks, rolls the state back to the new head and
+// discards pending updates. Removed blocks are returned newest first and
+// reported to block subscribers. Call it from within Exclusive.
+func (c *Chain) Rewind(depth uint64) ([]*Block, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if depth == 0 || depth >= c.currentHeight {
+		return nil, fmt.Errorf("invalid depth %d at height %d", depth, c.currentHeight)
+	}
+
+	newHeight := c.currentHeight - depth
+	newHead := c.blocks.GetByNumber(newHeight)
+	if newHead == nil {
+		return nil, fmt.Errorf("block %d has been pruned", newHeight)
+	}
+
+	if err := state.GlobalState.Rollback(newHeight); err != nil {
+		return nil, err
+	}
+
+	removed := c.blocks.GetRange(newHeight+1, c.currentHeight)
+	c.blocks.Truncate(newHeight)
+	c.currentHeight = newHeight
+	c.latestBlock = newHead
+	c.pending = BlockData{StateUpdates: make(map[string]*string)}
+
+	// Report the old chain tip first
+	for i, j := 0, len(removed)-1; i < j; i, j = i+1, j-1 {
+		removed[i], removed[j] = removed[j], removed[i]
+	}
+	for _, block := range removed {
+		c.publish(BlockEvent{Type: EventRemovedBlock, Block: block})
+	}
+	return removed, nil
+}
+
+// Update runs fn while block sealing is held off. blockNum is the number of the
+// block that will include every state update recorded by fn. In auto mining mode
+// that block is sealed before Update returns.
+func (c *Chain) Update(fn func(blockNum uint64) error) error {
+	c.sealMu.RLock()
+	blockNum := c.GetHeight() + 1
+	err := fn(blockNum)
+	c.sealMu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if c.GetMiningMode() == MiningAuto && c.IsRunning() {
+		c.sealMu.Lock()
+		defer c.sealMu.Unlock()
+		// A concurrent writer may already have sealed the block
+		if c.GetHeight() < blockNum {
+			block := c.sealPending()
+			utils.LogBlock(block.Number, block.Timestamp)
+		}
+	}
+	return nil
+}
+
+// RecordStateUpdate adds a state write to the pending block body; a nil value
+// records a deletion. Call it from within Update so the write cannot slip into
+// a different block.
+func (c *Chain) RecordStateUpdate(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if value == nil {
+		c.pending.StateUpdates[key] = nil
+		return
+	}
+	v := string(value)
+	c.pending.StateUpdates[key] = &v
+}
+
+// GetPendingUpdates returns a copy of the updates waiting for the next block
+func (c *Chain) GetPendingUpdates() map[string]*string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	updates := make(map[string]*string, len(c.pending.StateUpdates))
+	for k, v := range c.pending.StateUpdates {
+		updates[k] = v
+	}
+	return updates
+}
+
+// WaitForBlock blocks until the block with the given number exists or the timeout
+// expires. Returns nil on timeout or if the block has already been pruned.
+func (c *Chain) WaitForBlock(number uint64, timeout time.Duration) *Block {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		c.mu.RLock()
+		height := c.currentHeight
+		wait := c.newBlock
+		c.mu.RUnlock()
+
+		if height >= number {
+			return c.GetBlockByNumber(number)
+		}
+
+		select {
+		case <-wait:
+		case <-deadline.C:
+			return nil
+		}
+	}
+}
+
 // Global chain instance
 var globalChain *Chain
 
@@ -112,3 +497,41 @@ func SetInstance(chain *Chain) {
 	globalChain = chain
 }
 
+// WithPendingBlock runs fn through the global chain's Update, or with block
+// number 0 when no chain is running in this process
+func WithPendingBlock(fn func(blockNum uint64) error) error {
+	if globalChain == nil {
+		return fn(0)
+	}
+	return globalChain.Update(fn)
+}
+
+// WaitForInclusion waits for the global chain to seal the given block, allowing
+// up to two block intervals. Returns nil if the block is not produced in time or
+// immediately in manual mining mode, where the block waits for an explicit mine.
+func WaitForInclusion(blockNum uint64) *Block {
+	if globalChain == nil || blockNum == 0 {
+		return nil
+	}
+	if globalChain.GetMiningMode() == MiningManual {
+		return globalChain.GetBlockByNumber(blockNum)
+	}
+	return globalChain.WaitForBlock(blockNum, 2*globalChain.GetBlockTime()+time.Second)
+}
+
+// Now returns the global chain's clock time, or the wall clock when no chain is
+// running in this process. All subsystems should use it instead of time.Now.
+func Now() time.Time {
+	if globalChain == nil {
+		return time.Now()
+	}
+	return globalChain.clock.Now()
+}
+
+// RecordStateUpdate records a state write in the global chain's pending block
+func RecordStateUpdate(key string, value []byte) {
+	if globalChain != nil {
+		globalChain.RecordStateUpdate(key, value)
+	}
+}
+
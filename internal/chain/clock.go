@@ -0,0 +1,137 @@
+package chain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Clock is the chain's notion of the current time. It follows the wall clock
+// shifted by an offset, can be frozen, and can pin the next block's timestamp.
+type Clock struct {
+	mu                 sync.Mutex
+	offset             time.Duration
+	frozen             bool
+	frozenAt           time.Time
+	nextBlockTimestamp int64 // 0 when unset
+}
+
+// NewClock creates a clock that follows the wall clock
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+// Now returns the current chain time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now()
+}
+
+// now returns the current chain time (caller holds the lock)
+func (c *Clock) now() time.Time {
+	if c.frozen {
+		return c.frozenAt
+	}
+	return time.Now().Add(c.offset)
+}
+
+// IncreaseTime moves the clock forward
+func (c *Clock) IncreaseTime(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("cannot move time backwards")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.frozen {
+		c.frozenAt = c.frozenAt.Add(d)
+	} else {
+		c.offset += d
+	}
+	return nil
+}
+
+// SetTime jumps the clock so that Now returns t
+func (c *Clock) SetTime(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setTime(t)
+}
+
+// setTime jumps the clock (caller holds the lock)
+func (c *Clock) setTime(t time.Time) {
+	if c.frozen {
+		c.frozenAt = t
+	} else {
+		c.offset = time.Until(t)
+	}
+}
+
+// GetOffset returns how far the clock is ahead of the wall clock
+func (c *Clock) GetOffset() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now().Sub(time.Now())
+}
+
+// Freeze stops the clock at its current time
+func (c *Clock) Freeze() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.frozen {
+		c.frozenAt = c.now()
+		c.frozen = true
+	}
+}
+
+// Unfreeze lets the clock run again from where it was frozen
+func (c *Clock) Unfreeze() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.frozen {
+		c.offset = time.Until(c.frozenAt)
+		c.frozen = false
+	}
+}
+
+// IsFrozen reports whether the clock is frozen
+func (c *Clock) IsFrozen() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.frozen
+}
+
+// SetNextBlockTimestamp pins the timestamp (unix seconds) of the next block
+func (c *Clock) SetNextBlockTimestamp(ts int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextBlockTimestamp = ts
+}
+
+// GetNextBlockTimestamp returns the pinned next block timestamp (0 if unset)
+func (c *Clock) GetNextBlockTimestamp() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nextBlockTimestamp
+}
+
+// blockTimestamp returns the timestamp for a new block that must not be older
+// than minTimestamp. A pinned timestamp is consumed and the clock jumps to it,
+// so time keeps running from there.
+func (c *Clock) blockTimestamp(minTimestamp int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.nextBlockTimestamp != 0 {
+		ts := c.nextBlockTimestamp
+		c.nextBlockTimestamp = 0
+		c.setTime(time.Unix(ts, 0))
+		return ts
+	}
+
+	ts := c.now().Unix()
+	if ts < minTimestamp {
+		ts = minTimestamp
+	}
+	return ts
+}
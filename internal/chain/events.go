@@ -0,0 +1,67 @@
+package chain
+
+import (
+	"lfts/internal/utils"
+	"sync"
+)
+
+// Block event types
+const (
+	EventNewBlock     = "newBlock"
+	EventRemovedBlock = "removedBlock"
+)
+
+// subscriberBuffer is the number of events buffered per subscriber before
+// further events are dropped
+const subscriberBuffer = 256
+
+// BlockEvent reports a block added to or removed from the canonical chain
+type BlockEvent struct {
+	Type  string `json:"type"`
+	Block *Block `json:"block"`
+}
+
+// subscribers holds the channels of active block event subscriptions
+type subscribers struct {
+	mu     sync.Mutex
+	nextID int
+	chans  map[int]chan BlockEvent
+}
+
+// SubscribeBlocks returns a channel receiving block events and a function that
+// cancels the subscription. Slow subscribers miss events rather than stall the chain.
+func (c *Chain) SubscribeBlocks() (<-chan BlockEvent, func()) {
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+
+	if c.subs.chans == nil {
+		c.subs.chans = make(map[int]chan BlockEvent)
+	}
+	id := c.subs.nextID
+	c.subs.nextID++
+	ch := make(chan BlockEvent, subscriberBuffer)
+	c.subs.chans[id] = ch
+
+	cancel := func() {
+		c.subs.mu.Lock()
+		defer c.subs.mu.Unlock()
+		if ch, ok := c.subs.chans[id]; ok {
+			delete(c.subs.chans, id)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// publish delivers an event to every subscriber without blocking
+func (c *Chain) publish(event BlockEvent) {
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+	for id, ch := range c.subs.chans {
+		select {
+		case ch <- event:
+		default:
+			utils.Error("Block event subscriber %d is full, dropping %s #%d", id, event.Type, event.Block.Number)
+		}
+	}
+}
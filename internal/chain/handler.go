@@ -0,0 +1,401 @@
+package chain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lfts/internal/state"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaxBlocksPerRequest limits the number of blocks returned by /blocks
+const MaxBlocksPerRequest = 1000
+
+// ReadErrorStatus returns the HTTP status for a failed read at a block: unknown
+// blocks are 404, pruned state history is 410 and malformed references are 400
+func ReadErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrBlockNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, state.ErrHistoryPruned):
+		return http.StatusGone
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// HandleBlock handles GET /block/{number}
+func HandleBlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	ref := r.PathValue("number")
+	block, err := chainInstance.ResolveBlock(ref)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if block == nil {
+		http.Error(w, "Block not found: "+ref, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(block)
+}
+
+// HandleBlocks handles GET /blocks?from=<number>&to=<number>
+func HandleBlocks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Default to the full retained range
+	var from, to uint64
+	if earliest := chainInstance.GetEarliestBlock(); earliest != nil {
+		from = earliest.Number
+	}
+	to = chainInstance.GetHeight()
+
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		n, err := ParseBlockNumber(toStr)
+		if err != nil {
+			http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+			return
+		}
+		to = n
+	}
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		n, err := ParseBlockNumber(fromStr)
+		if err != nil {
+			http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+			return
+		}
+		from = n
+	} else if to >= MaxBlocksPerRequest && to-from >= MaxBlocksPerRequest {
+		// Without an explicit start, return the most recent page
+		from = to - MaxBlocksPerRequest + 1
+	}
+
+	if from > to {
+		http.Error(w, "from must not be greater than to", http.StatusBadRequest)
+		return
+	}
+
+	if to-from >= MaxBlocksPerRequest {
+		http.Error(w, "Block range too large", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chainInstance.GetBlocks(from, to))
+}
+
+// HandleVerify handles GET /chain/verify
+func HandleVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	checked, err := chainInstance.Verify()
+	response := map[string]interface{}{
+		"valid":   err == nil,
+		"checked": checked,
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleMine handles POST /chain/mine?blocks=<count>
+func HandleMine(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	count := 1
+	if countStr := r.URL.Query().Get("blocks"); countStr != "" {
+		n, err := strconv.Atoi(countStr)
+		if err != nil || n <= 0 || n > MaxBlocksPerRequest {
+			http.Error(w, "Invalid blocks parameter", http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chainInstance.Mine(count))
+}
+
+// HandleMining handles GET /chain/mining and POST /chain/mining?mode=<mode>&blockTime=<ms>
+func HandleMining(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		modeStr := r.URL.Query().Get("mode")
+		blockTimeStr := r.URL.Query().Get("blockTime")
+		if modeStr == "" && blockTimeStr == "" {
+			http.Error(w, "Missing mode or blockTime parameter", http.StatusBadRequest)
+			return
+		}
+
+		var mode MiningMode
+		if modeStr != "" {
+			parsed, err := ParseMiningMode(modeStr)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mode = parsed
+		}
+
+		if blockTimeStr != "" {
+			ms, err := strconv.Atoi(blockTimeStr)
+			if err != nil || ms <= 0 {
+				http.Error(w, "Invalid blockTime parameter", http.StatusBadRequest)
+				return
+			}
+			chainInstance.SetBlockTime(time.Duration(ms) * time.Millisecond)
+		}
+
+		if mode != "" {
+			chainInstance.SetMiningMode(mode)
+		}
+	}
+
+	response := map[string]interface{}{
+		"mode":      chainInstance.GetMiningMode(),
+		"blockTime": chainInstance.GetBlockTime().Milliseconds(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleControl handles POST /chain/pause, /chain/resume and /chain/restart
+func HandleControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	changed := true
+	switch r.URL.Path {
+	case "/chain/pause":
+		changed = chainInstance.Pause()
+	case "/chain/resume":
+		changed = chainInstance.Resume()
+	case "/chain/restart":
+		chainInstance.Restart()
+	default:
+		http.Error(w, "Unknown control action", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"running": chainInstance.IsRunning(),
+		"changed": changed,
+		"height":  chainInstance.GetHeight(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeTime encodes the chain clock state
+func writeTime(w http.ResponseWriter, chainInstance *Chain) {
+	clock := chainInstance.Clock()
+	response := map[string]interface{}{
+		"now":                clock.Now().Unix(),
+		"offset":             int64(clock.GetOffset().Round(time.Second).Seconds()),
+		"frozen":             clock.IsFrozen(),
+		"nextBlockTimestamp": clock.GetNextBlockTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleTime handles GET /time
+func HandleTime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	writeTime(w, chainInstance)
+}
+
+// HandleIncreaseTime handles POST /time/increase?seconds=<seconds>
+func HandleIncreaseTime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	seconds, err := strconv.ParseInt(r.URL.Query().Get("seconds"), 10, 64)
+	if err != nil || seconds < 0 {
+		http.Error(w, "Invalid seconds parameter", http.StatusBadRequest)
+		return
+	}
+
+	chainInstance.Clock().IncreaseTime(time.Duration(seconds) * time.Second)
+	writeTime(w, chainInstance)
+}
+
+// HandleNextBlockTimestamp handles POST /time/next-block?timestamp=<unix seconds>
+func HandleNextBlockTimestamp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	timestamp, err := strconv.ParseInt(r.URL.Query().Get("timestamp"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid timestamp parameter", http.StatusBadRequest)
+		return
+	}
+
+	if err := chainInstance.SetNextBlockTimestamp(timestamp); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeTime(w, chainInstance)
+}
+
+// HandleFreezeTime handles POST /time/freeze and POST /time/unfreeze
+func HandleFreezeTime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.URL.Path == "/time/unfreeze" {
+		chainInstance.Clock().Unfreeze()
+	} else {
+		chainInstance.Clock().Freeze()
+	}
+
+	writeTime(w, chainInstance)
+}
+
+// HandleEvents handles GET /chain/events, streaming block events as server-sent events
+func HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainInstance := GetInstance()
+	if chainInstance == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, cancel := chainInstance.SubscribeBlocks()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
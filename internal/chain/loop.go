@@ -5,28 +5,63 @@ import (
 	"time"
 )
 
-// StartLoop begins the block generation loop in a goroutine
-func StartLoop(chain *Chain) {
-	go func() {
-		ticker := time.NewTicker(chain.GetBlockTime())
-		defer ticker.Stop()
+// runLoop is the block generation loop started by Start. It ends when stop is
+// closed and then closes done.
+func (c *Chain) runLoop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(c.GetBlockTime())
+	defer ticker.Stop()
 
-		// Create genesis block immediately
-		block := chain.CreateBlock()
+	// Create the first block immediately on a fresh chain
+	if c.GetHeight() == 0 {
+		block := c.CreateBlock()
 		utils.LogBlock(block.Number, block.Timestamp)
+	}
 
-		for {
-			select {
-			case <-chain.GetStopChan():
-				utils.Info("Chain loop stopped")
-				return
-			case <-ticker.C:
-				if chain.IsRunning() {
-					block := chain.CreateBlock()
-					utils.LogBlock(block.Number, block.Timestamp)
-				}
+	for {
+		select {
+		case <-stop:
+			utils.Info("Chain loop stopped")
+			return
+		case <-c.GetConfigChan():
+			ticker.Reset(c.GetBlockTime())
+		case <-ticker.C:
+			if !c.IsRunning() {
+				continue
+			}
+			// Auto mode seals on writes, and on the ticker only when a block is due
+			mode := c.GetMiningMode()
+			if mode == MiningInterval || (mode == MiningAuto && c.blockDue()) {
+				block := c.CreateBlock()
+				utils.LogBlock(block.Number, block.Timestamp)
 			}
 		}
-	}()
+	}
+}
+
+// Pause stops block production. Writes keep accumulating in the pending block,
+// and blocks can still be mined on demand. It returns false if already paused.
+func (c *Chain) Pause() bool {
+	if !c.Stop() {
+		return false
+	}
+	utils.Info("Block production paused at height %d", c.GetHeight())
+	return true
 }
 
+// Resume restarts block production with a new loop. It returns false if the
+// chain is already running.
+func (c *Chain) Resume() bool {
+	if !c.Start() {
+		return false
+	}
+	utils.Info("Block production resumed at height %d", c.GetHeight())
+	return true
+}
+
+// Restart stops the current loop, if any, waits for it to exit and spawns a new one
+func (c *Chain) Restart() {
+	c.Stop()
+	c.Start()
+	utils.Info("Block production restarted at height %d", c.GetHeight())
+}
@@ -0,0 +1,51 @@
+package chain
+
+import (
+	"lfts/internal/state"
+	"testing"
+	"time"
+)
+
+func TestStopWaitsForLoop(t *testing.T) {
+	previous := state.GlobalState
+	state.GlobalState = state.NewMemoryStorage()
+	t.Cleanup(func() { state.GlobalState = previous })
+
+	c := NewChain(1)
+	c.Start()
+	for i := 0; i < 20; i++ {
+		time.Sleep(2 * time.Millisecond)
+		c.Restart()
+	}
+	if !c.Stop() {
+		t.Fatal("Stop() = false, want true")
+	}
+
+	// The stopped loop seals nothing more
+	height := c.GetHeight()
+	time.Sleep(20 * time.Millisecond)
+	if got := c.GetHeight(); got != height {
+		t.Errorf("height moved from %d to %d after Stop returned", height, got)
+	}
+	if _, err := c.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestStopRightAfterStart(t *testing.T) {
+	previous := state.GlobalState
+	state.GlobalState = state.NewMemoryStorage()
+	t.Cleanup(func() { state.GlobalState = previous })
+
+	// A fresh chain seals its first block as soon as the loop runs
+	for i := 0; i < 20; i++ {
+		c := NewChain(1000)
+		c.Start()
+		c.Stop()
+		height := c.GetHeight()
+		time.Sleep(time.Millisecond)
+		if got := c.GetHeight(); got != height {
+			t.Fatalf("height moved from %d to %d after Stop returned", height, got)
+		}
+	}
+}
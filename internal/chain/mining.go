@@ -0,0 +1,78 @@
+package chain
+
+import (
+	"fmt"
+	"time"
+)
+
+// MiningMode controls when the chain produces blocks
+type MiningMode string
+
+const (
+	// MiningInterval produces a block every block time
+	MiningInterval MiningMode = "interval"
+	// MiningAuto produces a block immediately after every state write
+	MiningAuto MiningMode = "auto"
+	// MiningManual produces blocks only when Mine is called
+	MiningManual MiningMode = "manual"
+)
+
+// ParseMiningMode validates a mining mode name
+func ParseMiningMode(s string) (MiningMode, error) {
+	switch MiningMode(s) {
+	case MiningInterval, MiningAuto, MiningManual:
+		return MiningMode(s), nil
+	default:
+		return "", fmt.Errorf("invalid mining mode %q (expected interval, auto or manual)", s)
+	}
+}
+
+// GetMiningMode returns the current mining mode
+func (c *Chain) GetMiningMode() MiningMode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.miningMode
+}
+
+// SetMiningMode switches the mining mode at runtime
+func (c *Chain) SetMiningMode(mode MiningMode) {
+	c.mu.Lock()
+	c.miningMode = mode
+	c.mu.Unlock()
+	c.notifyConfigChanged()
+}
+
+// SetBlockTime changes the block interval at runtime
+func (c *Chain) SetBlockTime(blockTime time.Duration) error {
+	if blockTime <= 0 {
+		return fmt.Errorf("block time must be positive")
+	}
+	c.mu.Lock()
+	c.blockTime = blockTime
+	c.mu.Unlock()
+	c.notifyConfigChanged()
+	return nil
+}
+
+// notifyConfigChanged wakes the chain loop so it picks up the new configuration
+func (c *Chain) notifyConfigChanged() {
+	select {
+	case c.configChanged <- struct{}{}:
+	default:
+	}
+}
+
+// GetConfigChan returns the channel signalled when mining configuration changes
+func (c *Chain) GetConfigChan() <-chan struct{} {
+	return c.configChanged
+}
+
+// Mine produces count blocks immediately, regardless of mining mode. The blocks
+// are sealed back to back, so no other block lands in between.
+func (c *Chain) Mine(count int) []*Block {
+	var blocks []*Block
+	c.Exclusive(func() {
+		blocks = c.SealBlocks(uint64(count))
+	})
+	return blocks
+}
@@ -0,0 +1,218 @@
+package chain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lfts/internal/utils"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// minBlockLogCompaction is the number of superseded records tolerated before
+// the block log is rewritten
+const minBlockLogCompaction = 1000
+
+// Block log record operations
+const (
+	blockOpAdd      = "add"
+	blockOpTruncate = "truncate"
+	blockOpReset    = "reset"
+)
+
+// blockLogRecord is one line of the block log
+type blockLogRecord struct {
+	Op     string   `json:"op"`
+	Block  *Block   `json:"block,omitempty"`
+	Number uint64   `json:"number,omitempty"`
+	Blocks []*Block `json:"blocks,omitempty"`
+}
+
+// blockLog is an append-only log of block store changes
+type blockLog struct {
+	path    string
+	file    *os.File
+	w       *bufio.Writer
+	records int
+}
+
+// append writes a record and syncs it to disk
+func (l *blockLog) append(record blockLogRecord) error {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := l.w.Write(append(encoded, '\n')); err != nil {
+		return err
+	}
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+	l.records++
+	return l.file.Sync()
+}
+
+// open opens the log file for appending
+func (l *blockLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open block log: %v", err)
+	}
+	l.file = f
+	l.w = bufio.NewWriter(f)
+	return nil
+}
+
+// compact rewrites the log as a single reset record holding the given blocks
+func (l *blockLog) compact(blocks []*Block) error {
+	encoded, err := json.Marshal(blockLogRecord{Op: blockOpReset, Blocks: blocks})
+	if err != nil {
+		return err
+	}
+
+	tmpPath := l.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(encoded, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		return err
+	}
+	if dir, err := os.Open(filepath.Dir(l.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	l.records = 1
+	return l.open()
+}
+
+// close flushes and closes the log
+func (l *blockLog) close() error {
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+	return l.file.Close()
+}
+
+// replayBlockLog applies every complete record of the log at path to store.
+// A torn record at the end of the log is discarded.
+func replayBlockLog(path string, store *BlockStore) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open block log: %v", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64
+	records := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return records, nil
+		}
+		if err != nil && err != io.EOF {
+			return records, fmt.Errorf("failed to read block log: %v", err)
+		}
+
+		var record blockLogRecord
+		if err == io.EOF || json.Unmarshal(bytes.TrimSpace(line), &record) != nil {
+			utils.Info("Discarding incomplete record at the end of %s", path)
+			if err := os.Truncate(path, offset); err != nil {
+				return records, fmt.Errorf("failed to truncate block log: %v", err)
+			}
+			return records, nil
+		}
+
+		switch record.Op {
+		case blockOpAdd:
+			store.Add(record.Block)
+		case blockOpTruncate:
+			store.Truncate(record.Number)
+		case blockOpReset:
+			store.Reset(record.Blocks)
+		default:
+			return records, fmt.Errorf("block log record %d: unknown operation %q", records+1, record.Op)
+		}
+		records++
+		offset += int64(len(line))
+	}
+}
+
+// OpenBlockLog loads the blocks persisted at path, resumes the chain at the
+// last persisted block and records all further block changes in the log. Call
+// it before the chain starts.
+func (c *Chain) OpenBlockLog(path string) error {
+	store := NewBlockStore(c.blocks.Retention())
+	records, err := replayBlockLog(path, store)
+	if err != nil {
+		return err
+	}
+
+	log := &blockLog{path: path, records: records}
+	if err := log.open(); err != nil {
+		return err
+	}
+	store.log = log
+
+	latest := store.Latest()
+	c.mu.Lock()
+	c.blocks = store
+	c.latestBlock = latest
+	c.currentHeight = 0
+	if latest != nil {
+		c.currentHeight = latest.Number
+	}
+	c.mu.Unlock()
+
+	if latest == nil {
+		return nil
+	}
+	if _, err := c.Verify(); err != nil {
+		return fmt.Errorf("persisted blocks are invalid: %v", err)
+	}
+
+	// Never produce blocks older than the persisted head
+	if latest.Timestamp > c.clock.Now().Unix() {
+		c.clock.SetTime(time.Unix(latest.Timestamp, 0))
+	}
+	return nil
+}
+
+// CloseBlockLog flushes and closes the block log, if any
+func (c *Chain) CloseBlockLog() error {
+	c.mu.RLock()
+	store := c.blocks
+	c.mu.RUnlock()
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.log == nil {
+		return nil
+	}
+	err := store.log.close()
+	store.log = nil
+	return err
+}
@@ -0,0 +1,143 @@
+package chain
+
+import "time"
+
+// Snapshot captures the chain head, retained blocks, pending body and clock
+type Snapshot struct {
+	height             uint64
+	latestBlock        *Block
+	blocks             []*Block
+	pending            map[string]*string
+	clockOffset        time.Duration
+	clockFrozen        bool
+	clockFrozenAt      time.Time
+	nextBlockTimestamp int64
+}
+
+// Height returns the chain height at the time of the snapshot
+func (s *Snapshot) Height() uint64 {
+	return s.height
+}
+
+// Exclusive runs fn while no block is being sealed and no state update is in
+// flight, so fn sees (and may replace) a consistent chain and state
+func (c *Chain) Exclusive(fn func()) {
+	c.sealMu.Lock()
+	defer c.sealMu.Unlock()
+	fn()
+}
+
+// Snapshot captures the chain. Call it from within Exclusive to pair it with a
+// consistent copy of the state.
+func (c *Chain) Snapshot() *Snapshot {
+	c.mu.RLock()
+	snap := &Snapshot{
+		height:      c.currentHeight,
+		latestBlock: c.latestBlock,
+		pending:     make(map[string]*string, len(c.pending.StateUpdates)),
+	}
+	for k, v := range c.pending.StateUpdates {
+		snap.pending[k] = v
+	}
+	c.mu.RUnlock()
+
+	// Blocks are immutable once sealed, so sharing pointers is safe
+	snap.blocks = c.blocks.GetRange(0, snap.height)
+
+	c.clock.mu.Lock()
+	snap.clockOffset = c.clock.offset
+	snap.clockFrozen = c.clock.frozen
+	snap.clockFrozenAt = c.clock.frozenAt
+	snap.nextBlockTimestamp = c.clock.nextBlockTimestamp
+	c.clock.mu.Unlock()
+
+	return snap
+}
+
+// Restore rolls the chain back to a snapshot. Subscribers receive removedBlock
+// events for the blocks dropped and newBlock events for the blocks brought back.
+// Call it from within Exclusive.
+func (c *Chain) Restore(snap *Snapshot) {
+	current := c.blocks.GetRange(0, c.GetHeight())
+	c.blocks.Reset(snap.blocks)
+
+	c.mu.Lock()
+	c.currentHeight = snap.height
+	c.latestBlock = snap.latestBlock
+	c.pending = BlockData{StateUpdates: make(map[string]*string, len(snap.pending))}
+	for k, v := range snap.pending {
+		c.pending.StateUpdates[k] = v
+	}
+	c.mu.Unlock()
+
+	c.clock.mu.Lock()
+	c.clock.offset = snap.clockOffset
+	c.clock.frozen = snap.clockFrozen
+	c.clock.frozenAt = snap.clockFrozenAt
+	c.clock.nextBlockTimestamp = snap.nextBlockTimestamp
+	c.clock.mu.Unlock()
+
+	removed, added := blockDiff(current, snap.blocks)
+	for i := len(removed) - 1; i >= 0; i-- {
+		c.publish(BlockEvent{Type: EventRemovedBlock, Block: removed[i]})
+	}
+	for _, block := range added {
+		c.publish(BlockEvent{Type: EventNewBlock, Block: block})
+	}
+}
+
+// blockDiff returns the blocks of from that are not in to and the blocks of to
+// that are not in from, both oldest first
+func blockDiff(from, to []*Block) (removed, added []*Block) {
+	hashes := make(map[Hash]bool, len(from))
+	for _, block := range from {
+		hashes[block.Hash] = true
+	}
+	for _, block := range to {
+		if !hashes[block.Hash] {
+			added = append(added, block)
+		}
+		delete(hashes, block.Hash)
+	}
+	for _, block := range from {
+		if hashes[block.Hash] {
+			removed = append(removed, block)
+		}
+	}
+	return removed, added
+}
+
+// Import replaces the chain with the given blocks (oldest first, ending at the
+// new head), dropping the pending body. The clock is moved forward if it is
+// behind the head. Call it from within Exclusive.
+func (c *Chain) Import(blocks []*Block) error {
+	for i, block := range blocks {
+		var parent *Block
+		if i > 0 {
+			parent = blocks[i-1]
+		}
+		if err := block.Verify(parent); err != nil {
+			return err
+		}
+	}
+
+	var latest *Block
+	var height uint64
+	if n := len(blocks); n > 0 {
+		latest = blocks[n-1]
+		height = latest.Number
+	}
+
+	c.blocks.Reset(blocks)
+
+	c.mu.Lock()
+	c.currentHeight = height
+	c.latestBlock = latest
+	c.pending = BlockData{StateUpdates: make(map[string]*string)}
+	c.mu.Unlock()
+
+	if latest != nil && latest.Timestamp > c.clock.Now().Unix() {
+		c.clock.SetTime(time.Unix(latest.Timestamp, 0))
+	}
+	return nil
+}
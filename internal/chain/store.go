@@ -0,0 +1,191 @@
+package chain
+
+import (
+	"lfts/internal/utils"
+	"sort"
+	"sync"
+)
+
+// BlockStore keeps produced blocks indexed by number and hash
+type BlockStore struct {
+	mu        sync.RWMutex
+	byNumber  map[uint64]*Block
+	byHash    map[Hash]*Block
+	numbers   []uint64  // ascending, oldest first
+	retention uint64    // 0 keeps every block
+	log       *blockLog // optional persistence (see Chain.OpenBlockLog)
+}
+
+// NewBlockStore creates a block store that keeps the last retention blocks (0 = unlimited)
+func NewBlockStore(retention uint64) *BlockStore {
+	return &BlockStore{
+		byNumber:  make(map[uint64]*Block),
+		byHash:    make(map[Hash]*Block),
+		numbers:   []uint64{},
+		retention: retention,
+	}
+}
+
+// Add stores a block and prunes blocks that fall outside the retention window
+func (s *BlockStore) Add(block *Block) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, exists := s.byNumber[block.Number]; exists {
+		delete(s.byHash, existing.Hash)
+	} else {
+		s.numbers = append(s.numbers, block.Number)
+	}
+	s.byNumber[block.Number] = block
+	s.byHash[block.Hash] = block
+	s.prune()
+	s.persist(blockLogRecord{Op: blockOpAdd, Block: block})
+}
+
+// prune drops the oldest blocks beyond the retention window (caller holds the lock)
+func (s *BlockStore) prune() {
+	if s.retention == 0 || uint64(len(s.numbers)) <= s.retention {
+		return
+	}
+
+	excess := uint64(len(s.numbers)) - s.retention
+	for _, number := range s.numbers[:excess] {
+		delete(s.byHash, s.byNumber[number].Hash)
+		delete(s.byNumber, number)
+	}
+	s.numbers = append([]uint64{}, s.numbers[excess:]...)
+}
+
+// GetByNumber returns the block with the given number, or nil if unknown or pruned
+func (s *BlockStore) GetByNumber(number uint64) *Block {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.byNumber[number]
+}
+
+// GetByHash returns the block with the given hash, or nil if unknown or pruned
+func (s *BlockStore) GetByHash(hash Hash) *Block {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.byHash[hash]
+}
+
+// GetRange returns the retained blocks with from <= number <= to in ascending order
+func (s *BlockStore) GetRange(from, to uint64) []*Block {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blocks := []*Block{}
+	if from > to {
+		return blocks
+	}
+
+	start := sort.Search(len(s.numbers), func(i int) bool {
+		return s.numbers[i] >= from
+	})
+	for _, number := range s.numbers[start:] {
+		if number > to {
+			break
+		}
+		blocks = append(blocks, s.byNumber[number])
+	}
+	return blocks
+}
+
+// Earliest returns the oldest retained block
+func (s *BlockStore) Earliest() *Block {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.numbers) == 0 {
+		return nil
+	}
+	return s.byNumber[s.numbers[0]]
+}
+
+// Latest returns the newest retained block
+func (s *BlockStore) Latest() *Block {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.numbers) == 0 {
+		return nil
+	}
+	return s.byNumber[s.numbers[len(s.numbers)-1]]
+}
+
+// Len returns the number of retained blocks
+func (s *BlockStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.numbers)
+}
+
+// Retention returns the configured retention window (0 = unlimited)
+func (s *BlockStore) Retention() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.retention
+}
+
+// SetRetention changes the retention window and prunes immediately
+func (s *BlockStore) SetRetention(retention uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = retention
+	s.prune()
+}
+
+// Reset replaces the retained blocks with the given ones (ascending by number)
+func (s *BlockStore) Reset(blocks []*Block) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.byNumber = make(map[uint64]*Block, len(blocks))
+	s.byHash = make(map[Hash]*Block, len(blocks))
+	s.numbers = make([]uint64, 0, len(blocks))
+	for _, block := range blocks {
+		s.byNumber[block.Number] = block
+		s.byHash[block.Hash] = block
+		s.numbers = append(s.numbers, block.Number)
+	}
+	s.prune()
+	s.persist(blockLogRecord{Op: blockOpReset, Blocks: blocks})
+}
+
+// Truncate removes all blocks with a number greater than the given one
+func (s *BlockStore) Truncate(number uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keep := sort.Search(len(s.numbers), func(i int) bool {
+		return s.numbers[i] > number
+	})
+	for _, n := range s.numbers[keep:] {
+		delete(s.byHash, s.byNumber[n].Hash)
+		delete(s.byNumber, n)
+	}
+	s.numbers = s.numbers[:keep]
+	s.persist(blockLogRecord{Op: blockOpTruncate, Number: number})
+}
+
+// persist appends a change to the block log, compacting the log once most of
+// its records are superseded (caller holds the lock)
+func (s *BlockStore) persist(record blockLogRecord) {
+	if s.log == nil {
+		return
+	}
+	if err := s.log.append(record); err != nil {
+		utils.Error("Block log write failed: %v", err)
+		return
+	}
+
+	if s.log.records < 2*len(s.numbers)+minBlockLogCompaction {
+		return
+	}
+	blocks := make([]*Block, 0, len(s.numbers))
+	for _, number := range s.numbers {
+		blocks = append(blocks, s.byNumber[number])
+	}
+	if err := s.log.compact(blocks); err != nil {
+		utils.Error("Block log compaction failed: %v", err)
+	}
+}
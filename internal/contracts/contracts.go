@@ -4,15 +4,18 @@ import (
 	"encoding/hex"
 	"fmt"
 	"lfts/internal/ftso"
+	"lfts/internal/network"
 	"math/big"
 	"strings"
+	"sync"
 )
 
 // ContractCall represents a contract function call
 type ContractCall struct {
-	To     string `json:"to"`     // Contract address
-	Data   string `json:"data"`   // Function call data (hex encoded)
-	Method string `json:"method"` // Function name (for logging)
+	To     string  `json:"to"`     // Contract address
+	Data   string  `json:"data"`   // Function call data (hex encoded)
+	Method string  `json:"method"` // Function name (for logging)
+	Block  *uint64 `json:"-"`      // Block whose state is read (nil reads the current state)
 }
 
 // ContractResponse represents the response from a contract call
@@ -21,23 +24,35 @@ type ContractResponse struct {
 	Error  string `json:"error,omitempty"`
 }
 
-// Mock contract addresses
+// Mock contract addresses (returned by the FlareContractRegistry as FtsoV2 and
+// FdcVerification unless the network profile gives them other addresses)
 const (
-	FTSOContractAddress = "0x0000000000000000000000000000000000000001"
-	FDCContractAddress  = "0x0000000000000000000000000000000000000002"
+	FTSOContractAddress = network.MockFtsoV2Address
+	FDCContractAddress  = network.MockFdcVerificationAddress
 )
 
 // HandleContractCall simulates a contract call
 func HandleContractCall(call ContractCall) (*ContractResponse, error) {
-	// Route to appropriate contract handler
-	switch strings.ToLower(call.To) {
-	case FTSOContractAddress:
+	profile := network.Active()
+	if strings.EqualFold(call.To, profile.Registry) {
+		return handleRegistryCall(call)
+	}
+
+	// Route by the registry name the profile gives the address
+	name, ok := profile.ContractName(call.To)
+	if !ok {
+		return &ContractResponse{
+			Error: "Unknown contract address",
+		}, nil
+	}
+	switch name {
+	case network.ContractFtsoV2:
 		return handleFTSOCall(call)
-	case FDCContractAddress:
+	case network.ContractFdcVerification:
 		return handleFDCCall(call)
 	default:
 		return &ContractResponse{
-			Error: "Unknown contract address",
+			Error: "The sandbox does not implement " + name,
 		}, nil
 	}
 }
@@ -49,7 +64,7 @@ func handleFTSOCall(call ContractCall) (*ContractResponse, error) {
 		return &ContractResponse{Error: "Invalid call data"}, nil
 	}
 
-	selector := call.Data[:10] // 0x + 4 bytes
+	selector := strings.ToLower(call.Data[:10]) // 0x + 4 bytes
 
 	// Function selectors (first 4 bytes of keccak256(function signature))
 	// getCurrentPrice(address) = 0x893d20e8
@@ -57,8 +72,10 @@ func handleFTSOCall(call ContractCall) (*ContractResponse, error) {
 	// getPriceAt(address,uint256) = 0x... (placeholder)
 
 	switch selector {
+	case selectorGetFeedByID: // getFeedById(bytes21)
+		return handleGetFeedByID(call)
 	case "0x893d20e8": // getCurrentPrice(address)
-		return handleGetCurrentPrice(call.Data)
+		return handleGetCurrentPrice(call)
 	case "0x4b750334": // getPrice(address,uint256)
 		return handleGetPrice(call.Data)
 	default:
@@ -66,8 +83,60 @@ func handleFTSOCall(call ContractCall) (*ContractResponse, error) {
 	}
 }
 
+// selectorGetFeedByID is the selector of FtsoV2 getFeedById(bytes21)
+var selectorGetFeedByID = selector("getFeedById(bytes21)")
+
+// handleGetFeedByID implements getFeedById(bytes21 feedId) returns
+// (uint256 value, int8 decimals, uint64 timestamp) for any registered feed
+func handleGetFeedByID(call ContractCall) (*ContractResponse, error) {
+	// bytes21 is left-aligned in its 32-byte word
+	if len(call.Data) < 10+64 {
+		return &ContractResponse{Error: "Invalid call data"}, nil
+	}
+	feed, err := ftso.LookupFeed("0x" + call.Data[10:10+42])
+	if err != nil {
+		return &ContractResponse{Error: err.Error()}, nil
+	}
+
+	var price *ftso.FTSOPrice
+	if call.Block != nil {
+		price, err = ftso.GetPriceAtBlock(feed.ID.String(), *call.Block)
+	} else {
+		price, err = ftso.GetPrice(feed.ID.String())
+	}
+	if err != nil {
+		return &ContractResponse{Error: err.Error()}, nil
+	}
+	if price == nil {
+		return &ContractResponse{Error: "Feed not found: " + feed.Name}, nil
+	}
+
+	if price.Value.BitLen() > 256 {
+		return &ContractResponse{Error: "Value does not fit in uint256"}, nil
+	}
+	return &ContractResponse{
+		Result: "0x" + encodeUint(price.Value) + encodeInt(int64(price.Decimals)) + encodeUint(big.NewInt(price.Timestamp)),
+	}, nil
+}
+
+// encodeUint ABI-encodes an unsigned integer as a 32-byte word (hex, without 0x)
+func encodeUint(n *big.Int) string {
+	return fmt.Sprintf("%064s", n.Text(16))
+}
+
+// encodeInt ABI-encodes a signed integer as a two's complement 32-byte word (hex, without 0x)
+func encodeInt(n int64) string {
+	v := big.NewInt(n)
+	if n < 0 {
+		v.Add(v, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return encodeUint(v)
+}
+
 // handleGetCurrentPrice implements getCurrentPrice(address asset) returns (uint256 price, uint256 timestamp)
-func handleGetCurrentPrice(data string) (*ContractResponse, error) {
+func handleGetCurrentPrice(call ContractCall) (*ContractResponse, error) {
+	data := call.Data
+
 	// Extract asset address from data (skip 0x and selector)
 	if len(data) < 74 { // 0x + 4 bytes selector + 32 bytes address
 		return &ContractResponse{Error: "Invalid call data"}, nil
@@ -80,7 +149,13 @@ func handleGetCurrentPrice(data string) (*ContractResponse, error) {
 	// Map common addresses to asset symbols (simplified)
 	asset := addressToAsset(assetHex)
 
-	price, err := ftso.GetPrice(asset)
+	var price *ftso.FTSOPrice
+	var err error
+	if call.Block != nil {
+		price, err = ftso.GetPriceAtBlock(asset, *call.Block)
+	} else {
+		price, err = ftso.GetPrice(asset)
+	}
 	if err != nil {
 		return &ContractResponse{Error: err.Error()}, nil
 	}
@@ -90,7 +165,7 @@ func handleGetCurrentPrice(data string) (*ContractResponse, error) {
 	}
 
 	// Encode return values: (uint256 price, uint256 timestamp)
-	priceBig := big.NewInt(int64(price.Price * 1e8)) // Scale to 8 decimals
+	priceBig := ftso.ScaleValue(price.Value, price.Decimals, 8) // Scale to 8 decimals
 	timestampBig := big.NewInt(price.Timestamp)
 
 	// Pack: 32 bytes price + 32 bytes timestamp
@@ -123,16 +198,36 @@ func handleFDCCall(call ContractCall) (*ContractResponse, error) {
 	}
 }
 
-// addressToAsset maps contract addresses to asset symbols (simplified)
-func addressToAsset(addressHex string) string {
-	// Common asset addresses (simplified mapping)
-	// In real implementation, this would be a proper mapping
-	addressMap := map[string]string{
+var (
+	// assetAddressesMu guards assetAddresses
+	assetAddressesMu sync.RWMutex
+
+	// assetAddresses maps asset addresses (40 lowercase hex chars) to asset symbols
+	assetAddresses = map[string]string{
 		"0000000000000000000000000000000000000001": "BTC",
 		"0000000000000000000000000000000000000002": "ETH",
 		"0000000000000000000000000000000000000003": "XRP",
 	}
+)
+
+// RegisterAssetAddress maps an asset address to an asset symbol for contract calls
+func RegisterAssetAddress(address, asset string) error {
+	normalized := strings.ToLower(strings.TrimPrefix(address, "0x"))
+	if len(normalized) != 40 {
+		return fmt.Errorf("invalid asset address: %s", address)
+	}
+	if _, err := hex.DecodeString(normalized); err != nil {
+		return fmt.Errorf("invalid asset address: %s", address)
+	}
 
+	assetAddressesMu.Lock()
+	defer assetAddressesMu.Unlock()
+	assetAddresses[normalized] = asset
+	return nil
+}
+
+// addressToAsset maps contract addresses to asset symbols (simplified)
+func addressToAsset(addressHex string) string {
 	// Normalize address (remove leading zeros, lowercase)
 	normalized := strings.ToLower(strings.TrimPrefix(addressHex, "0x"))
 	normalized = strings.TrimLeft(normalized, "0")
@@ -140,12 +235,19 @@ func addressToAsset(addressHex string) string {
 		normalized = "0"
 	}
 
-	// Try to find in map
-	for addr, asset := range addressMap {
+	// Try to find in map, preferring an exact match
+	assetAddressesMu.RLock()
+	if asset, ok := assetAddresses[fmt.Sprintf("%040s", normalized)]; ok {
+		assetAddressesMu.RUnlock()
+		return asset
+	}
+	for addr, asset := range assetAddresses {
 		if strings.HasSuffix(normalized, strings.TrimLeft(addr, "0")) {
+			assetAddressesMu.RUnlock()
 			return asset
 		}
 	}
+	assetAddressesMu.RUnlock()
 
 	// Default: use last 4 chars as asset code
 	if len(normalized) >= 4 {
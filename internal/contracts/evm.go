@@ -0,0 +1,293 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/proof"
+	"lfts/internal/reorg"
+	"lfts/internal/snapshot"
+	"math"
+	"time"
+)
+
+// Development methods compatible with Hardhat/Anvil (evm_*)
+
+// invalidParams builds a JSON-RPC invalid params error
+func invalidParams(message string) *RPCError {
+	return &RPCError{Code: -32602, Message: message}
+}
+
+// chainUnavailable is returned when no chain runs in this process
+var chainUnavailable = &RPCError{Code: -32000, Message: "Chain not initialized"}
+
+// handleEvmMine processes evm_mine([timestamp]): mines a single block, optionally
+// with the given timestamp, and returns "0x0"
+func handleEvmMine(params json.RawMessage) (interface{}, *RPCError) {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return nil, chainUnavailable
+	}
+
+	var args []interface{}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, invalidParams("expected [timestamp]")
+		}
+	}
+	var timestamp int64
+	if len(args) > 0 && args[0] != nil {
+		ts, err := parseQuantity(args[0])
+		if err != nil {
+			return nil, invalidParams(err.Error())
+		}
+		timestamp = int64(ts)
+	}
+
+	// Set the timestamp and seal together, so no other block can take it
+	var rpcErr *RPCError
+	chainInstance.Exclusive(func() {
+		if timestamp != 0 {
+			if err := chainInstance.SetNextBlockTimestamp(timestamp); err != nil {
+				rpcErr = invalidParams(err.Error())
+				return
+			}
+		}
+		chainInstance.SealBlocks(1)
+	})
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	return "0x0", nil
+}
+
+// handleHardhatMine processes hardhat_mine([count], [interval]): mines count
+// blocks (default 1). With an interval, every block after the first is stamped
+// interval seconds after the previous one.
+func handleHardhatMine(params json.RawMessage) (interface{}, *RPCError) {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return nil, chainUnavailable
+	}
+
+	count := uint64(1)
+	var args []interface{}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, invalidParams("expected [count, interval]")
+		}
+	}
+	if len(args) > 0 && args[0] != nil {
+		n, err := parseQuantity(args[0])
+		if err != nil || n == 0 || n > chain.MaxBlocksPerRequest {
+			return nil, invalidParams("invalid block count")
+		}
+		count = n
+	}
+	var interval int64
+	spaced := len(args) > 1 && args[1] != nil
+	if spaced {
+		n, err := parseQuantity(args[1])
+		if err != nil || n > math.MaxInt32 {
+			return nil, invalidParams("invalid interval")
+		}
+		interval = int64(n)
+	}
+
+	chainInstance.Exclusive(func() {
+		if !spaced {
+			chainInstance.SealBlocks(count)
+			return
+		}
+		previous := chainInstance.SealBlocks(1)[0]
+		for i := uint64(1); i < count; i++ {
+			// Never older than the previous block, so this cannot fail
+			chainInstance.SetNextBlockTimestamp(previous.Timestamp + interval)
+			previous = chainInstance.SealBlocks(1)[0]
+		}
+	})
+	return true, nil
+}
+
+// handleEvmSetAutomine processes evm_setAutomine(bool): true switches to auto
+// mining, false to manual mining
+func handleEvmSetAutomine(params json.RawMessage) (interface{}, *RPCError) {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return nil, chainUnavailable
+	}
+
+	var args []bool
+	if err := json.Unmarshal(params, &args); err != nil || len(args) < 1 {
+		return nil, invalidParams("expected [enabled]")
+	}
+
+	if args[0] {
+		chainInstance.SetMiningMode(chain.MiningAuto)
+	} else {
+		chainInstance.SetMiningMode(chain.MiningManual)
+	}
+	return true, nil
+}
+
+// handleEvmSetIntervalMining processes evm_setIntervalMining(ms): a positive
+// interval switches to interval mining with that block time, 0 to manual mining
+func handleEvmSetIntervalMining(params json.RawMessage) (interface{}, *RPCError) {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return nil, chainUnavailable
+	}
+
+	var args []int64
+	if err := json.Unmarshal(params, &args); err != nil || len(args) < 1 || args[0] < 0 {
+		return nil, invalidParams("expected [intervalMs]")
+	}
+
+	if args[0] == 0 {
+		chainInstance.SetMiningMode(chain.MiningManual)
+		return true, nil
+	}
+
+	if err := chainInstance.SetBlockTime(time.Duration(args[0]) * time.Millisecond); err != nil {
+		return nil, invalidParams(err.Error())
+	}
+	chainInstance.SetMiningMode(chain.MiningInterval)
+	return true, nil
+}
+
+// parseQuantity parses a JSON-RPC quantity given as hex string or number
+func parseQuantity(value interface{}) (uint64, error) {
+	switch v := value.(type) {
+	case string:
+		return chain.ParseBlockNumber(v)
+	case float64:
+		if v < 0 {
+			return 0, fmt.Errorf("negative quantity: %v", v)
+		}
+		return uint64(v), nil
+	default:
+		return 0, fmt.Errorf("invalid quantity: %v", value)
+	}
+}
+
+// handleEvmIncreaseTime processes evm_increaseTime(seconds) and returns the total
+// clock offset in seconds
+func handleEvmIncreaseTime(params json.RawMessage) (interface{}, *RPCError) {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return nil, chainUnavailable
+	}
+
+	var args []interface{}
+	if err := json.Unmarshal(params, &args); err != nil || len(args) < 1 {
+		return nil, invalidParams("expected [seconds]")
+	}
+	seconds, err := parseQuantity(args[0])
+	if err != nil {
+		return nil, invalidParams(err.Error())
+	}
+
+	clock := chainInstance.Clock()
+	clock.IncreaseTime(time.Duration(seconds) * time.Second)
+	return int64(clock.GetOffset().Round(time.Second).Seconds()), nil
+}
+
+// handleEvmSetNextBlockTimestamp processes evm_setNextBlockTimestamp(timestamp)
+// and echoes the timestamp
+func handleEvmSetNextBlockTimestamp(params json.RawMessage) (interface{}, *RPCError) {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return nil, chainUnavailable
+	}
+
+	var args []interface{}
+	if err := json.Unmarshal(params, &args); err != nil || len(args) < 1 {
+		return nil, invalidParams("expected [timestamp]")
+	}
+	ts, err := parseQuantity(args[0])
+	if err != nil {
+		return nil, invalidParams(err.Error())
+	}
+
+	if err := chainInstance.SetNextBlockTimestamp(int64(ts)); err != nil {
+		return nil, invalidParams(err.Error())
+	}
+	return fmt.Sprintf("0x%x", ts), nil
+}
+
+// handleFreezeTime processes lfts_freezeTime and lfts_unfreezeTime and returns
+// the current chain time
+func handleFreezeTime(freeze bool) (interface{}, *RPCError) {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return nil, chainUnavailable
+	}
+
+	if freeze {
+		chainInstance.Clock().Freeze()
+	} else {
+		chainInstance.Clock().Unfreeze()
+	}
+	return fmt.Sprintf("0x%x", chainInstance.Clock().Now().Unix()), nil
+}
+
+// handleEvmSnapshot processes evm_snapshot and returns the snapshot ID
+func handleEvmSnapshot() (interface{}, *RPCError) {
+	id, err := snapshot.Take()
+	if err != nil {
+		return nil, &RPCError{Code: -32000, Message: err.Error()}
+	}
+	return fmt.Sprintf("0x%x", id), nil
+}
+
+// handleEvmRevert processes evm_revert(id) and reports whether the snapshot existed
+func handleEvmRevert(params json.RawMessage) (interface{}, *RPCError) {
+	var args []interface{}
+	if err := json.Unmarshal(params, &args); err != nil || len(args) < 1 {
+		return nil, invalidParams("expected [snapshotId]")
+	}
+	id, err := parseQuantity(args[0])
+	if err != nil {
+		return nil, invalidParams(err.Error())
+	}
+
+	return snapshot.Revert(id) == nil, nil
+}
+
+// handleLftsReorg processes lfts_reorg({depth, blocks, updates}) and returns the
+// removed and added blocks
+func handleLftsReorg(params json.RawMessage) (interface{}, *RPCError) {
+	var args []reorg.Request
+	if err := json.Unmarshal(params, &args); err != nil || len(args) < 1 {
+		return nil, invalidParams("expected [{depth, blocks, updates}]")
+	}
+
+	result, err := reorg.Reorg(args[0])
+	if err != nil {
+		return nil, &RPCError{Code: -32000, Message: err.Error()}
+	}
+	return result, nil
+}
+
+// handleLftsGetProof processes lfts_getProof(key, [block]) and returns an
+// inclusion proof for the key against the block's state root
+func handleLftsGetProof(params json.RawMessage) (interface{}, *RPCError) {
+	if chain.GetInstance() == nil {
+		return nil, chainUnavailable
+	}
+
+	var args []string
+	if err := json.Unmarshal(params, &args); err != nil || len(args) < 1 || args[0] == "" {
+		return nil, invalidParams("expected [key, block]")
+	}
+	ref := "latest"
+	if len(args) > 1 {
+		ref = args[1]
+	}
+
+	result, err := proof.Get(args[0], ref)
+	if err != nil {
+		return nil, &RPCError{Code: -32000, Message: err.Error()}
+	}
+	return result, nil
+}
@@ -0,0 +1,70 @@
+package contracts
+
+import (
+	"encoding/json"
+	"lfts/internal/chain"
+	"lfts/internal/state"
+	"testing"
+)
+
+// useChain installs a fresh chain over an in-memory state for the test
+func useChain(t *testing.T) *chain.Chain {
+	t.Helper()
+	previous := state.GlobalState
+	state.GlobalState = state.NewMemoryStorage()
+	c := chain.NewChain(1000)
+	chain.SetInstance(c)
+	t.Cleanup(func() {
+		chain.SetInstance(nil)
+		state.GlobalState = previous
+	})
+	return c
+}
+
+func TestEvmMineTimestamp(t *testing.T) {
+	c := useChain(t)
+	ts := c.Clock().Now().Unix() + 3600
+
+	raw, _ := json.Marshal([]interface{}{ts})
+	if _, rpcErr := handleEvmMine(raw); rpcErr != nil {
+		t.Fatalf("handleEvmMine() error = %v", rpcErr.Message)
+	}
+	if got := c.GetLatestBlock(); got == nil || got.Number != 1 || got.Timestamp != ts {
+		t.Fatalf("latest block = %+v, want block 1 at %d", got, ts)
+	}
+
+	// A timestamp before the latest block is rejected without sealing
+	raw, _ = json.Marshal([]interface{}{ts - 1})
+	if _, rpcErr := handleEvmMine(raw); rpcErr == nil {
+		t.Fatal("handleEvmMine() accepted a timestamp older than the latest block")
+	}
+	if got := c.GetHeight(); got != 1 {
+		t.Errorf("height = %d after a rejected evm_mine, want 1", got)
+	}
+}
+
+func TestHardhatMineInterval(t *testing.T) {
+	c := useChain(t)
+
+	raw, _ := json.Marshal([]interface{}{"0x3", "0x3c"})
+	if _, rpcErr := handleHardhatMine(raw); rpcErr != nil {
+		t.Fatalf("handleHardhatMine() error = %v", rpcErr.Message)
+	}
+	blocks := c.GetBlocks(1, 3)
+	if len(blocks) != 3 {
+		t.Fatalf("got %d blocks, want 3", len(blocks))
+	}
+	for i := 1; i < len(blocks); i++ {
+		if got := blocks[i].Timestamp - blocks[i-1].Timestamp; got != 60 {
+			t.Errorf("block %d is %ds after its parent, want 60s", blocks[i].Number, got)
+		}
+	}
+
+	raw, _ = json.Marshal([]interface{}{"0x1", "nope"})
+	if _, rpcErr := handleHardhatMine(raw); rpcErr == nil {
+		t.Error("handleHardhatMine() accepted an invalid interval")
+	}
+	if got := c.GetHeight(); got != 3 {
+		t.Errorf("height = %d after a rejected hardhat_mine, want 3", got)
+	}
+}
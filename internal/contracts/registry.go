@@ -0,0 +1,91 @@
+package contracts
+
+import (
+	"encoding/hex"
+	"fmt"
+	"lfts/internal/network"
+	"lfts/internal/utils"
+	"math/big"
+	"strings"
+)
+
+// Function selectors of the FlareContractRegistry
+var (
+	selectorGetContractAddressByName = selector("getContractAddressByName(string)")
+	selectorGetContractAddressByHash = selector("getContractAddressByHash(bytes32)")
+)
+
+// selector returns the 0x-prefixed 4-byte function selector of a signature
+func selector(signature string) string {
+	hash := utils.Keccak256([]byte(signature))
+	return "0x" + hex.EncodeToString(hash[:4])
+}
+
+// contractNameHash returns keccak256(abi.encode(name)), the key used by the
+// periphery ContractRegistry library
+func contractNameHash(name string) string {
+	padded := make([]byte, (len(name)+31)/32*32)
+	copy(padded, name)
+	encoded := fmt.Sprintf("%064x%064x%s", 32, len(name), hex.EncodeToString(padded))
+	raw, _ := hex.DecodeString(encoded)
+	hash := utils.Keccak256(raw)
+	return hex.EncodeToString(hash[:])
+}
+
+// encodeAddress ABI-encodes an address as a 32-byte word
+func encodeAddress(address string) string {
+	return "0x" + fmt.Sprintf("%064s", strings.ToLower(strings.TrimPrefix(address, "0x")))
+}
+
+// handleRegistryCall handles calls to the FlareContractRegistry. Unknown names
+// resolve to the zero address, as on the real registry.
+func handleRegistryCall(call ContractCall) (*ContractResponse, error) {
+	if len(call.Data) < 10 {
+		return &ContractResponse{Error: "Invalid call data"}, nil
+	}
+
+	contracts := network.Active().Contracts
+	args := strings.ToLower(call.Data[10:])
+
+	switch strings.ToLower(call.Data[:10]) {
+	case selectorGetContractAddressByName:
+		name, err := decodeString(args)
+		if err != nil {
+			return &ContractResponse{Error: err.Error()}, nil
+		}
+		return &ContractResponse{Result: encodeAddress(contracts[name])}, nil
+	case selectorGetContractAddressByHash:
+		if len(args) < 64 {
+			return &ContractResponse{Error: "Invalid call data"}, nil
+		}
+		for name, address := range contracts {
+			if contractNameHash(name) == args[:64] {
+				return &ContractResponse{Result: encodeAddress(address)}, nil
+			}
+		}
+		return &ContractResponse{Result: encodeAddress("")}, nil
+	default:
+		return &ContractResponse{Error: "Unknown function selector"}, nil
+	}
+}
+
+// decodeString decodes a single ABI-encoded string argument (hex, without selector)
+func decodeString(args string) (string, error) {
+	raw, err := hex.DecodeString(args)
+	if err != nil || len(raw) < 32 {
+		return "", fmt.Errorf("invalid string argument")
+	}
+
+	// Compare against what is left instead of adding, which could wrap around
+	offset := new(big.Int).SetBytes(raw[:32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(raw))-32 {
+		return "", fmt.Errorf("invalid string argument")
+	}
+	start := offset.Uint64()
+
+	length := new(big.Int).SetBytes(raw[start : start+32])
+	if !length.IsUint64() || length.Uint64() > uint64(len(raw))-start-32 {
+		return "", fmt.Errorf("invalid string argument")
+	}
+	return string(raw[start+32 : start+32+length.Uint64()]), nil
+}
@@ -0,0 +1,121 @@
+package contracts
+
+import (
+	"encoding/hex"
+	"fmt"
+	"lfts/internal/network"
+	"strings"
+	"testing"
+)
+
+// word returns a 32-byte ABI word holding n
+func word(n uint64) string {
+	return fmt.Sprintf("%064x", n)
+}
+
+func TestDecodeString(t *testing.T) {
+	ftso := hex.EncodeToString([]byte("FtsoV2"))
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		wantErr bool
+	}{
+		{"valid", word(32) + word(6) + ftso + strings.Repeat("0", 64-len(ftso)), "FtsoV2", false},
+		{"empty string", word(32) + word(0), "", false},
+		{"too short", word(32), "", true},
+		{"offset past the end", word(64) + word(6), "", true},
+		{"offset wrapping around", strings.Repeat("0", 48) + "ffffffffffffffe0" + word(6), "", true},
+		{"length past the end", word(32) + word(64) + ftso, "", true},
+		{"length wrapping around", word(32) + strings.Repeat("f", 64), "", true},
+		{"length near 2^64", word(32) + strings.Repeat("0", 48) + "ffffffffffffffe1", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeString(tt.args)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("decodeString() = %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// useProfile activates the named profile with extra registry entries for the test
+func useProfile(t *testing.T, name string, contracts map[string]string) {
+	t.Helper()
+	profile, err := network.Lookup(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for contract, address := range contracts {
+		if err := profile.SetContract(contract, address); err != nil {
+			t.Fatal(err)
+		}
+	}
+	previous := network.Active()
+	network.SetActive(profile)
+	t.Cleanup(func() { network.SetActive(previous) })
+}
+
+// lookupName calls getContractAddressByName on the registry
+func lookupName(t *testing.T, name string) string {
+	t.Helper()
+	encoded := hex.EncodeToString([]byte(name))
+	data := selectorGetContractAddressByName + word(32) + word(uint64(len(name))) + encoded + strings.Repeat("0", (64-len(encoded)%64)%64)
+	resp, err := HandleContractCall(ContractCall{To: network.FlareContractRegistryAddress, Data: data})
+	if err != nil || resp.Error != "" {
+		t.Fatalf("getContractAddressByName(%s) = %+v, %v", name, resp, err)
+	}
+	return resp.Result
+}
+
+func TestProfileContracts(t *testing.T) {
+	const deployed = "0x00000000000000000000000000000000000000Aa"
+	const relay = "0x00000000000000000000000000000000000000bb"
+	useProfile(t, "coston2", map[string]string{"FtsoV2": deployed, "Relay": relay})
+
+	if got := lookupName(t, "FtsoV2"); got != encodeAddress(deployed) {
+		t.Errorf("FtsoV2 resolves to %s, want %s", got, deployed)
+	}
+	if got := lookupName(t, "FdcVerification"); got != encodeAddress(FDCContractAddress) {
+		t.Errorf("FdcVerification resolves to %s, want the mock", got)
+	}
+	if got := lookupName(t, "Unknown"); got != encodeAddress("") {
+		t.Errorf("Unknown resolves to %s, want the zero address", got)
+	}
+
+	tests := []struct {
+		name    string
+		to      string
+		wantErr string // "" when the FtsoV2 mock answers
+	}{
+		{"mock at the profile address", deployed, ""},
+		{"address case is ignored", "0x" + strings.ToUpper(deployed[2:]), ""},
+		{"mock address no longer FtsoV2", FTSOContractAddress, "Unknown contract address"},
+		{"contract without a mock", relay, "The sandbox does not implement Relay"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := HandleContractCall(ContractCall{To: tt.to, Data: "0xdeadbeef"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.wantErr
+			if want == "" {
+				want = "Unknown function selector" // answered by the FtsoV2 mock
+			}
+			if resp.Error != want {
+				t.Errorf("HandleContractCall() error = %q, want %q", resp.Error, want)
+			}
+		})
+	}
+
+	// Two names cannot share an address
+	profile := network.Active()
+	if err := profile.SetContract("FdcVerification", deployed); err == nil {
+		t.Error("SetContract() accepted an address used by FtsoV2")
+	}
+	if err := profile.SetContract("Relay", "0x1234"); err == nil {
+		t.Error("SetContract() accepted an invalid address")
+	}
+}
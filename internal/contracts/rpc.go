@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"lfts/internal/chain"
 	"net/http"
+	"strings"
 )
 
 // JSONRPCRequest represents a JSON-RPC request
@@ -54,11 +55,41 @@ func HandleJSONRPC(w http.ResponseWriter, r *http.Request) {
 
 	switch req.Method {
 	case "eth_call":
-		resp.Result = handleEthCall(req.Params)
+		resp.Result, resp.Error = handleEthCall(req.Params)
 	case "eth_blockNumber":
 		resp.Result = handleEthBlockNumber()
+	case "eth_chainId":
+		resp.Result = handleEthChainID()
+	case "net_version":
+		resp.Result = handleNetVersion()
 	case "eth_getBlockByNumber":
 		resp.Result = handleEthGetBlockByNumber(req.Params)
+	case "eth_getBlockByHash":
+		resp.Result = handleEthGetBlockByNumber(req.Params)
+	case "evm_mine":
+		resp.Result, resp.Error = handleEvmMine(req.Params)
+	case "hardhat_mine", "anvil_mine":
+		resp.Result, resp.Error = handleHardhatMine(req.Params)
+	case "evm_increaseTime":
+		resp.Result, resp.Error = handleEvmIncreaseTime(req.Params)
+	case "evm_setNextBlockTimestamp":
+		resp.Result, resp.Error = handleEvmSetNextBlockTimestamp(req.Params)
+	case "lfts_freezeTime":
+		resp.Result, resp.Error = handleFreezeTime(true)
+	case "lfts_unfreezeTime":
+		resp.Result, resp.Error = handleFreezeTime(false)
+	case "evm_snapshot":
+		resp.Result, resp.Error = handleEvmSnapshot()
+	case "evm_revert":
+		resp.Result, resp.Error = handleEvmRevert(req.Params)
+	case "lfts_reorg":
+		resp.Result, resp.Error = handleLftsReorg(req.Params)
+	case "lfts_getProof":
+		resp.Result, resp.Error = handleLftsGetProof(req.Params)
+	case "evm_setAutomine":
+		resp.Result, resp.Error = handleEvmSetAutomine(req.Params)
+	case "evm_setIntervalMining":
+		resp.Result, resp.Error = handleEvmSetIntervalMining(req.Params)
 	default:
 		resp.Error = &RPCError{
 			Code:    -32601,
@@ -70,21 +101,24 @@ func HandleJSONRPC(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-// handleEthCall processes eth_call requests
-func handleEthCall(params json.RawMessage) interface{} {
+// handleEthCall processes eth_call requests. The optional second parameter
+// selects the state to read: "pending" (the default) reads the current state
+// including unsealed writes; any other block reference (tag, number, hash or
+// {"blockNumber"|"blockHash": ...}) reads the state as of that block.
+func handleEthCall(params json.RawMessage) (interface{}, *RPCError) {
 	var callParams []interface{}
 	if err := json.Unmarshal(params, &callParams); err != nil {
-		return nil
+		return nil, nil
 	}
 
 	if len(callParams) < 1 {
-		return "0x"
+		return "0x", nil
 	}
 
 	// Parse call object
 	callObj, ok := callParams[0].(map[string]interface{})
 	if !ok {
-		return "0x"
+		return "0x", nil
 	}
 
 	to, _ := callObj["to"].(string)
@@ -95,16 +129,37 @@ func handleEthCall(params json.RawMessage) interface{} {
 		Data: data,
 	}
 
+	ref := "pending"
+	if len(callParams) > 1 && callParams[1] != nil {
+		switch block := callParams[1].(type) {
+		case string:
+			ref = block
+		case map[string]interface{}:
+			if number, ok := block["blockNumber"].(string); ok {
+				ref = number
+			} else if hash, ok := block["blockHash"].(string); ok {
+				ref = hash
+			}
+		}
+	}
+	if !strings.EqualFold(ref, "pending") {
+		number, err := chain.ResolveBlockNumber(ref)
+		if err != nil {
+			return nil, &RPCError{Code: -32000, Message: err.Error()}
+		}
+		call.Block = &number
+	}
+
 	response, err := HandleContractCall(call)
 	if err != nil {
-		return "0x"
+		return "0x", nil
 	}
 
 	if response.Error != "" {
-		return "0x"
+		return "0x", nil
 	}
 
-	return response.Result
+	return response.Result, nil
 }
 
 // handleEthBlockNumber returns current block number
@@ -118,7 +173,26 @@ func handleEthBlockNumber() interface{} {
 	return fmt.Sprintf("0x%x", height)
 }
 
-// handleEthGetBlockByNumber returns block information
+// handleEthChainID returns the chain ID as a hex quantity
+func handleEthChainID() interface{} {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return fmt.Sprintf("0x%x", chain.DefaultChainID)
+	}
+	return fmt.Sprintf("0x%x", chainInstance.GetChainID())
+}
+
+// handleNetVersion returns the network ID (the chain ID) as a decimal string
+func handleNetVersion() interface{} {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return fmt.Sprintf("%d", chain.DefaultChainID)
+	}
+	return fmt.Sprintf("%d", chainInstance.GetChainID())
+}
+
+// handleEthGetBlockByNumber returns block information for eth_getBlockByNumber and
+// eth_getBlockByHash (ResolveBlock accepts both numbers and hashes)
 func handleEthGetBlockByNumber(params json.RawMessage) interface{} {
 	var blockParams []interface{}
 	if err := json.Unmarshal(params, &blockParams); err != nil {
@@ -129,27 +203,48 @@ func handleEthGetBlockByNumber(params json.RawMessage) interface{} {
 		return nil
 	}
 
-	blockNumStr, _ := blockParams[0].(string)
+	blockRef, _ := blockParams[0].(string)
 	chainInstance := chain.GetInstance()
 	if chainInstance == nil {
 		return nil
 	}
 
-	block := chainInstance.GetLatestBlock()
-	if block == nil {
+	block, err := chainInstance.ResolveBlock(blockRef)
+	if err != nil || block == nil {
 		return nil
 	}
 
-	// Check if requesting latest block
-	if blockNumStr == "latest" || blockNumStr == "0x" {
-		return map[string]interface{}{
-			"number":       fmt.Sprintf("0x%x", block.Number),
-			"timestamp":    fmt.Sprintf("0x%x", block.Timestamp),
-			"transactions": []interface{}{},
-		}
-	}
+	return formatBlock(block)
+}
 
-	return nil
+// formatBlock renders a block in the Ethereum JSON-RPC block format. The sandbox
+// has no transactions, so the transaction and receipt roots are the empty trie root
+// expected by clients; the commitment to the block body is exposed as updatesRoot.
+func formatBlock(block *chain.Block) map[string]interface{} {
+	return map[string]interface{}{
+		"number":           fmt.Sprintf("0x%x", block.Number),
+		"hash":             block.Hash.String(),
+		"parentHash":       block.ParentHash.String(),
+		"timestamp":        fmt.Sprintf("0x%x", block.Timestamp),
+		"stateRoot":        block.StateRoot.String(),
+		"transactionsRoot": chain.EmptyRoot.String(),
+		"receiptsRoot":     chain.EmptyRoot.String(),
+		"updatesRoot":      block.UpdatesRoot.String(),
+		"sha3Uncles":       chain.EmptyUncleHash.String(),
+		"logsBloom":        "0x" + strings.Repeat("00", 256),
+		"miner":            block.Miner,
+		"gasLimit":         fmt.Sprintf("0x%x", block.GasLimit),
+		"gasUsed":          fmt.Sprintf("0x%x", block.GasUsed),
+		"difficulty":       fmt.Sprintf("0x%x", block.Difficulty),
+		"totalDifficulty":  "0x0",
+		"extraData":        block.ExtraData,
+		"mixHash":          chain.Hash{}.String(),
+		"nonce":            "0x0000000000000000",
+		"baseFeePerGas":    "0x0",
+		"size":             "0x0",
+		"transactions":     []interface{}{},
+		"uncles":           []interface{}{},
+	}
 }
 
 // sendError sends a JSON-RPC error response
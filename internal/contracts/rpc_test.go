@@ -0,0 +1,62 @@
+package contracts
+
+import (
+	"encoding/json"
+	"lfts/internal/ftso"
+	"strings"
+	"testing"
+)
+
+// flrUSD is the getFeedById call data for the FLR/USD feed
+var flrUSD = selectorGetFeedByID + "01464c522f555344" + strings.Repeat("0", 64-16)
+
+func TestEthCallBlockParameter(t *testing.T) {
+	c := useChain(t)
+
+	// Block 1 has the first price; the second one is not sealed yet
+	if _, err := ftso.SetPriceDecimal("FLR", "0.02"); err != nil {
+		t.Fatal(err)
+	}
+	c.CreateBlock()
+	if _, err := ftso.SetPriceDecimal("FLR", "0.03"); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := ftso.GetPriceAtBlock("FLR", 1)
+	if err != nil || first == nil {
+		t.Fatalf("GetPriceAtBlock() = %v, %v", first, err)
+	}
+	current, err := ftso.GetPrice("FLR")
+	if err != nil || current == nil {
+		t.Fatalf("GetPrice() = %v, %v", current, err)
+	}
+
+	call := map[string]string{"to": FTSOContractAddress, "data": flrUSD}
+	tests := []struct {
+		name  string
+		block interface{} // omitted if nil
+		want  *ftso.FTSOPrice
+	}{
+		{"default reads unsealed writes", nil, current},
+		{"pending", "pending", current},
+		{"latest", "latest", first},
+		{"historical block number", "0x1", first},
+		{"historical block object", map[string]string{"blockNumber": "0x1"}, first},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := []interface{}{call}
+			if tt.block != nil {
+				params = append(params, tt.block)
+			}
+			raw, _ := json.Marshal(params)
+			got, rpcErr := handleEthCall(raw)
+			if rpcErr != nil {
+				t.Fatalf("handleEthCall() error = %v", rpcErr.Message)
+			}
+			if want := "0x" + encodeUint(tt.want.Value); !strings.HasPrefix(got.(string), want) {
+				t.Errorf("handleEthCall() = %v, want value %s", got, want)
+			}
+		})
+	}
+}
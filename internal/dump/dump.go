@@ -0,0 +1,169 @@
+package dump
+
+import (
+	"encoding/hex"
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/network"
+	"lfts/internal/state"
+	"lfts/internal/utils"
+	"strings"
+	"unicode/utf8"
+)
+
+// Version is the format version written by Export. Import rejects other versions.
+const Version = 1
+
+// Dump is a portable copy of a sandbox: every state key as of the chain head and
+// the retained blocks. Encoding the same sandbox always yields the same JSON
+// (map keys are sorted, and there are no timestamps of the export itself).
+type Dump struct {
+	Version int            `json:"version"`
+	Network string         `json:"network"`
+	ChainID uint64         `json:"chainId"`
+	Height  uint64         `json:"height"`
+	Blocks  []*chain.Block `json:"blocks"`
+
+	// State holds text values; Binary holds values that are not valid UTF-8 as
+	// 0x-prefixed hex
+	State  map[string]string `json:"state"`
+	Binary map[string]string `json:"binary,omitempty"`
+}
+
+// Export captures the sealed state and blocks of the running chain. Writes that
+// are not yet sealed into a block are not included.
+func Export() (*Dump, error) {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return nil, fmt.Errorf("chain not initialized")
+	}
+
+	d := &Dump{
+		Version: Version,
+		Network: network.Active().Name,
+		ChainID: chainInstance.GetChainID(),
+		State:   make(map[string]string),
+	}
+	chainInstance.Exclusive(func() {
+		d.Height = chainInstance.GetHeight()
+		d.Blocks = chainInstance.GetBlocks(0, d.Height)
+
+		// Every committed version belongs to a block up to the head, so the
+		// newest version of each key is its value at the head
+		snap := state.GlobalState.Snapshot()
+		for key, versions := range snap.Versions {
+			value := versions[len(versions)-1].Value
+			if value == nil {
+				continue // deleted
+			}
+			if utf8.Valid(value) {
+				d.State[key] = string(value)
+			} else {
+				if d.Binary == nil {
+					d.Binary = make(map[string]string)
+				}
+				d.Binary[key] = "0x" + hex.EncodeToString(value)
+			}
+		}
+	})
+	return d, nil
+}
+
+// snapshot converts the dumped state into a storage snapshot at the dump height
+func (d *Dump) snapshot() (*state.Snapshot, error) {
+	snap := &state.Snapshot{
+		Versions: make(map[string][]state.Version, len(d.State)+len(d.Binary)),
+		Head:     d.Height,
+		Floor:    d.Height,
+	}
+	for key, value := range d.State {
+		snap.Versions[key] = []state.Version{{Block: d.Height, Value: []byte(value)}}
+	}
+	for key, value := range d.Binary {
+		if _, exists := d.State[key]; exists {
+			return nil, fmt.Errorf("key %s is both in state and binary", key)
+		}
+		raw, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("key %s: invalid hex value", key)
+		}
+		snap.Versions[key] = []state.Version{{Block: d.Height, Value: raw}}
+	}
+	return snap, nil
+}
+
+// Validate checks that the dump is complete and consistent: a supported version,
+// linked blocks ending at the dump height, and a state matching the head's state
+// root
+func (d *Dump) Validate() error {
+	if d.Version != Version {
+		return fmt.Errorf("unsupported dump version %d (expected %d)", d.Version, Version)
+	}
+
+	if n := len(d.Blocks); n == 0 {
+		if d.Height != 0 {
+			return fmt.Errorf("dump at height %d has no blocks", d.Height)
+		}
+	} else if head := d.Blocks[n-1]; head.Number != d.Height {
+		return fmt.Errorf("last block is %d, expected height %d", head.Number, d.Height)
+	}
+	for i, block := range d.Blocks {
+		var parent *chain.Block
+		if i > 0 {
+			parent = d.Blocks[i-1]
+		}
+		if err := block.Verify(parent); err != nil {
+			return err
+		}
+	}
+
+	snap, err := d.snapshot()
+	if err != nil {
+		return err
+	}
+	if n := len(d.Blocks); n > 0 {
+		check := state.NewMemoryStorage()
+		check.Restore(snap)
+		root, err := check.Root(d.Height)
+		if err != nil {
+			return err
+		}
+		if head := d.Blocks[n-1]; chain.Hash(root) != head.StateRoot {
+			return fmt.Errorf("state does not match the state root of block %d", head.Number)
+		}
+	}
+	return nil
+}
+
+// Import replaces the chain and state of the running node with the dump. State
+// history before the dump height is not part of a dump, so earlier blocks can
+// no longer be read with block= after an import.
+func Import(d *Dump) error {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return fmt.Errorf("chain not initialized")
+	}
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	snap, err := d.snapshot()
+	if err != nil {
+		return err
+	}
+
+	chainInstance.Exclusive(func() {
+		state.GlobalState.Restore(snap)
+		err = chainInstance.Import(d.Blocks)
+	})
+	if err != nil {
+		return err
+	}
+
+	if d.ChainID != 0 {
+		chainInstance.SetChainID(d.ChainID)
+	}
+	if d.Network != "" && d.Network != network.Active().Name {
+		utils.Info("Imported a %s dump into a node running the %s profile", d.Network, network.Active().Name)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"lfts/internal/chain"
+	"lfts/internal/state"
+	"testing"
+)
+
+// useChain installs a fresh chain over an in-memory state for the test
+func useChain(t *testing.T) *chain.Chain {
+	t.Helper()
+	previous := state.GlobalState
+	state.GlobalState = state.NewMemoryStorage()
+	c := chain.NewChain(1000)
+	chain.SetInstance(c)
+	t.Cleanup(func() {
+		chain.SetInstance(nil)
+		state.GlobalState = previous
+	})
+	return c
+}
+
+// exported builds a small chain with text, binary and deleted keys and dumps it
+// through JSON, as `lfts dump export` writes it
+func exported(t *testing.T) (*Dump, []byte) {
+	t.Helper()
+	c := useChain(t)
+	state.GlobalState.Set("ftso:BTC:latest", []byte(`{"value":1}`))
+	state.GlobalState.Set("gone", []byte("soon"))
+	c.CreateBlock()
+	state.GlobalState.Set("ftso:BTC:latest", []byte(`{"value":2}`))
+	state.GlobalState.Set("raw", []byte{0xff, 0x00, 0x01})
+	state.GlobalState.Delete("gone")
+	c.CreateBlock()
+	c.CreateBlock()
+
+	d, err := Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, encoded
+}
+
+func decode(t *testing.T, encoded []byte) *Dump {
+	t.Helper()
+	var d Dump
+	if err := json.Unmarshal(encoded, &d); err != nil {
+		t.Fatal(err)
+	}
+	return &d
+}
+
+func TestRoundTrip(t *testing.T) {
+	original, encoded := exported(t)
+	head := chain.GetInstance().GetLatestBlock()
+	if _, ok := original.Binary["raw"]; !ok {
+		t.Errorf("binary value exported as %v, want it in Binary", original.Binary)
+	}
+	if _, ok := original.State["gone"]; ok {
+		t.Error("deleted key exported")
+	}
+
+	// Import into a fresh node
+	c := useChain(t)
+	d := decode(t, encoded)
+	if err := Import(d); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	if got := c.GetLatestBlock(); got.Hash != head.Hash {
+		t.Errorf("head = #%d %s, want #%d %s", got.Number, got.Hash, head.Number, head.Hash)
+	}
+	root, err := state.GlobalState.Root(c.GetHeight())
+	if err != nil || chain.Hash(root) != head.StateRoot {
+		t.Errorf("state root = %x, %v, want %s", root, err, head.StateRoot)
+	}
+	if got, _ := state.GlobalState.Get("raw"); !bytes.Equal(got, []byte{0xff, 0x00, 0x01}) {
+		t.Errorf("raw = %x, want ff0001", got)
+	}
+	if state.GlobalState.Has("gone") {
+		t.Error("deleted key imported")
+	}
+
+	// Exporting the imported node yields the same dump
+	again, err := Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reencoded, _ := json.Marshal(again)
+	if !bytes.Equal(reencoded, encoded) {
+		t.Errorf("re-export differs:\n%s\nwant\n%s", reencoded, encoded)
+	}
+}
+
+func TestTamperedDump(t *testing.T) {
+	_, encoded := exported(t)
+	tests := []struct {
+		name   string
+		tamper func(d *Dump)
+	}{
+		{"changed value", func(d *Dump) { d.State["ftso:BTC:latest"] = `{"value":3}` }},
+		{"added key", func(d *Dump) { d.State["extra"] = "1" }},
+		{"removed key", func(d *Dump) { delete(d.Binary, "raw") }},
+		{"key in state and binary", func(d *Dump) { d.State["raw"] = "x" }},
+		{"changed state root", func(d *Dump) { d.Blocks[len(d.Blocks)-1].StateRoot = chain.EmptyRoot }},
+		{"changed block body", func(d *Dump) {
+			v := "forged"
+			d.Blocks[1].Data.StateUpdates = map[string]*string{"ftso:BTC:latest": &v}
+		}},
+		{"missing head block", func(d *Dump) { d.Blocks = d.Blocks[:len(d.Blocks)-1] }},
+		{"wrong height", func(d *Dump) { d.Height++ }},
+		{"unsupported version", func(d *Dump) { d.Version = Version + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := decode(t, encoded)
+			if err := d.Validate(); err != nil {
+				t.Fatalf("untampered dump: Validate() error = %v", err)
+			}
+			tt.tamper(d)
+			if err := d.Validate(); err == nil {
+				t.Error("Validate() accepted the tampered dump")
+			}
+		})
+	}
+
+	// A rejected import leaves the running node alone
+	c := chain.GetInstance()
+	head := c.GetLatestBlock()
+	d := decode(t, encoded)
+	d.State["ftso:BTC:latest"] = `{"value":3}`
+	if err := Import(d); err == nil {
+		t.Fatal("Import() accepted a tampered dump")
+	}
+	if got := c.GetLatestBlock(); got.Hash != head.Hash {
+		t.Errorf("head moved to #%d after a rejected import", got.Number)
+	}
+	if got, _ := state.GlobalState.Get("ftso:BTC:latest"); string(got) != `{"value":2}` {
+		t.Errorf("ftso:BTC:latest = %s after a rejected import", got)
+	}
+}
@@ -0,0 +1,55 @@
+package dump
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HandleExport handles GET /state/export and returns the dump as indented JSON
+func HandleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d, err := Export()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		http.Error(w, "Error encoding dump", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// HandleImport handles POST /state/import with a dump as the request body
+func HandleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var d Dump
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, "Invalid dump: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := Import(&d); err != nil {
+		http.Error(w, "Import failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"height": d.Height,
+		"blocks": len(d.Blocks),
+		"keys":   len(d.State) + len(d.Binary),
+	})
+}
@@ -2,22 +2,77 @@ package fdc
 
 import (
 	"encoding/json"
+	"fmt"
 	"lfts/internal/chain"
 	"lfts/internal/state"
-	"time"
+	"lfts/internal/utils"
+	"strings"
 )
 
 const (
-	// MaxHistoryEntries limits the number of historical feed entries stored per feed
-	MaxHistoryEntries = 1000
+	// DefaultHistoryRetention is the number of historical entries kept per feed
+	// unless changed with SetHistoryRetention
+	DefaultHistoryRetention = 1000
+)
+
+// Feed statuses reported by reads. A feed without a TTL is always fresh.
+const (
+	StatusFresh   = "fresh"
+	StatusStale   = "stale"
+	StatusExpired = "expired"
 )
 
 // FDCFeed represents a data feed from the FDC connector
 type FDCFeed struct {
 	FeedName  string                 `json:"feedName"`
 	Data      map[string]interface{} `json:"data"` // Arbitrary JSON data
-	Timestamp int64                   `json:"timestamp"`
-	BlockNum  uint64                  `json:"blockNum,omitempty"`
+	Timestamp int64                  `json:"timestamp"`
+	BlockNum  uint64                 `json:"blockNum,omitempty"`
+
+	// Validity of the value as of the read, set by reads (not stored)
+	Status    string `json:"status,omitempty"`    // fresh, stale or expired
+	StaleAt   int64  `json:"staleAt,omitempty"`   // when the value becomes stale (0 = no TTL)
+	ExpiresAt int64  `json:"expiresAt,omitempty"` // when the value expires (0 = no TTL)
+}
+
+// FeedTTL is the validity window of a feed's values, in seconds after the
+// timestamp of each value
+type FeedTTL struct {
+	TTL        int64 `json:"ttl"`                  // a value expires this long after its timestamp (0 = never)
+	StaleAfter int64 `json:"staleAfter,omitempty"` // a value is stale this long after its timestamp (0 = half the TTL)
+}
+
+// Validate checks the TTL for inconsistent values
+func (t FeedTTL) Validate() error {
+	if t.TTL < 0 || t.StaleAfter < 0 {
+		return fmt.Errorf("ttl and staleAfter must not be negative")
+	}
+	if t.StaleAfter > t.TTL {
+		return fmt.Errorf("staleAfter must not exceed ttl")
+	}
+	return nil
+}
+
+// annotate sets the validity fields of the feed as of the given time
+func (t FeedTTL) annotate(feed *FDCFeed, now int64) {
+	feed.Status = StatusFresh
+	if t.TTL == 0 {
+		return
+	}
+
+	staleAfter := t.StaleAfter
+	if staleAfter == 0 {
+		staleAfter = t.TTL / 2
+	}
+	feed.StaleAt = feed.Timestamp + staleAfter
+	feed.ExpiresAt = feed.Timestamp + t.TTL
+
+	switch {
+	case now >= feed.ExpiresAt:
+		feed.Status = StatusExpired
+	case now >= feed.StaleAt:
+		feed.Status = StatusStale
+	}
 }
 
 // FeedPoint represents a single feed entry in history
@@ -29,20 +84,47 @@ type FeedPoint struct {
 
 // FDCFeedHistory represents the full feed history
 type FDCFeedHistory struct {
-	FeedName string      `json:"feedName"`
-	Latest   *FDCFeed    `json:"latest"`
-	History  []FeedPoint `json:"history"`
+	FeedName  string      `json:"feedName"`
+	Latest    *FDCFeed    `json:"latest"`
+	History   []FeedPoint `json:"history"`
+	Retention int         `json:"retention"`
 }
 
-// SetFeed stores a feed entry for the given feed name
-func SetFeed(feedName string, data map[string]interface{}) error {
-	chainInstance := chain.GetInstance()
-	var blockNum uint64
-	if chainInstance != nil {
-		blockNum = chainInstance.GetHeight()
+// historyRing returns the history of a feed
+func historyRing(rw state.ReadWriter, feedName string) *state.Ring[FeedPoint] {
+	return state.NewRing[FeedPoint](rw, "history:fdc:"+feedName, DefaultHistoryRetention)
+}
+
+// SetFeed stores a feed entry for the given feed name. The write is recorded in the
+// pending block body; the returned feed carries the number of the block that will
+// include it.
+func SetFeed(feedName string, data map[string]interface{}) (*FDCFeed, error) {
+	return SetFeedAt(feedName, data, chain.Now().Unix())
+}
+
+// SetFeedAt is SetFeed with an explicit timestamp (used to seed history)
+func SetFeedAt(feedName string, data map[string]interface{}, now int64) (*FDCFeed, error) {
+	var feed *FDCFeed
+	err := chain.WithPendingBlock(func(blockNum uint64) error {
+		var err error
+		feed, err = setFeedIn(blockNum, feedName, data, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	now := time.Now().Unix()
+	return feed, nil
+}
+
+// SetFeedInBlock is SetFeed for callers that hold the chain exclusively (see
+// chain.Exclusive) and seal the pending block blockNum themselves
+func SetFeedInBlock(blockNum uint64, feedName string, data map[string]interface{}) (*FDCFeed, error) {
+	return setFeedIn(blockNum, feedName, data, chain.Now().Unix())
+}
+
+// setFeedIn stores a feed entry in the pending block blockNum
+func setFeedIn(blockNum uint64, feedName string, data map[string]interface{}, now int64) (*FDCFeed, error) {
 	feed := FDCFeed{
 		FeedName:  feedName,
 		Data:      data,
@@ -50,65 +132,169 @@ func SetFeed(feedName string, data map[string]interface{}) error {
 		BlockNum:  blockNum,
 	}
 
-	// Store latest feed
+	// Store the latest entry and its history entry atomically
 	latestKey := "fdc:" + feedName + ":latest"
 	latestData, err := json.Marshal(feed)
 	if err != nil {
-		return err
-	}
-	if err := state.Set(latestKey, latestData); err != nil {
-		return err
+		return nil, err
 	}
+	err = state.Update(func(tx *state.Tx) error {
+		if err := tx.Set(latestKey, latestData); err != nil {
+			return err
+		}
+
+		// The ring drops the oldest entry once full
+		err := historyRing(tx, feedName).Append(FeedPoint{
+			Data:      data,
+			Timestamp: now,
+			BlockNum:  blockNum,
+		})
+		if err != nil {
+			return err
+		}
 
-	// Add to history
-	historyKey := "fdc:" + feedName + ":history"
-	historyData, err := state.Get(historyKey)
+		// Record under the state lock, so the last write recorded for the key is
+		// the one the state keeps
+		chain.RecordStateUpdate(latestKey, latestData)
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var history FDCFeedHistory
-	if historyData != nil {
-		if err := json.Unmarshal(historyData, &history); err != nil {
-			// If unmarshal fails, start fresh
-			history = FDCFeedHistory{
-				FeedName: feedName,
-				History:  []FeedPoint{},
-			}
-		}
-	} else {
-		history = FDCFeedHistory{
-			FeedName: feedName,
-			History:  []FeedPoint{},
-		}
-	}
+	return &feed, nil
+}
 
-	// Add new feed point
-	history.Latest = &feed
-	history.History = append(history.History, FeedPoint{
-		Data:      data,
-		Timestamp: now,
-		BlockNum:  blockNum,
+// SetHistoryRetention sets how many historical entries are kept for a feed,
+// dropping the oldest ones if the history is longer
+func SetHistoryRetention(feedName string, entries int) error {
+	return chain.WithPendingBlock(func(uint64) error {
+		return state.Update(func(tx *state.Tx) error {
+			return historyRing(tx, feedName).SetRetention(entries)
+		})
 	})
+}
+
+// legacyHistorySuffix ends the keys under which earlier versions stored the
+// whole history of a feed as one value ("fdc:<feed>:history")
+const legacyHistorySuffix = ":history"
 
-	// Limit history size
-	if len(history.History) > MaxHistoryEntries {
-		history.History = history.History[len(history.History)-MaxHistoryEntries:]
+// MigrateHistory moves feed histories stored by earlier versions into the feeds'
+// history rings, before any entries the rings already have, and removes the old
+// values. Histories that cannot be decoded are left in place. Returns the number
+// of feeds migrated.
+func MigrateHistory() (int, error) {
+	var keys []string
+	err := state.IteratePrefix("fdc:", func(key string, _ []byte) bool {
+		if strings.HasSuffix(key, legacyHistorySuffix) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if err != nil || len(keys) == 0 {
+		return 0, err
 	}
 
-	// Store updated history
-	historyData, err = json.Marshal(history)
+	migrated := 0
+	err = chain.WithPendingBlock(func(uint64) error {
+		return state.Update(func(tx *state.Tx) error {
+			for _, key := range keys {
+				data, err := tx.Get(key)
+				if err != nil {
+					return err
+				}
+				var legacy struct {
+					History []FeedPoint `json:"history"`
+				}
+				if err := json.Unmarshal(data, &legacy); err != nil {
+					utils.Error("Cannot migrate feed history %s: %v", key, err)
+					continue
+				}
+
+				feedName := strings.TrimSuffix(strings.TrimPrefix(key, "fdc:"), legacyHistorySuffix)
+				if err := historyRing(tx, feedName).Prepend(legacy.History); err != nil {
+					return err
+				}
+				if err := tx.Delete(key); err != nil {
+					return err
+				}
+				migrated++
+			}
+			return nil
+		})
+	})
 	if err != nil {
+		return 0, err
+	}
+	return migrated, nil
+}
+
+// ttlKey returns the state key of a feed's TTL
+func ttlKey(feedName string) string {
+	return "ttl:fdc:" + feedName
+}
+
+// SetTTL sets the validity window of a feed's values (a zero TTL removes it).
+// It applies to the values already stored as well as to new ones.
+func SetTTL(feedName string, ttl FeedTTL) error {
+	if err := ttl.Validate(); err != nil {
 		return err
 	}
+	return chain.WithPendingBlock(func(uint64) error {
+		return state.Update(func(tx *state.Tx) error {
+			if ttl.TTL == 0 {
+				return tx.Delete(ttlKey(feedName))
+			}
+			data, err := json.Marshal(ttl)
+			if err != nil {
+				return err
+			}
+			return tx.Set(ttlKey(feedName), data)
+		})
+	})
+}
+
+// GetTTL retrieves the validity window of a feed (a zero TTL if it has none)
+func GetTTL(feedName string) (FeedTTL, error) {
+	return readTTL(feedName, state.Get)
+}
 
-	return state.Set(historyKey, historyData)
+// readTTL decodes the TTL of a feed using the given state reader
+func readTTL(feedName string, get func(key string) ([]byte, error)) (FeedTTL, error) {
+	var ttl FeedTTL
+	data, err := get(ttlKey(feedName))
+	if err != nil || data == nil {
+		return ttl, err
+	}
+	err = json.Unmarshal(data, &ttl)
+	return ttl, err
 }
 
-// GetFeed retrieves the latest feed for the given feed name
+// GetFeed retrieves the latest feed for the given feed name, with its status as
+// of the chain clock
 func GetFeed(feedName string) (*FDCFeed, error) {
+	return readFeed(feedName, state.Get, chain.Now().Unix())
+}
+
+// GetFeedAtBlock retrieves the feed as it was after the given block was sealed,
+// with its status as of that block's timestamp
+func GetFeedAtBlock(feedName string, number uint64) (*FDCFeed, error) {
+	now := chain.Now().Unix()
+	if chainInstance := chain.GetInstance(); chainInstance != nil {
+		if block := chainInstance.GetBlockByNumber(number); block != nil {
+			now = block.Timestamp
+		}
+	}
+	return readFeed(feedName, func(key string) ([]byte, error) {
+		return state.GetAt(key, number)
+	}, now)
+}
+
+// readFeed decodes the latest value of a feed using the given state reader and
+// sets its status as of now
+func readFeed(feedName string, get func(key string) ([]byte, error), now int64) (*FDCFeed, error) {
 	key := "fdc:" + feedName + ":latest"
-	data, err := state.Get(key)
+	data, err := get(key)
 	if err != nil {
 		return nil, err
 	}
@@ -123,43 +309,74 @@ func GetFeed(feedName string) (*FDCFeed, error) {
 		return nil, err
 	}
 
+	ttl, err := readTTL(feedName, get)
+	if err != nil {
+		return nil, err
+	}
+	ttl.annotate(&feed, now)
+
 	return &feed, nil
 }
 
 // GetFeedHistory retrieves the full feed history
 func GetFeedHistory(feedName string) (*FDCFeedHistory, error) {
-	key := "fdc:" + feedName + ":history"
-	data, err := state.Get(key)
+	latest, err := GetFeed(feedName)
 	if err != nil {
 		return nil, err
 	}
 
-	if data == nil {
-		return nil, nil
+	ring := historyRing(state.GlobalState, feedName)
+	points, err := ring.Last(0)
+	if err != nil {
+		return nil, err
 	}
-
-	var history FDCFeedHistory
-	err = json.Unmarshal(data, &history)
+	retention, err := ring.Retention()
 	if err != nil {
 		return nil, err
 	}
 
-	return &history, nil
+	if latest == nil && len(points) == 0 {
+		return nil, nil
+	}
+
+	return &FDCFeedHistory{
+		FeedName:  feedName,
+		Latest:    latest,
+		History:   points,
+		Retention: retention,
+	}, nil
+}
+
+// GetRecentFeeds retrieves the newest limit entries of a feed, oldest first
+func GetRecentFeeds(feedName string, limit int) ([]FeedPoint, error) {
+	return historyRing(state.GlobalState, feedName).Last(limit)
 }
 
-// GetAllFeeds retrieves all FDC feeds from state
+// GetAllFeeds retrieves the latest value of every FDC feed from state
 func GetAllFeeds() (map[string]*FDCFeed, error) {
-	allKeys := state.GlobalState.GetAllKeys()
 	feeds := make(map[string]*FDCFeed)
+	err := state.IteratePrefix("fdc:", func(key string, value []byte) bool {
+		feedName, ok := strings.CutSuffix(strings.TrimPrefix(key, "fdc:"), ":latest")
+		if !ok {
+			return true
+		}
+		var feed FDCFeed
+		if err := json.Unmarshal(value, &feed); err == nil {
+			feeds[feedName] = &feed
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	for _, key := range allKeys {
-		if len(key) > 4 && key[:4] == "fdc:" && key[len(key)-7:] == ":latest" {
-			feedName := key[4 : len(key)-7]
-			feed, err := GetFeed(feedName)
-			if err == nil && feed != nil {
-				feeds[feedName] = feed
-			}
+	now := chain.Now().Unix()
+	for feedName, feed := range feeds {
+		ttl, err := GetTTL(feedName)
+		if err != nil {
+			return nil, err
 		}
+		ttl.annotate(feed, now)
 	}
 
 	return feeds, nil
@@ -3,11 +3,13 @@ package fdc
 import (
 	"encoding/json"
 	"io"
+	"lfts/internal/chain"
 	"net/http"
 	"strconv"
 )
 
-// HandleFeed handles GET /fdc/feed?name=<feed_name>
+// HandleFeed handles GET /fdc/feed?name=<feed_name>[&block=<number|hash|tag>][&strict=true].
+// With strict=true an expired feed is answered with 410 Gone instead of a status flag.
 func HandleFeed(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -20,8 +22,21 @@ func HandleFeed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	feed, err := GetFeed(feedName)
-	if err != nil {
+	var (
+		feed *FDCFeed
+		err  error
+	)
+	if blockStr := r.URL.Query().Get("block"); blockStr != "" {
+		number, resolveErr := chain.ResolveBlockNumber(blockStr)
+		if resolveErr != nil {
+			http.Error(w, resolveErr.Error(), chain.ReadErrorStatus(resolveErr))
+			return
+		}
+		if feed, err = GetFeedAtBlock(feedName, number); err != nil {
+			http.Error(w, err.Error(), chain.ReadErrorStatus(err))
+			return
+		}
+	} else if feed, err = GetFeed(feedName); err != nil {
 		http.Error(w, "Error retrieving feed", http.StatusInternalServerError)
 		return
 	}
@@ -30,6 +45,10 @@ func HandleFeed(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Feed not found: "+feedName, http.StatusNotFound)
 		return
 	}
+	if feed.Status == StatusExpired && r.URL.Query().Get("strict") == "true" {
+		http.Error(w, "Feed expired: "+feedName, http.StatusGone)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(feed)
@@ -68,15 +87,20 @@ func HandleInjectFeed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = SetFeed(feedName, data)
+	feed, err := SetFeed(feedName, data)
 	if err != nil {
 		http.Error(w, "Error setting feed", http.StatusInternalServerError)
 		return
 	}
 
-	feed, _ := GetFeed(feedName)
+	// Respond with the block that includes the write
+	response := map[string]interface{}{
+		"feed":  feed,
+		"block": chain.WaitForInclusion(feed.BlockNum),
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(feed)
+	json.NewEncoder(w).Encode(response)
 }
 
 // HandleFeedHistory handles GET /fdc/history?name=<feed_name>&limit=<limit>
@@ -100,25 +124,13 @@ func HandleFeedHistory(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		history, err := GetFeedHistory(feedName)
+		// Return last N entries
+		result, err := GetRecentFeeds(feedName, limit)
 		if err != nil {
 			http.Error(w, "Error retrieving feed history", http.StatusInternalServerError)
 			return
 		}
 
-		if history == nil || len(history.History) == 0 {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode([]FeedPoint{})
-			return
-		}
-
-		// Return last N entries
-		start := len(history.History) - limit
-		if start < 0 {
-			start = 0
-		}
-		result := history.History[start:]
-
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(result)
 		return
@@ -134,8 +146,9 @@ func HandleFeedHistory(w http.ResponseWriter, r *http.Request) {
 	if history == nil {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(FDCFeedHistory{
-			FeedName: feedName,
-			History:  []FeedPoint{},
+			FeedName:  feedName,
+			History:   []FeedPoint{},
+			Retention: DefaultHistoryRetention,
 		})
 		return
 	}
@@ -144,6 +157,90 @@ func HandleFeedHistory(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(history)
 }
 
+// HandleRetention handles POST /fdc/retention?name=<feed_name>&entries=<n> and sets
+// how many historical entries are kept for the feed
+func HandleRetention(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feedName := r.URL.Query().Get("name")
+	if feedName == "" {
+		http.Error(w, "Missing name parameter", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := strconv.Atoi(r.URL.Query().Get("entries"))
+	if err != nil || entries <= 0 {
+		http.Error(w, "Invalid entries parameter", http.StatusBadRequest)
+		return
+	}
+
+	if err := SetHistoryRetention(feedName, entries); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"feedName":  feedName,
+		"retention": entries,
+	})
+}
+
+// HandleTTL handles GET /fdc/ttl?name=<feed_name> and
+// POST /fdc/ttl?name=<feed_name>&ttl=<seconds>[&staleAfter=<seconds>], which sets
+// how long the feed's values stay valid (ttl=0 removes the TTL)
+func HandleTTL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feedName := r.URL.Query().Get("name")
+	if feedName == "" {
+		http.Error(w, "Missing name parameter", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var ttl FeedTTL
+		var err error
+		if ttl.TTL, err = strconv.ParseInt(r.URL.Query().Get("ttl"), 10, 64); err != nil {
+			http.Error(w, "Invalid ttl parameter", http.StatusBadRequest)
+			return
+		}
+		if staleStr := r.URL.Query().Get("staleAfter"); staleStr != "" {
+			if ttl.StaleAfter, err = strconv.ParseInt(staleStr, 10, 64); err != nil {
+				http.Error(w, "Invalid staleAfter parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := ttl.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := SetTTL(feedName, ttl); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	ttl, err := GetTTL(feedName)
+	if err != nil {
+		http.Error(w, "Error retrieving ttl", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"feedName":   feedName,
+		"ttl":        ttl.TTL,
+		"staleAfter": ttl.StaleAfter,
+	})
+}
+
 // HandleListFeeds handles GET /fdc/list
 func HandleListFeeds(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -0,0 +1,137 @@
+// Package feedid encodes FTSOv2 feed IDs: a category byte followed by the
+// ASCII feed name, right-padded to 21 bytes
+package feedid
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Category is the feed category stored in the first byte of an FTSOv2 feed ID
+type Category byte
+
+// FTSOv2 feed categories
+const (
+	CategoryCrypto    Category = 0x01
+	CategoryForex     Category = 0x02
+	CategoryCommodity Category = 0x03
+	CategoryStock     Category = 0x04
+)
+
+// categoryNames maps categories to their names
+var categoryNames = map[Category]string{
+	CategoryCrypto:    "crypto",
+	CategoryForex:     "forex",
+	CategoryCommodity: "commodity",
+	CategoryStock:     "stock",
+}
+
+// ParseCategory parses a category name ("crypto") or number ("1")
+func ParseCategory(s string) (Category, error) {
+	for category, name := range categoryNames {
+		if strings.EqualFold(s, name) {
+			return category, nil
+		}
+	}
+	if n, err := strconv.ParseUint(s, 0, 8); err == nil {
+		if _, known := categoryNames[Category(n)]; known {
+			return Category(n), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown feed category %q (expected crypto, forex, commodity or stock)", s)
+}
+
+// String returns the category name
+func (c Category) String() string {
+	if name, known := categoryNames[c]; known {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", byte(c))
+}
+
+// MarshalText encodes the category as its name
+func (c Category) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes a category name or number
+func (c *Category) UnmarshalText(text []byte) error {
+	category, err := ParseCategory(string(text))
+	if err != nil {
+		return err
+	}
+	*c = category
+	return nil
+}
+
+// ID is a bytes21 FTSOv2 feed ID: the category byte followed by the ASCII
+// feed name, right-padded with zeros
+type ID [21]byte
+
+// New returns the ID of the feed with the given category and name
+func New(category Category, name string) (ID, error) {
+	var id ID
+	if _, known := categoryNames[category]; !known {
+		return id, fmt.Errorf("unknown feed category %s", category)
+	}
+	if name == "" || len(name) > len(id)-1 {
+		return id, fmt.Errorf("feed name %q must be 1 to %d bytes", name, len(id)-1)
+	}
+	id[0] = byte(category)
+	copy(id[1:], name)
+	return id, nil
+}
+
+// Parse parses a 0x-prefixed bytes21 feed ID
+func Parse(s string) (ID, error) {
+	var id ID
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+	if err != nil || len(raw) != len(id) {
+		return id, fmt.Errorf("invalid feed ID %q (expected 21 hex bytes)", s)
+	}
+	copy(id[:], raw)
+	if _, known := categoryNames[id.Category()]; !known {
+		return id, fmt.Errorf("invalid feed ID %q: unknown category 0x%02x", s, raw[0])
+	}
+	if id.Name() == "" {
+		return id, fmt.Errorf("invalid feed ID %q: empty name", s)
+	}
+	return id, nil
+}
+
+// IsID reports whether s has the form of a feed ID rather than a name or symbol
+func IsID(s string) bool {
+	return (strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X")) && len(s) == 2+2*len(ID{})
+}
+
+// Category returns the category of the feed
+func (id ID) Category() Category {
+	return Category(id[0])
+}
+
+// Name returns the feed name without padding
+func (id ID) Name() string {
+	return strings.TrimRight(string(id[1:]), "\x00")
+}
+
+// String returns the ID as 0x-prefixed hex
+func (id ID) String() string {
+	return "0x" + hex.EncodeToString(id[:])
+}
+
+// MarshalText encodes the ID as 0x-prefixed hex
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText decodes a 0x-prefixed feed ID
+func (id *ID) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
@@ -0,0 +1,292 @@
+package ftso
+
+import (
+	"encoding/json"
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/state"
+	"lfts/internal/utils"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultFastUpdatePrecision is the percentage one fast-update delta moves a
+// value by (2^-13)
+const DefaultFastUpdatePrecision = 0.01220703125
+
+// FastUpdateConfig configures block-latency fast updates. Every block, each
+// submitter moves every feed's fast value by at most one delta of Precision
+// percent towards its target: the newest value submitted to a voting round that
+// has not ended yet, otherwise the latest published anchor value.
+type FastUpdateConfig struct {
+	Submitters int     `json:"submitters"` // per block (0 disables fast updates)
+	Precision  float64 `json:"precision"`  // percent per delta
+}
+
+// Validate checks the fast-update settings
+func (c *FastUpdateConfig) Validate() error {
+	if c.Submitters < 0 {
+		return fmt.Errorf("fast-update submitters must not be negative")
+	}
+	if math.IsNaN(c.Precision) || c.Precision <= 0 || c.Precision >= 100 {
+		return fmt.Errorf("fast-update precision must be greater than 0 and less than 100 percent")
+	}
+	return nil
+}
+
+// step returns the precision as a fraction, parsed as decimal text so 0.01%
+// is exactly 1/10000
+func (c *FastUpdateConfig) step() *big.Rat {
+	step, _ := new(big.Rat).SetString(strconv.FormatFloat(c.Precision, 'f', -1, 64))
+	return step.Quo(step, big.NewRat(100, 1))
+}
+
+// fastUpdatesKey is the state key of the configuration set by SetFastUpdates
+const fastUpdatesKey = "config:ftso:fast-updates"
+
+var (
+	// fastUpdatesMu guards fastUpdates
+	fastUpdatesMu sync.RWMutex
+
+	// fastUpdates is the node's fast-update configuration (disabled by default)
+	fastUpdates = FastUpdateConfig{Precision: DefaultFastUpdatePrecision}
+)
+
+// FastUpdates returns the fast-update configuration set by SetFastUpdates,
+// stored in the state so reverts and reorgs undo it, otherwise the node's
+func FastUpdates() FastUpdateConfig {
+	data, err := state.Get(fastUpdatesKey)
+	if err == nil && data != nil {
+		var config FastUpdateConfig
+		if err = json.Unmarshal(data, &config); err == nil {
+			return config
+		}
+	}
+	if err != nil {
+		utils.Error("Invalid fast-update configuration in state: %v", err)
+	}
+
+	fastUpdatesMu.RLock()
+	defer fastUpdatesMu.RUnlock()
+	return fastUpdates
+}
+
+// ConfigureFastUpdates sets the node's fast-update configuration before the
+// chain starts. It applies until SetFastUpdates changes it on chain.
+func ConfigureFastUpdates(config FastUpdateConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	fastUpdatesMu.Lock()
+	defer fastUpdatesMu.Unlock()
+	fastUpdates = config
+	return nil
+}
+
+// SetFastUpdates changes the fast-update configuration of a running node and
+// stores it in the state. Disabling fast updates removes the block-latency
+// values, so reads return anchor values again; enabling them starts every feed
+// at its anchor value.
+func SetFastUpdates(config FastUpdateConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	if config.Submitters == 0 {
+		err := state.IteratePrefix("fast:ftso:", func(key string, value []byte) bool {
+			keys = append(keys, key)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return chain.WithPendingBlock(func(uint64) error {
+		return state.Update(func(tx *state.Tx) error {
+			if err := tx.Set(fastUpdatesKey, data); err != nil {
+				return err
+			}
+			for _, key := range keys {
+				if err := tx.Delete(key); err != nil {
+					return err
+				}
+				chain.RecordStateUpdate(key, nil)
+			}
+			return nil
+		})
+	})
+}
+
+// fastKey returns the state key of an asset's block-latency value
+func fastKey(asset string) string {
+	return "fast:ftso:" + asset
+}
+
+// decodePrice reads a price stored at a key, or nil if there is none
+func decodePrice(key string, get func(key string) ([]byte, error)) (*FTSOPrice, error) {
+	data, err := get(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var price FTSOPrice
+	if err := json.Unmarshal(data, &price); err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// ApplyFastUpdates moves the block-latency value of every feed towards its
+// target: the newest value submitted to a voting round that has not ended yet,
+// otherwise the latest anchor value. Fast values thus lead the anchors in
+// either direction, revealing a pending value only one delta per submitter per
+// block. The chain runs it as a seal hook after FinalizeRounds, so every block
+// produced by the chain loop (or mined on demand) carries one batch of fast
+// updates, made after the block publishes the values of ended rounds.
+func ApplyFastUpdates(number uint64, timestamp int64) error {
+	config := FastUpdates()
+	if config.Submitters == 0 {
+		return nil
+	}
+
+	// Feeds with an anchor value and their pending values, collected outside Update
+	var assets []string
+	err := state.IteratePrefix("ftso:", func(key string, value []byte) bool {
+		if asset, ok := strings.CutSuffix(strings.TrimPrefix(key, "ftso:"), ":latest"); ok {
+			assets = append(assets, asset)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(assets)
+	pending, err := GetPendingPrices()
+	if err != nil {
+		return err
+	}
+	targets := make(map[string]*FTSOPrice, len(pending))
+	for _, price := range pending {
+		targets[price.Asset] = price // ordered by round, so the newest wins
+	}
+
+	step := config.step()
+	return state.Update(func(tx *state.Tx) error {
+		for _, asset := range assets {
+			if err := fastUpdate(tx, asset, targets[asset], config.Submitters, step, number, timestamp); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// fastUpdate applies one block of fast updates to an asset, moving it towards
+// target, or towards its anchor value if target is nil
+func fastUpdate(tx *state.Tx, asset string, target *FTSOPrice, submitters int, step *big.Rat, number uint64, timestamp int64) error {
+	anchor, err := decodePrice("ftso:"+asset+":latest", tx.Get)
+	if err != nil || anchor == nil {
+		return err
+	}
+	current, err := decodePrice(fastKey(asset), tx.Get)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		target = anchor
+	}
+
+	decimals, err := readDecimals(tx, asset)
+	if err != nil {
+		return err
+	}
+	goal := ScaleValue(target.Value, target.Decimals, decimals)
+
+	// Fast updates start from the anchor value
+	value := ScaleValue(anchor.Value, anchor.Decimals, decimals)
+	if current != nil {
+		value = ScaleValue(current.Value, current.Decimals, decimals)
+	}
+	for i := 0; i < submitters; i++ {
+		next := applyDelta(value, goal, step)
+		if next == nil {
+			break
+		}
+		value = next
+	}
+	if current != nil && value.Cmp(current.Value) == 0 && decimals == current.Decimals {
+		return nil
+	}
+
+	fast := FTSOPrice{
+		Asset:     asset,
+		Value:     value,
+		Decimals:  decimals,
+		Price:     json.Number(FormatValue(value, decimals)),
+		Timestamp: timestamp,
+		BlockNum:  number,
+	}
+	data, err := json.Marshal(fast)
+	if err != nil {
+		return err
+	}
+	if err := tx.Set(fastKey(asset), data); err != nil {
+		return err
+	}
+	chain.RecordStateUpdate(fastKey(asset), data)
+	return nil
+}
+
+// applyDelta returns the value moved by one delta (step times the value, at
+// least one unit) towards the goal, or nil if no delta gets it closer
+func applyDelta(value, goal *big.Int, step *big.Rat) *big.Int {
+	diff := new(big.Int).Sub(goal, value)
+	if diff.Sign() == 0 {
+		return nil
+	}
+
+	scaled := new(big.Rat).Mul(new(big.Rat).SetInt(new(big.Int).Abs(value)), step)
+	delta := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+	if delta.Sign() == 0 {
+		delta.SetInt64(1)
+	}
+
+	// Moving helps only while the goal is more than half a delta away
+	if new(big.Int).Lsh(new(big.Int).Abs(diff), 1).Cmp(delta) <= 0 {
+		return nil
+	}
+	if diff.Sign() < 0 {
+		return delta.Sub(value, delta)
+	}
+	return delta.Add(value, delta)
+}
+
+// GetAnchorPrice returns the latest anchor value of an asset: the value published
+// by the last finalized voting round, without fast updates
+func GetAnchorPrice(asset string) (*FTSOPrice, error) {
+	asset, err := ResolveAsset(asset)
+	if err != nil {
+		return nil, err
+	}
+	return readAnchor(asset, state.Get)
+}
+
+// GetAnchorPriceAtBlock returns the anchor value of an asset as it was after the
+// given block was sealed
+func GetAnchorPriceAtBlock(asset string, number uint64) (*FTSOPrice, error) {
+	asset, err := ResolveAsset(asset)
+	if err != nil {
+		return nil, err
+	}
+	return readAnchor(asset, func(key string) ([]byte, error) {
+		return state.GetAt(key, number)
+	})
+}
@@ -0,0 +1,182 @@
+package ftso
+
+import (
+	"encoding/json"
+	"lfts/internal/state"
+	"math/big"
+	"testing"
+)
+
+func TestApplyDelta(t *testing.T) {
+	percent := big.NewRat(1, 100)
+	tests := []struct {
+		name        string
+		value, goal int64
+		step        *big.Rat
+		want        int64 // 0: no delta
+	}{
+		{"up", 10000, 20000, percent, 10100},
+		{"down", 10000, 5000, percent, 9900},
+		{"at goal", 10000, 10000, percent, 0},
+		{"within half a delta", 10000, 10050, percent, 0},
+		{"just over half a delta", 10000, 9949, percent, 9900},
+		{"at least one unit", 50, 60, percent, 51},
+		{"default precision", 8192000, 9000000, (&FastUpdateConfig{Precision: DefaultFastUpdatePrecision}).step(), 8193000},
+		{"decimal precision", 1000000, 0, (&FastUpdateConfig{Precision: 0.01}).step(), 999900},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyDelta(big.NewInt(tt.value), big.NewInt(tt.goal), tt.step)
+			switch {
+			case tt.want == 0 && got != nil:
+				t.Errorf("applyDelta(%d, %d) = %s, want no delta", tt.value, tt.goal, got)
+			case tt.want != 0 && (got == nil || got.Int64() != tt.want):
+				t.Errorf("applyDelta(%d, %d) = %v, want %d", tt.value, tt.goal, got, tt.want)
+			}
+		})
+	}
+}
+
+// useFastUpdates configures fast updates for the test
+func useFastUpdates(t *testing.T, config FastUpdateConfig) {
+	t.Helper()
+	previous := FastUpdates()
+	if err := ConfigureFastUpdates(config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		fastUpdatesMu.Lock()
+		fastUpdates = previous
+		fastUpdatesMu.Unlock()
+	})
+}
+
+// submit stores a value waiting for its voting round to end
+func submit(t *testing.T, asset string, round uint64, value int64, decimals int8) {
+	t.Helper()
+	price := FTSOPrice{Asset: asset, Value: big.NewInt(value), Decimals: decimals, VotingRoundID: round}
+	price.Price = json.Number(FormatValue(price.Value, decimals))
+	data, _ := json.Marshal(price)
+	err := state.Update(func(tx *state.Tx) error {
+		return tx.Set(pendingKey(asset, round), data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fastBlocks applies fast updates to the blocks after number and returns the
+// BTC value after each
+func fastBlocks(t *testing.T, number *uint64, count int) []*FTSOPrice {
+	t.Helper()
+	var prices []*FTSOPrice
+	for i := 0; i < count; i++ {
+		*number++
+		if err := ApplyFastUpdates(*number, int64(*number)); err != nil {
+			t.Fatalf("ApplyFastUpdates(%d) error = %v", *number, err)
+		}
+		price, err := GetPrice("BTC")
+		if err != nil || price == nil {
+			t.Fatalf("GetPrice() = %v, %v after block %d", price, err, *number)
+		}
+		prices = append(prices, price)
+	}
+	return prices
+}
+
+// checkConverges checks that values move monotonically towards goal and then
+// stay within half a delta of it
+func checkConverges(t *testing.T, prices []*FTSOPrice, from, goal int64, step *big.Rat) {
+	t.Helper()
+	previous := from
+	for i, p := range prices {
+		v := p.Value.Int64()
+		if (goal > from && (v < previous || v > goal+previous/200)) ||
+			(goal < from && (v > previous || v < goal-previous/200)) {
+			t.Fatalf("block %d moved from %d to %d, want monotonic towards %d", p.BlockNum, previous, v, goal)
+		}
+		if i >= len(prices)-5 && v != previous {
+			t.Fatalf("block %d moved from %d to %d, want the value to settle", p.BlockNum, previous, v)
+		}
+		previous = v
+	}
+	if applyDelta(big.NewInt(previous), big.NewInt(goal), step) != nil {
+		t.Errorf("settled at %d, more than half a delta from %d", previous, goal)
+	}
+}
+
+func TestApplyFastUpdates(t *testing.T) {
+	useMemoryState(t)
+	if err := SetDecimals("BTC", 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetPriceDecimal("BTC", "100"); err != nil {
+		t.Fatal(err)
+	}
+	var number uint64
+
+	// Disabled fast updates leave the anchor value alone
+	useFastUpdates(t, FastUpdateConfig{Precision: 1})
+	fastBlocks(t, &number, 1)
+	if state.GlobalState.Has(fastKey("BTC")) {
+		t.Fatal("fast value stored while fast updates are disabled")
+	}
+
+	config := FastUpdateConfig{Submitters: 2, Precision: 1}
+	step := config.step()
+	useFastUpdates(t, config)
+
+	// A feed starts at its anchor value
+	prices := fastBlocks(t, &number, 1)
+	if p := prices[0]; p.Value.Int64() != 10000 || p.Decimals != 2 || p.BlockNum != number {
+		t.Fatalf("first fast value = %+v, want the anchor 10000 in block %d", p, number)
+	}
+
+	// One delta per submitter per block towards the pending value
+	submit(t, "BTC", 5, 11000, 2)
+	prices = fastBlocks(t, &number, 2)
+	if got := []int64{prices[0].Value.Int64(), prices[1].Value.Int64()}; got[0] != 10201 || got[1] != 10406 {
+		t.Fatalf("fast values = %v, want [10201 10406]", got)
+	}
+	if p := prices[1]; string(p.Price) != "104.06" || p.Timestamp != int64(number) {
+		t.Errorf("fast value = %+v, want price 104.06 at timestamp %d", p, number)
+	}
+	if anchor, _ := GetAnchorPrice("BTC"); anchor == nil || anchor.Value.Int64() != 10000 {
+		t.Errorf("GetAnchorPrice() = %+v, want the anchor unchanged", anchor)
+	}
+	checkConverges(t, fastBlocks(t, &number, 20), 10406, 11000, step)
+
+	// The newest round wins, leading the anchor downwards
+	submit(t, "BTC", 6, 9000, 2)
+	current, _ := GetPrice("BTC")
+	prices = fastBlocks(t, &number, 30)
+	checkConverges(t, prices, current.Value.Int64(), 9000, step)
+	if last := prices[len(prices)-1].Value.Int64(); last >= 10000 {
+		t.Errorf("fast value = %d, want it below the anchor 10000", last)
+	}
+
+	// Once the rounds end the feed follows its anchor again
+	err := state.Update(func(tx *state.Tx) error {
+		if err := tx.Delete(pendingKey("BTC", 5)); err != nil {
+			return err
+		}
+		return tx.Delete(pendingKey("BTC", 6))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, _ = GetPrice("BTC")
+	checkConverges(t, fastBlocks(t, &number, 30), current.Value.Int64(), 10000, step)
+
+	// New decimals rescale the fast value before moving it
+	current, _ = GetPrice("BTC")
+	if err := SetDecimals("BTC", 4); err != nil {
+		t.Fatal(err)
+	}
+	prices = fastBlocks(t, &number, 1)
+	rescaled := current.Value.Int64() * 100
+	if p := prices[0]; p.Decimals != 4 || p.Value.Int64() < min(rescaled, 1000000) || p.Value.Int64() > max(rescaled, 1000000) {
+		t.Errorf("fast value = %+v, want 4 decimals between %d and the anchor 1000000", p, rescaled)
+	}
+	checkConverges(t, fastBlocks(t, &number, 30), prices[0].Value.Int64(), 1000000, step)
+}
@@ -0,0 +1,167 @@
+package ftso
+
+import (
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/feedid"
+	"lfts/internal/network"
+	"lfts/internal/state"
+	"sort"
+	"strings"
+)
+
+// Feed is an entry of the feed registry: an FTSOv2 feed and the asset symbol its
+// values are stored under
+type Feed struct {
+	ID       feedid.ID       `json:"id"`
+	Category feedid.Category `json:"category"`
+	Name     string          `json:"name"`
+	Asset    string          `json:"asset"`
+}
+
+// feedKey returns the state key of a feed's registered asset symbol
+func feedKey(id feedid.ID) string {
+	return "feed:ftso:" + id.String()
+}
+
+// defaultAsset returns the asset symbol of a feed that is not registered: the
+// base symbol of a USD-quoted crypto feed ("BTC" for BTC/USD), otherwise the
+// feed name
+func defaultAsset(id feedid.ID) string {
+	if base, ok := strings.CutSuffix(id.Name(), "/USD"); ok && id.Category() == feedid.CategoryCrypto {
+		return base
+	}
+	return id.Name()
+}
+
+// newFeed builds the registry entry of a feed ID
+func newFeed(id feedid.ID) (Feed, error) {
+	data, err := state.Get(feedKey(id))
+	if err != nil {
+		return Feed{}, err
+	}
+	asset := string(data)
+	if data == nil {
+		asset = defaultAsset(id)
+	}
+	return Feed{ID: id, Category: id.Category(), Name: id.Name(), Asset: asset}, nil
+}
+
+// RegisterFeed maps a feed to the asset symbol its values are stored under.
+// Feeds need no registration when the default applies: a USD-quoted crypto
+// feed maps to its base symbol (BTC/USD to BTC), any other feed to its name.
+// Registrations are stored in the state, so they follow the chain through
+// restarts, dumps and reverts.
+func RegisterFeed(category feedid.Category, name, asset string) (Feed, error) {
+	id, err := feedid.New(category, name)
+	if err != nil {
+		return Feed{}, err
+	}
+	if asset == "" || feedid.IsID(asset) || strings.Contains(asset, ":") {
+		return Feed{}, fmt.Errorf("invalid asset symbol %q", asset)
+	}
+
+	err = chain.WithPendingBlock(func(uint64) error {
+		return state.Update(func(tx *state.Tx) error {
+			return tx.Set(feedKey(id), []byte(asset))
+		})
+	})
+	if err != nil {
+		return Feed{}, err
+	}
+	return Feed{ID: id, Category: id.Category(), Name: id.Name(), Asset: asset}, nil
+}
+
+// LookupFeed returns the registry entry of a feed given as a feed ID
+// ("0x01464c522f555344..."), a crypto feed name ("FLR/USD") or an asset symbol
+// ("FLR")
+func LookupFeed(ref string) (Feed, error) {
+	var id feedid.ID
+	var err error
+	switch {
+	case feedid.IsID(ref):
+		id, err = feedid.Parse(ref)
+	case strings.Contains(ref, "/"):
+		id, err = feedid.New(feedid.CategoryCrypto, ref)
+	default:
+		id, err = assetFeedID(ref)
+	}
+	if err != nil {
+		return Feed{}, err
+	}
+	return newFeed(id)
+}
+
+// registeredFeeds returns the registered feeds, ordered by ID
+func registeredFeeds() ([]Feed, error) {
+	var feeds []Feed
+	var parseErr error
+	err := state.IteratePrefix("feed:ftso:", func(key string, value []byte) bool {
+		id, err := feedid.Parse(strings.TrimPrefix(key, "feed:ftso:"))
+		if err != nil {
+			parseErr = fmt.Errorf("invalid feed registration %s: %v", key, err)
+			return false
+		}
+		feeds = append(feeds, Feed{ID: id, Category: id.Category(), Name: id.Name(), Asset: string(value)})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return feeds, parseErr
+}
+
+// assetFeedID returns the ID of the feed stored under an asset symbol: a
+// registered feed if there is one, otherwise the USD-quoted crypto feed
+func assetFeedID(asset string) (feedid.ID, error) {
+	registered, err := registeredFeeds()
+	if err != nil {
+		return feedid.ID{}, err
+	}
+	for _, feed := range registered {
+		if feed.Asset == asset {
+			return feed.ID, nil
+		}
+	}
+	return feedid.New(feedid.CategoryCrypto, asset+"/USD")
+}
+
+// ResolveAsset returns the asset symbol that values of a feed are stored under,
+// given a feed ID, a crypto feed name or an asset symbol (returned unchanged)
+func ResolveAsset(ref string) (string, error) {
+	if !feedid.IsID(ref) && !strings.Contains(ref, "/") {
+		return ref, nil
+	}
+	feed, err := LookupFeed(ref)
+	if err != nil {
+		return "", err
+	}
+	return feed.Asset, nil
+}
+
+// Feeds returns the feeds of the active network profile and every registered
+// feed, ordered by ID
+func Feeds() ([]Feed, error) {
+	registered, err := registeredFeeds()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[feedid.ID]bool)
+	var feeds []Feed
+	for _, feed := range registered {
+		seen[feed.ID] = true
+		feeds = append(feeds, feed)
+	}
+	for _, name := range network.Active().Feeds {
+		id, err := feedid.New(feedid.CategoryCrypto, name)
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		feeds = append(feeds, Feed{ID: id, Category: id.Category(), Name: id.Name(), Asset: defaultAsset(id)})
+	}
+
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].ID.String() < feeds[j].ID.String() })
+	return feeds, nil
+}
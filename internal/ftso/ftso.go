@@ -2,114 +2,298 @@ package ftso
 
 import (
 	"encoding/json"
+	"fmt"
 	"lfts/internal/chain"
 	"lfts/internal/state"
-	"sort"
-	"time"
+	"lfts/internal/utils"
+	"math"
+	"math/big"
+	"strings"
 )
 
 const (
-	// MaxHistoryEntries limits the number of historical prices stored per asset
-	MaxHistoryEntries = 1000
+	// DefaultHistoryRetention is the number of historical prices kept per asset
+	// unless changed with SetHistoryRetention
+	DefaultHistoryRetention = 1000
 )
 
-// FTSOPrice represents a price feed from the FTSO oracle
+// FTSOPrice represents a price feed from the FTSO oracle. Values are fixed-point
+// integers as in FTSOv2: the price is Value / 10^Decimals.
 type FTSOPrice struct {
-	Asset     string  `json:"asset"`
-	Price     float64 `json:"price"`
-	Timestamp int64   `json:"timestamp"`
-	BlockNum  uint64  `json:"blockNum,omitempty"`
+	Asset         string      `json:"asset"`
+	Value         *big.Int    `json:"value"`
+	Decimals      int8        `json:"decimals"`
+	Price         json.Number `json:"price"` // Value rendered as a decimal number
+	Timestamp     int64       `json:"timestamp"`
+	BlockNum      uint64      `json:"blockNum,omitempty"`
+	VotingRoundID uint64      `json:"votingRoundId,omitempty"` // round that published the value
+}
+
+// Float64 returns the price as a float64 (for display and simulation)
+func (p *FTSOPrice) Float64() float64 {
+	return valueFloat(p.Value, p.Decimals)
+}
+
+// UnmarshalJSON decodes a price, converting the float prices stored by earlier
+// versions to fixed-point values
+func (p *FTSOPrice) UnmarshalJSON(data []byte) error {
+	type plain FTSOPrice
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	if p.Value == nil {
+		p.Value, p.Decimals = legacyValue(p.Price)
+	}
+	return nil
 }
 
 // PricePoint represents a single price point in history
 type PricePoint struct {
-	Price     float64 `json:"price"`
-	Timestamp int64   `json:"timestamp"`
-	BlockNum  uint64  `json:"blockNum"`
+	Value         *big.Int    `json:"value"`
+	Decimals      int8        `json:"decimals"`
+	Price         json.Number `json:"price"` // Value rendered as a decimal number
+	Timestamp     int64       `json:"timestamp"`
+	BlockNum      uint64      `json:"blockNum"`
+	VotingRoundID uint64      `json:"votingRoundId,omitempty"`
+}
+
+// Float64 returns the price as a float64 (for display and simulation)
+func (p *PricePoint) Float64() float64 {
+	return valueFloat(p.Value, p.Decimals)
+}
+
+// UnmarshalJSON decodes a price point, converting the float prices stored by
+// earlier versions to fixed-point values
+func (p *PricePoint) UnmarshalJSON(data []byte) error {
+	type plain PricePoint
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	if p.Value == nil {
+		p.Value, p.Decimals = legacyValue(p.Price)
+	}
+	return nil
 }
 
 // FTSOPriceHistory represents the full price history for an asset
 type FTSOPriceHistory struct {
-	Asset  string       `json:"asset"`
-	Latest *FTSOPrice   `json:"latest"`
-	History []PricePoint `json:"history"`
+	Asset     string       `json:"asset"`
+	Latest    *FTSOPrice   `json:"latest"`
+	History   []PricePoint `json:"history"`
+	Retention int          `json:"retention"`
 }
 
-// SetPrice stores a price for the given asset and adds it to history
-func SetPrice(asset string, price float64) error {
-	chainInstance := chain.GetInstance()
-	var blockNum uint64
-	if chainInstance != nil {
-		blockNum = chainInstance.GetHeight()
+// historyRing returns the price history of an asset
+func historyRing(rw state.ReadWriter, asset string) *state.Ring[PricePoint] {
+	return state.NewRing[PricePoint](rw, "history:ftso:"+asset, DefaultHistoryRetention)
+}
+
+// SetPrice stores a price for the given asset and adds it to history, rounding it
+// to the feed's decimals, without waiting for a voting round (see SubmitPrice).
+// The write is recorded in the pending block body; the returned price carries the
+// number of the block that will include it.
+func SetPrice(asset string, price float64) (*FTSOPrice, error) {
+	if math.IsNaN(price) || math.IsInf(price, 0) {
+		return nil, fmt.Errorf("invalid price %v", price)
+	}
+	return setPrice(asset, new(big.Rat).SetFloat64(price), true, chain.Now().Unix())
+}
+
+// SetPriceDecimal is SetPrice with a decimal price ("65000.12"), which is stored
+// exactly; a price with more precision than the feed's decimals is rejected
+func SetPriceDecimal(asset string, price string) (*FTSOPrice, error) {
+	return SetPriceAt(asset, price, chain.Now().Unix())
+}
+
+// SetPriceAt is SetPriceDecimal with an explicit timestamp (used to seed history)
+func SetPriceAt(asset string, price string, now int64) (*FTSOPrice, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(price))
+	if !ok {
+		return nil, fmt.Errorf("invalid price %q", price)
+	}
+	return setPrice(asset, r, false, now)
+}
+
+// setPrice stores a price as a fixed-point value with the feed's decimals
+func setPrice(asset string, price *big.Rat, round bool, now int64) (*FTSOPrice, error) {
+	asset, err := ResolveAsset(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	var ftsoPrice FTSOPrice
+	err = chain.WithPendingBlock(func(blockNum uint64) error {
+		// Store the latest price and its history entry atomically
+		return state.Update(func(tx *state.Tx) error {
+			ftsoPrice, err = newPrice(tx, asset, price, round, now)
+			if err != nil {
+				return err
+			}
+			ftsoPrice.BlockNum = blockNum
+			return publish(tx, ftsoPrice)
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	now := time.Now().Unix()
-	ftsoPrice := FTSOPrice{
+	return &ftsoPrice, nil
+}
+
+// newPrice converts a price to a fixed-point value with the decimals of the asset
+func newPrice(rw state.ReadWriter, asset string, price *big.Rat, round bool, now int64) (FTSOPrice, error) {
+	decimals, err := readDecimals(rw, asset)
+	if err != nil {
+		return FTSOPrice{}, err
+	}
+	value, err := toValue(price, decimals, round)
+	if err != nil {
+		return FTSOPrice{}, err
+	}
+	return FTSOPrice{
 		Asset:     asset,
-		Price:     price,
+		Value:     value,
+		Decimals:  decimals,
+		Price:     json.Number(FormatValue(value, decimals)),
 		Timestamp: now,
-		BlockNum:  blockNum,
-	}
+	}, nil
+}
 
-	// Store latest price
-	latestKey := "ftso:" + asset + ":latest"
-	latestData, err := json.Marshal(ftsoPrice)
+// publish stores a price as the latest price of its asset and appends it to
+// history. Call it within Update of the block recorded in the price.
+func publish(tx *state.Tx, price FTSOPrice) error {
+	latestKey := "ftso:" + price.Asset + ":latest"
+	latestData, err := json.Marshal(price)
 	if err != nil {
 		return err
 	}
-	if err := state.Set(latestKey, latestData); err != nil {
+	if err := tx.Set(latestKey, latestData); err != nil {
 		return err
 	}
 
-	// Add to history
-	historyKey := "ftso:" + asset + ":history"
-	historyData, err := state.Get(historyKey)
+	// The ring drops the oldest point once full
+	err = historyRing(tx, price.Asset).Append(PricePoint{
+		Value:         price.Value,
+		Decimals:      price.Decimals,
+		Price:         price.Price,
+		Timestamp:     price.Timestamp,
+		BlockNum:      price.BlockNum,
+		VotingRoundID: price.VotingRoundID,
+	})
 	if err != nil {
 		return err
 	}
 
-	var history FTSOPriceHistory
-	if historyData != nil {
-		if err := json.Unmarshal(historyData, &history); err != nil {
-			// If unmarshal fails, start fresh
-			history = FTSOPriceHistory{
-				Asset:   asset,
-				History: []PricePoint{},
-			}
-		}
-	} else {
-		history = FTSOPriceHistory{
-			Asset:   asset,
-			History: []PricePoint{},
+	// Record under the state lock, so the last write recorded for the key is
+	// the one the state keeps
+	chain.RecordStateUpdate(latestKey, latestData)
+	return nil
+}
+
+// SetHistoryRetention sets how many historical prices are kept for an asset,
+// dropping the oldest ones if the history is longer
+func SetHistoryRetention(asset string, entries int) error {
+	asset, err := ResolveAsset(asset)
+	if err != nil {
+		return err
+	}
+	return chain.WithPendingBlock(func(uint64) error {
+		return state.Update(func(tx *state.Tx) error {
+			return historyRing(tx, asset).SetRetention(entries)
+		})
+	})
+}
+
+// legacyHistorySuffix ends the keys under which earlier versions stored the
+// whole price history of an asset as one value ("ftso:<asset>:history")
+const legacyHistorySuffix = ":history"
+
+// MigrateHistory moves price histories stored by earlier versions into the
+// assets' history rings, before any entries the rings already have, and removes
+// the old values. Histories that cannot be decoded are left in place. Returns
+// the number of assets migrated.
+func MigrateHistory() (int, error) {
+	var keys []string
+	err := state.IteratePrefix("ftso:", func(key string, _ []byte) bool {
+		if strings.HasSuffix(key, legacyHistorySuffix) {
+			keys = append(keys, key)
 		}
+		return true
+	})
+	if err != nil || len(keys) == 0 {
+		return 0, err
 	}
 
-	// Add new price point
-	history.Latest = &ftsoPrice
-	history.History = append(history.History, PricePoint{
-		Price:     price,
-		Timestamp: now,
-		BlockNum:  blockNum,
+	migrated := 0
+	err = chain.WithPendingBlock(func(uint64) error {
+		return state.Update(func(tx *state.Tx) error {
+			for _, key := range keys {
+				data, err := tx.Get(key)
+				if err != nil {
+					return err
+				}
+				var legacy struct {
+					History []PricePoint `json:"history"`
+				}
+				if err := json.Unmarshal(data, &legacy); err != nil {
+					utils.Error("Cannot migrate price history %s: %v", key, err)
+					continue
+				}
+
+				asset := strings.TrimSuffix(strings.TrimPrefix(key, "ftso:"), legacyHistorySuffix)
+				if err := historyRing(tx, asset).Prepend(legacy.History); err != nil {
+					return err
+				}
+				if err := tx.Delete(key); err != nil {
+					return err
+				}
+				migrated++
+			}
+			return nil
+		})
 	})
+	if err != nil {
+		return 0, err
+	}
+	return migrated, nil
+}
 
-	// Limit history size
-	if len(history.History) > MaxHistoryEntries {
-		history.History = history.History[len(history.History)-MaxHistoryEntries:]
+// GetPrice retrieves the latest price for the given asset: its block-latency
+// value when fast updates are enabled, otherwise its anchor value
+func GetPrice(asset string) (*FTSOPrice, error) {
+	asset, err := ResolveAsset(asset)
+	if err != nil {
+		return nil, err
 	}
+	return readPrice(asset, state.Get)
+}
 
-	// Store updated history
-	historyData, err = json.Marshal(history)
+// GetPriceAtBlock retrieves the price of the given asset as it was after the
+// given block was sealed
+func GetPriceAtBlock(asset string, number uint64) (*FTSOPrice, error) {
+	asset, err := ResolveAsset(asset)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return readPrice(asset, func(key string) ([]byte, error) {
+		return state.GetAt(key, number)
+	})
+}
 
-	return state.Set(historyKey, historyData)
+// readPrice decodes the block-latency value of an asset, or its anchor value
+// without fast updates, using the given state reader
+func readPrice(asset string, get func(key string) ([]byte, error)) (*FTSOPrice, error) {
+	fast, err := decodePrice(fastKey(asset), get)
+	if err != nil || fast != nil {
+		return fast, err
+	}
+	return readAnchor(asset, get)
 }
 
-// GetPrice retrieves the latest price for the given asset
-func GetPrice(asset string) (*FTSOPrice, error) {
+// readAnchor decodes the latest anchor value of an asset using the given state reader
+func readAnchor(asset string, get func(key string) ([]byte, error)) (*FTSOPrice, error) {
 	key := "ftso:" + asset + ":latest"
-	data, err := state.Get(key)
+	data, err := get(key)
 	if err != nil {
 		return nil, err
 	}
@@ -129,85 +313,133 @@ func GetPrice(asset string) (*FTSOPrice, error) {
 
 // GetPriceAt retrieves the price at or before the given timestamp
 func GetPriceAt(asset string, timestamp int64) (*PricePoint, error) {
-	history, err := GetPriceHistory(asset)
+	asset, err := ResolveAsset(asset)
 	if err != nil {
 		return nil, err
 	}
+	ring := historyRing(state.GlobalState, asset)
 
-	if history == nil || len(history.History) == 0 {
-		return nil, nil
-	}
-
-	// Find the price point at or before the timestamp
 	// History is stored chronologically, so we can do a binary search
-	idx := sort.Search(len(history.History), func(i int) bool {
-		return history.History[i].Timestamp > timestamp
+	idx, err := ring.Search(func(point PricePoint) bool {
+		return point.Timestamp > timestamp
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	if idx == 0 {
-		// All prices are after the requested timestamp
+		// No history, or all prices are after the requested timestamp
 		return nil, nil
 	}
 
 	// Return the price point just before the first one after the timestamp
-	return &history.History[idx-1], nil
+	point, err := ring.At(idx - 1)
+	if err != nil {
+		return nil, err
+	}
+	return &point, nil
 }
 
 // GetPriceHistory retrieves the full price history for an asset
 func GetPriceHistory(asset string) (*FTSOPriceHistory, error) {
-	key := "ftso:" + asset + ":history"
-	data, err := state.Get(key)
+	asset, err := ResolveAsset(asset)
 	if err != nil {
 		return nil, err
 	}
 
-	if data == nil {
-		return nil, nil
+	latest, err := readAnchor(asset, state.Get)
+	if err != nil {
+		return nil, err
 	}
 
-	var history FTSOPriceHistory
-	err = json.Unmarshal(data, &history)
+	ring := historyRing(state.GlobalState, asset)
+	points, err := ring.Last(0)
+	if err != nil {
+		return nil, err
+	}
+	retention, err := ring.Retention()
 	if err != nil {
 		return nil, err
 	}
 
-	return &history, nil
+	if latest == nil && len(points) == 0 {
+		return nil, nil
+	}
+
+	return &FTSOPriceHistory{
+		Asset:     asset,
+		Latest:    latest,
+		History:   points,
+		Retention: retention,
+	}, nil
+}
+
+// GetRecentPrices retrieves the newest limit price points of an asset, oldest first
+func GetRecentPrices(asset string, limit int) ([]PricePoint, error) {
+	asset, err := ResolveAsset(asset)
+	if err != nil {
+		return nil, err
+	}
+	return historyRing(state.GlobalState, asset).Last(limit)
 }
 
 // GetPriceHistoryRange retrieves price history within a time range
 func GetPriceHistoryRange(asset string, fromTimestamp, toTimestamp int64) ([]PricePoint, error) {
-	history, err := GetPriceHistory(asset)
+	asset, err := ResolveAsset(asset)
 	if err != nil {
 		return nil, err
 	}
+	ring := historyRing(state.GlobalState, asset)
 
-	if history == nil {
-		return []PricePoint{}, nil
+	from, err := ring.Search(func(point PricePoint) bool {
+		return point.Timestamp >= fromTimestamp
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	var result []PricePoint
-	for _, point := range history.History {
-		if point.Timestamp >= fromTimestamp && point.Timestamp <= toTimestamp {
-			result = append(result, point)
-		}
+	to, err := ring.Search(func(point PricePoint) bool {
+		return point.Timestamp > toTimestamp
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	return ring.Slice(from, to)
 }
 
-// GetAllPrices retrieves all FTSO prices from state
+// GetAllPrices retrieves the latest price of every asset from state, preferring
+// block-latency values like GetPrice
 func GetAllPrices() (map[string]*FTSOPrice, error) {
-	allKeys := state.GlobalState.GetAllKeys()
 	prices := make(map[string]*FTSOPrice)
+	err := state.IteratePrefix("ftso:", func(key string, value []byte) bool {
+		asset, ok := strings.CutSuffix(strings.TrimPrefix(key, "ftso:"), ":latest")
+		if !ok {
+			return true
+		}
+		var price FTSOPrice
+		if err := json.Unmarshal(value, &price); err == nil {
+			prices[asset] = &price
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	for _, key := range allKeys {
-		if len(key) > 13 && key[:13] == "ftso:" && key[len(key)-7:] == ":latest" {
-			asset := key[5 : len(key)-7]
-			price, err := GetPrice(asset)
-			if err == nil && price != nil {
-				prices[asset] = price
-			}
+	// Block-latency values replace the anchor values
+	err = state.IteratePrefix("fast:ftso:", func(key string, value []byte) bool {
+		asset := strings.TrimPrefix(key, "fast:ftso:")
+		if strings.Contains(asset, ":") {
+			return true
+		}
+		var price FTSOPrice
+		if err := json.Unmarshal(value, &price); err == nil {
+			prices[asset] = &price
 		}
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return prices, nil
@@ -0,0 +1,51 @@
+package ftso
+
+import (
+	"lfts/internal/state"
+	"testing"
+)
+
+func TestMigrateHistory(t *testing.T) {
+	useMemoryState(t)
+
+	// Earlier versions stored float prices in one value per asset
+	legacy := `{"asset":"BTC","latest":{"asset":"BTC","price":60000.5,"timestamp":3},` +
+		`"history":[{"price":59000,"timestamp":1,"blockNum":1},{"price":60000.5,"timestamp":2,"blockNum":2}]}`
+	state.GlobalState.Set("ftso:BTC:history", []byte(legacy))
+	state.GlobalState.Set("ftso:ETH:history", []byte("not json"))
+	if err := historyRing(state.GlobalState, "BTC").Append(PricePoint{Price: "61000", Timestamp: 3, BlockNum: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := MigrateHistory()
+	if err != nil || n != 1 {
+		t.Fatalf("MigrateHistory() = %d, %v; want 1", n, err)
+	}
+
+	points, err := historyRing(state.GlobalState, "BTC").Last(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var timestamps []int64
+	for _, p := range points {
+		timestamps = append(timestamps, p.Timestamp)
+	}
+	if len(points) != 3 || timestamps[0] != 1 || timestamps[1] != 2 || timestamps[2] != 3 {
+		t.Fatalf("history timestamps = %v, want [1 2 3]", timestamps)
+	}
+	if p := points[1]; p.Value == nil || p.Float64() != 60000.5 {
+		t.Errorf("migrated point = %+v, want the legacy float converted to 60000.5", p)
+	}
+
+	if state.GlobalState.Has("ftso:BTC:history") {
+		t.Error("legacy history still stored after the migration")
+	}
+	if !state.GlobalState.Has("ftso:ETH:history") {
+		t.Error("undecodable legacy history was removed")
+	}
+
+	// Nothing is left to migrate
+	if n, err := MigrateHistory(); err != nil || n != 0 {
+		t.Errorf("second MigrateHistory() = %d, %v; want 0", n, err)
+	}
+}
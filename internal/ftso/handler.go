@@ -2,20 +2,62 @@ package ftso
 
 import (
 	"encoding/json"
+	"lfts/internal/chain"
+	"lfts/internal/feedid"
 	"net/http"
 	"strconv"
+	"time"
 )
 
-// HandlePrice handles GET /ftso/price?asset=<asset>
+// assetParam reads the asset parameter, which may be an asset symbol ("BTC"), a
+// crypto feed name ("BTC/USD") or a bytes21 feed ID, and writes a 400 response
+// if it is missing or invalid
+func assetParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	asset := r.URL.Query().Get("asset")
+	if asset == "" {
+		http.Error(w, "Missing asset parameter", http.StatusBadRequest)
+		return "", false
+	}
+	if _, err := ResolveAsset(asset); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return asset, true
+}
+
+// HandlePrice handles GET /ftso/price?asset=<asset>[&block=<number|hash|tag>]
 func HandlePrice(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	asset := r.URL.Query().Get("asset")
-	if asset == "" {
-		http.Error(w, "Missing asset parameter", http.StatusBadRequest)
+	asset, ok := assetParam(w, r)
+	if !ok {
+		return
+	}
+
+	// Check if block parameter is provided for the price as of that block
+	if blockStr := r.URL.Query().Get("block"); blockStr != "" {
+		number, err := chain.ResolveBlockNumber(blockStr)
+		if err != nil {
+			http.Error(w, err.Error(), chain.ReadErrorStatus(err))
+			return
+		}
+
+		price, err := GetPriceAtBlock(asset, number)
+		if err != nil {
+			http.Error(w, err.Error(), chain.ReadErrorStatus(err))
+			return
+		}
+
+		if price == nil {
+			http.Error(w, "Price not found for asset at block: "+asset, http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(price)
 		return
 	}
 
@@ -59,6 +101,87 @@ func HandlePrice(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(price)
 }
 
+// HandleAnchor handles GET /ftso/anchor?asset=<asset>[&block=<number|hash|tag>],
+// which returns the anchor value published by the last finalized voting round,
+// without fast updates
+func HandleAnchor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	asset, ok := assetParam(w, r)
+	if !ok {
+		return
+	}
+
+	var price *FTSOPrice
+	var err error
+	if blockStr := r.URL.Query().Get("block"); blockStr != "" {
+		number, resolveErr := chain.ResolveBlockNumber(blockStr)
+		if resolveErr != nil {
+			http.Error(w, resolveErr.Error(), chain.ReadErrorStatus(resolveErr))
+			return
+		}
+		price, err = GetAnchorPriceAtBlock(asset, number)
+	} else {
+		price, err = GetAnchorPrice(asset)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), chain.ReadErrorStatus(err))
+		return
+	}
+	if price == nil {
+		http.Error(w, "Anchor value not found for asset: "+asset, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(price)
+}
+
+// HandleFastUpdates handles GET /ftso/fast-updates, which returns the fast-update
+// configuration, and POST /ftso/fast-updates?submitters=<n>&precision=<percent>,
+// which changes it (omitted parameters keep their value)
+func HandleFastUpdates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		query := r.URL.Query()
+		config := FastUpdates()
+		if submitters := query.Get("submitters"); submitters != "" {
+			n, err := strconv.Atoi(submitters)
+			if err != nil {
+				http.Error(w, "Invalid submitters parameter", http.StatusBadRequest)
+				return
+			}
+			config.Submitters = n
+		}
+		if precision := query.Get("precision"); precision != "" {
+			p, err := strconv.ParseFloat(precision, 64)
+			if err != nil {
+				http.Error(w, "Invalid precision parameter", http.StatusBadRequest)
+				return
+			}
+			config.Precision = p
+		}
+		if err := config.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := SetFastUpdates(config); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FastUpdates())
+}
+
 // HandlePriceHistory handles GET /ftso/history?asset=<asset>&from=<timestamp>&to=<timestamp>
 func HandlePriceHistory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -66,9 +189,8 @@ func HandlePriceHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	asset := r.URL.Query().Get("asset")
-	if asset == "" {
-		http.Error(w, "Missing asset parameter", http.StatusBadRequest)
+	asset, ok := assetParam(w, r)
+	if !ok {
 		return
 	}
 
@@ -84,25 +206,13 @@ func HandlePriceHistory(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		history, err := GetPriceHistory(asset)
+		// Return last N prices
+		result, err := GetRecentPrices(asset, limit)
 		if err != nil {
 			http.Error(w, "Error retrieving price history", http.StatusInternalServerError)
 			return
 		}
 
-		if history == nil || len(history.History) == 0 {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode([]PricePoint{})
-			return
-		}
-
-		// Return last N prices
-		start := len(history.History) - limit
-		if start < 0 {
-			start = 0
-		}
-		result := history.History[start:]
-
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(result)
 		return
@@ -142,9 +252,11 @@ func HandlePriceHistory(w http.ResponseWriter, r *http.Request) {
 
 	if history == nil {
 		w.Header().Set("Content-Type", "application/json")
+		symbol, _ := ResolveAsset(asset)
 		json.NewEncoder(w).Encode(FTSOPriceHistory{
-			Asset:   asset,
-			History: []PricePoint{},
+			Asset:     symbol,
+			History:   []PricePoint{},
+			Retention: DefaultHistoryRetention,
 		})
 		return
 	}
@@ -153,3 +265,248 @@ func HandlePriceHistory(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(history)
 }
 
+// HandleRetention handles POST /ftso/retention?asset=<asset>&entries=<n> and sets
+// how many historical prices are kept for the asset
+func HandleRetention(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	asset, ok := assetParam(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := strconv.Atoi(r.URL.Query().Get("entries"))
+	if err != nil || entries <= 0 {
+		http.Error(w, "Invalid entries parameter", http.StatusBadRequest)
+		return
+	}
+
+	if err := SetHistoryRetention(asset, entries); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	symbol, _ := ResolveAsset(asset)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"asset":     symbol,
+		"retention": entries,
+	})
+}
+
+// HandleDecimals handles GET /ftso/decimals?asset=<asset> and
+// POST /ftso/decimals?asset=<asset>&decimals=<n>, which sets the decimals of new
+// values of the feed
+func HandleDecimals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	asset, ok := assetParam(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		decimals, err := strconv.ParseInt(r.URL.Query().Get("decimals"), 10, 8)
+		if err != nil {
+			http.Error(w, "Invalid decimals parameter (expected -128 to 127)", http.StatusBadRequest)
+			return
+		}
+		if err := SetDecimals(asset, int8(decimals)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	decimals, err := GetDecimals(asset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	symbol, _ := ResolveAsset(asset)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"asset":    symbol,
+		"decimals": decimals,
+	})
+}
+
+// HandleRound handles GET /ftso/round, which returns the current voting round
+// and the values pending for it, and POST /ftso/round?epoch=<seconds>, which
+// changes the voting epoch length (0 publishes values as soon as they are
+// submitted)
+func HandleRound(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		epoch, err := strconv.ParseInt(r.URL.Query().Get("epoch"), 10, 64)
+		if err != nil || epoch < 0 {
+			http.Error(w, "Invalid epoch parameter (expected seconds, 0 to disable voting rounds)", http.StatusBadRequest)
+			return
+		}
+		if err := SetVotingEpoch(time.Duration(epoch) * time.Second); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	pending, err := GetPendingPrices()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if pending == nil {
+		pending = []*FTSOPrice{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"votingEpoch": int64(VotingEpoch().Seconds()),
+		"round":       CurrentRound(),
+		"pending":     pending,
+	})
+}
+
+// HandleProviders handles GET /ftso/providers, which lists the simulated data
+// providers, POST /ftso/providers?name=<name>&weight=<n>&strategy=<strategy>
+// (&bias=<percent>&lag=<rounds>), which adds or replaces a provider, and
+// DELETE /ftso/providers?name=<name>
+func HandleProviders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		provider := Provider{Name: query.Get("name"), Strategy: Strategy(query.Get("strategy"))}
+		if provider.Strategy == "" {
+			provider.Strategy = StrategyHonest
+		}
+		var err error
+		if provider.Weight, err = strconv.ParseUint(query.Get("weight"), 10, 64); err != nil {
+			http.Error(w, "Invalid weight parameter", http.StatusBadRequest)
+			return
+		}
+		if bias := query.Get("bias"); bias != "" {
+			if provider.Bias, err = strconv.ParseFloat(bias, 64); err != nil {
+				http.Error(w, "Invalid bias parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if lag := query.Get("lag"); lag != "" {
+			if provider.Lag, err = strconv.ParseUint(lag, 10, 64); err != nil {
+				http.Error(w, "Invalid lag parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := SetProvider(provider); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		removed, err := RemoveProvider(query.Get("name"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !removed {
+			http.Error(w, "Provider not found: "+query.Get("name"), http.StatusNotFound)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	providers, err := Providers()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"providers": providers,
+	})
+}
+
+// HandleSubmissions handles GET /ftso/submissions?asset=<asset>(&round=<id>),
+// which returns the provider submissions, weighted median and reward bands of a
+// voting round (the latest finalized round by default)
+func HandleSubmissions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	asset, ok := assetParam(w, r)
+	if !ok {
+		return
+	}
+
+	var result *RoundResult
+	var err error
+	if roundStr := r.URL.Query().Get("round"); roundStr != "" {
+		round, parseErr := strconv.ParseUint(roundStr, 10, 64)
+		if parseErr != nil {
+			http.Error(w, "Invalid round parameter", http.StatusBadRequest)
+			return
+		}
+		result, err = GetRoundResult(asset, round)
+	} else {
+		result, err = GetLatestRoundResult(asset)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result == nil {
+		http.Error(w, "No provider submissions for asset: "+asset, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// HandleFeeds handles GET /ftso/feeds, which lists the feed registry, and
+// POST /ftso/feeds?category=<category>&name=<feed_name>&asset=<asset>, which maps
+// a feed to the asset symbol its values are stored under
+func HandleFeeds(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		feeds, err := Feeds()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"feeds": feeds,
+		})
+	case http.MethodPost:
+		category, err := feedid.ParseCategory(r.URL.Query().Get("category"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		feed, err := RegisterFeed(category, r.URL.Query().Get("name"), r.URL.Query().Get("asset"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(feed)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,571 @@
+package ftso
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/state"
+	"lfts/internal/utils"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SecondaryBandPPM is the half-width of the secondary reward band around the
+// median, in parts per million (±0.5%)
+const SecondaryBandPPM = 5000
+
+// Strategy is how a simulated data provider derives its value from the value
+// submitted to the round
+type Strategy string
+
+const (
+	// StrategyHonest submits the value as is
+	StrategyHonest Strategy = "honest"
+	// StrategyBiased submits the value shifted by the provider's bias
+	StrategyBiased Strategy = "biased"
+	// StrategyLagging submits the value of an earlier round
+	StrategyLagging Strategy = "lagging"
+	// StrategyOffline submits nothing
+	StrategyOffline Strategy = "offline"
+)
+
+// ParseStrategy validates a strategy name
+func ParseStrategy(s string) (Strategy, error) {
+	switch Strategy(s) {
+	case StrategyHonest, StrategyBiased, StrategyLagging, StrategyOffline:
+		return Strategy(s), nil
+	default:
+		return "", fmt.Errorf("invalid strategy %q (expected honest, biased, lagging or offline)", s)
+	}
+}
+
+// Provider is a simulated FTSO data provider. Every provider commits a value for
+// each feed that has a value submitted in a voting round and reveals it when the
+// round ends; the published value is the weighted median of the reveals.
+type Provider struct {
+	Name     string   `json:"name"`
+	Weight   uint64   `json:"weight"`
+	Strategy Strategy `json:"strategy"`
+	Bias     float64  `json:"bias,omitempty"` // percent added to the value (biased)
+	Lag      uint64   `json:"lag,omitempty"`  // rounds behind (lagging, default 1)
+}
+
+// Validate checks the provider settings
+func (p *Provider) Validate() error {
+	if p.Name == "" || strings.Contains(p.Name, ":") {
+		return fmt.Errorf("invalid provider name %q", p.Name)
+	}
+	if p.Weight == 0 {
+		return fmt.Errorf("provider %s: weight must be positive", p.Name)
+	}
+	if _, err := ParseStrategy(string(p.Strategy)); err != nil {
+		return fmt.Errorf("provider %s: %v", p.Name, err)
+	}
+	if math.IsNaN(p.Bias) || math.IsInf(p.Bias, 0) || p.Bias <= -100 {
+		return fmt.Errorf("provider %s: bias must be greater than -100%%", p.Name)
+	}
+	return nil
+}
+
+// lag returns how many rounds a lagging provider is behind
+func (p *Provider) lag() uint64 {
+	if p.Lag == 0 {
+		return 1
+	}
+	return p.Lag
+}
+
+// providerKey returns the state key of a simulated data provider
+func providerKey(name string) string {
+	return "provider:ftso:" + name
+}
+
+// SetProvider adds a simulated data provider or replaces the one with the same
+// name. Changes apply to values submitted afterwards; providers that already
+// committed in the current round reveal what they committed.
+func SetProvider(p Provider) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return chain.WithPendingBlock(func(uint64) error {
+		return state.Update(func(tx *state.Tx) error {
+			return tx.Set(providerKey(p.Name), data)
+		})
+	})
+}
+
+// RemoveProvider removes a simulated data provider and reports whether it existed
+func RemoveProvider(name string) (bool, error) {
+	data, err := state.Get(providerKey(name))
+	if err != nil || data == nil {
+		return false, err
+	}
+	err = chain.WithPendingBlock(func(uint64) error {
+		return state.Update(func(tx *state.Tx) error {
+			return tx.Delete(providerKey(name))
+		})
+	})
+	return err == nil, err
+}
+
+// Providers returns the simulated data providers ordered by name
+func Providers() ([]Provider, error) {
+	list := []Provider{}
+	var decodeErr error
+	err := state.IteratePrefix("provider:ftso:", func(key string, value []byte) bool {
+		var p Provider
+		if decodeErr = json.Unmarshal(value, &p); decodeErr != nil {
+			return false
+		}
+		list = append(list, p)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Submission is a data provider's submission for a feed in a voting round
+type Submission struct {
+	Provider      string      `json:"provider"`
+	Weight        uint64      `json:"weight"`
+	Strategy      Strategy    `json:"strategy"`
+	Commit        string      `json:"commit,omitempty"` // hash committed during the round (empty if nothing was submitted)
+	Sealed        string      `json:"sealed,omitempty"` // value and random sealed with the node's reveal key until the round ends
+	Value         *big.Int    `json:"value,omitempty"`  // revealed after the round ends
+	Price         json.Number `json:"price,omitempty"`
+	Random        string      `json:"random,omitempty"`
+	Revealed      bool        `json:"revealed"` // the reveal matches the commit
+	PrimaryBand   bool        `json:"primaryBand"`
+	SecondaryBand bool        `json:"secondaryBand"`
+}
+
+// Band is a range of values, both ends included
+type Band struct {
+	Low  *big.Int `json:"low"`
+	High *big.Int `json:"high"`
+}
+
+// contains reports whether the value lies within the band
+func (b *Band) contains(value *big.Int) bool {
+	return value.Cmp(b.Low) >= 0 && value.Cmp(b.High) <= 0
+}
+
+// RoundResult records how the providers determined a feed's value in a voting
+// round. Until the round is finalized, submissions only carry their commits.
+type RoundResult struct {
+	Asset         string       `json:"asset"`
+	VotingRoundID uint64       `json:"votingRoundId"`
+	Finalized     bool         `json:"finalized"`
+	Decimals      int8         `json:"decimals"`
+	Reference     *big.Int     `json:"reference"`       // value submitted to the round, observed by the providers
+	Value         *big.Int     `json:"value,omitempty"` // weighted median of the reveals (none if nothing was revealed)
+	Price         json.Number  `json:"price,omitempty"`
+	TotalWeight   uint64       `json:"totalWeight"`             // weight of the revealed submissions
+	PrimaryBand   *Band        `json:"primaryBand,omitempty"`   // weighted interquartile range
+	SecondaryBand *Band        `json:"secondaryBand,omitempty"` // median ± SecondaryBandPPM
+	Submissions   []Submission `json:"submissions"`
+}
+
+// commitsKey returns the state key of the provider commits for a feed in a round
+func commitsKey(asset string, round uint64) string {
+	return fmt.Sprintf("commits:ftso:%s:%d", asset, round)
+}
+
+// resultsKey returns the state key of the finalized round results of an asset
+func resultsKey(asset string) string {
+	return "rounds:ftso:" + asset
+}
+
+// resultsRing returns the finalized round results of an asset
+func resultsRing(rw state.ReadWriter, asset string) *state.Ring[RoundResult] {
+	return state.NewRing[RoundResult](rw, resultsKey(asset), DefaultHistoryRetention)
+}
+
+// recordRingWrites records the writes tx made to the ring stored at key (its
+// header and entry slots) in the pending block body
+func recordRingWrites(tx *state.Tx, key string) {
+	for _, w := range tx.Writes() {
+		if w.Key == key || strings.HasPrefix(w.Key, key+":") {
+			chain.RecordStateUpdate(w.Key, w.Value)
+		}
+	}
+}
+
+// revealKeySize is the size of the AES-256 key sealing provider secrets
+const revealKeySize = 32
+
+var (
+	// revealKeyMu guards revealKey
+	revealKeyMu sync.Mutex
+
+	// revealKey seals the value and random behind each commit, so they can be
+	// kept in the state (and survive reverts, reorgs and restarts) without being
+	// readable before the round is finalized. Generated on first use unless
+	// LoadRevealKey is called.
+	revealKey []byte
+)
+
+// LoadRevealKey reads the key sealing provider secrets from path, creating the
+// file with a new key if it does not exist, so a restarted node can still reveal
+// the commits made before the restart
+func LoadRevealKey(path string) error {
+	key, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key = make([]byte, revealKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, key, 0600); err != nil {
+			return fmt.Errorf("failed to write reveal key: %v", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to read reveal key: %v", err)
+	}
+	if len(key) != revealKeySize {
+		return fmt.Errorf("invalid reveal key in %s: expected %d bytes, got %d", path, revealKeySize, len(key))
+	}
+
+	revealKeyMu.Lock()
+	defer revealKeyMu.Unlock()
+	revealKey = key
+	return nil
+}
+
+// revealCipher returns the AEAD sealing provider secrets, generating the reveal
+// key if none was loaded
+func revealCipher() (cipher.AEAD, error) {
+	revealKeyMu.Lock()
+	defer revealKeyMu.Unlock()
+	if revealKey == nil {
+		key := make([]byte, revealKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		revealKey = key
+	}
+	block, err := aes.NewCipher(revealKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSecret encrypts the random and value behind a commit, bound to the commit
+// hash, as 0x-prefixed hex of nonce || ciphertext
+func sealSecret(commit string, value *big.Int, random []byte) (string, error) {
+	aead, err := revealCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	plaintext := append(lengthPrefixed(random), value.Bytes()...)
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(commit))
+	return "0x" + hex.EncodeToString(sealed), nil
+}
+
+// openSecret decrypts a secret sealed by sealSecret for the given commit hash
+func openSecret(commit, sealed string) (*big.Int, []byte, error) {
+	aead, err := revealCipher()
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(sealed, "0x"))
+	if err != nil || len(data) < aead.NonceSize() {
+		return nil, nil, fmt.Errorf("invalid sealed secret")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(commit))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(plaintext) < 4 {
+		return nil, nil, fmt.Errorf("invalid sealed secret")
+	}
+	n := binary.BigEndian.Uint32(plaintext)
+	if uint64(n) > uint64(len(plaintext)-4) {
+		return nil, nil, fmt.Errorf("invalid sealed secret")
+	}
+	random := plaintext[4 : 4+n]
+	return new(big.Int).SetBytes(plaintext[4+n:]), random, nil
+}
+
+// commitHash returns keccak256 of the provider, round, asset, value and random,
+// each variable-length field prefixed with its length
+func commitHash(provider string, round uint64, asset string, value *big.Int, random []byte) string {
+	var roundBytes [8]byte
+	binary.BigEndian.PutUint64(roundBytes[:], round)
+	hash := utils.Keccak256(
+		lengthPrefixed([]byte(provider)),
+		roundBytes[:],
+		lengthPrefixed([]byte(asset)),
+		lengthPrefixed(value.Bytes()),
+		lengthPrefixed(random),
+	)
+	return "0x" + hex.EncodeToString(hash[:])
+}
+
+// lengthPrefixed returns data preceded by its 4-byte big-endian length
+func lengthPrefixed(data []byte) []byte {
+	out := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(out, uint32(len(data)))
+	return append(out, data...)
+}
+
+// commitProviders has every provider in list commit a value derived from a
+// price submitted to a round, replacing earlier commits for the round. The
+// values and randoms are stored sealed next to the commits until the reveal.
+// Without providers the price is published as is.
+func commitProviders(tx *state.Tx, list []Provider, price FTSOPrice) error {
+	key := commitsKey(price.Asset, price.VotingRoundID)
+	if len(list) == 0 {
+		if !tx.Has(key) {
+			return nil
+		}
+		if err := tx.Delete(key); err != nil {
+			return err
+		}
+		chain.RecordStateUpdate(key, nil)
+		return nil
+	}
+
+	result := RoundResult{
+		Asset:         price.Asset,
+		VotingRoundID: price.VotingRoundID,
+		Decimals:      price.Decimals,
+		Reference:     price.Value,
+	}
+	for _, p := range list {
+		submission := Submission{Provider: p.Name, Weight: p.Weight, Strategy: p.Strategy}
+		value, err := providerValue(tx, p, price)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			random := make([]byte, 32)
+			if _, err := rand.Read(random); err != nil {
+				return err
+			}
+			submission.Commit = commitHash(p.Name, price.VotingRoundID, price.Asset, value, random)
+			if submission.Sealed, err = sealSecret(submission.Commit, value, random); err != nil {
+				return err
+			}
+		}
+		result.Submissions = append(result.Submissions, submission)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := tx.Set(key, data); err != nil {
+		return err
+	}
+	chain.RecordStateUpdate(key, data)
+	return nil
+}
+
+// providerValue returns the value a provider submits for a price (nil if none)
+func providerValue(tx *state.Tx, p Provider, price FTSOPrice) (*big.Int, error) {
+	switch p.Strategy {
+	case StrategyHonest:
+		return new(big.Int).Set(price.Value), nil
+
+	case StrategyBiased:
+		// Parse the bias as decimal text so 2% shifts by exactly 2%
+		bias, _ := new(big.Rat).SetString(strconv.FormatFloat(p.Bias, 'f', -1, 64))
+		factor := new(big.Rat).Add(big.NewRat(1, 1), bias.Quo(bias, big.NewRat(100, 1)))
+		scaled := new(big.Rat).Mul(new(big.Rat).SetInt(price.Value), factor)
+		return new(big.Int).Quo(scaled.Num(), scaled.Denom()), nil
+
+	case StrategyLagging:
+		// The value submitted lag rounds ago, if the feed had one
+		if price.VotingRoundID < p.lag() {
+			return nil, nil
+		}
+		target := price.VotingRoundID - p.lag()
+		ring := resultsRing(tx, price.Asset)
+		idx, err := ring.Search(func(r RoundResult) bool { return r.VotingRoundID > target })
+		if err != nil || idx == 0 {
+			return nil, err
+		}
+		earlier, err := ring.At(idx - 1)
+		if err != nil {
+			return nil, err
+		}
+		return ScaleValue(earlier.Reference, earlier.Decimals, price.Decimals), nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// revealProviders reveals the commits for a price whose round ended, computes
+// the weighted median and reward bands, and records the result. Commits whose
+// secret cannot be unsealed (such as those imported from another node's dump)
+// or does not match the commit are not counted. It returns nil if no provider
+// committed for the round.
+func revealProviders(tx *state.Tx, price FTSOPrice) (*RoundResult, error) {
+	key := commitsKey(price.Asset, price.VotingRoundID)
+	data, err := tx.Get(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var result RoundResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	var revealed []*Submission
+	for i := range result.Submissions {
+		s := &result.Submissions[i]
+		if s.Commit == "" {
+			continue
+		}
+		value, random, err := openSecret(s.Commit, s.Sealed)
+		s.Sealed = ""
+		if err != nil {
+			utils.Error("Voting round %d: cannot unseal the %s commit of %s: %v", result.VotingRoundID, result.Asset, s.Provider, err)
+			continue
+		}
+		if commitHash(s.Provider, result.VotingRoundID, result.Asset, value, random) != s.Commit {
+			continue
+		}
+		s.Value = value
+		s.Price = json.Number(FormatValue(value, result.Decimals))
+		s.Random = "0x" + hex.EncodeToString(random)
+		s.Revealed = true
+		revealed = append(revealed, s)
+		result.TotalWeight += s.Weight
+	}
+
+	if len(revealed) > 0 {
+		sort.SliceStable(revealed, func(i, j int) bool { return revealed[i].Value.Cmp(revealed[j].Value) < 0 })
+		median := weightedQuantile(revealed, result.TotalWeight, 1, 2)
+		result.Value = median
+		result.Price = json.Number(FormatValue(median, result.Decimals))
+		result.PrimaryBand = &Band{
+			Low:  weightedQuantile(revealed, result.TotalWeight, 1, 4),
+			High: weightedQuantile(revealed, result.TotalWeight, 3, 4),
+		}
+		width := new(big.Int).Quo(new(big.Int).Mul(median, big.NewInt(SecondaryBandPPM)), big.NewInt(1000000))
+		result.SecondaryBand = &Band{
+			Low:  new(big.Int).Sub(median, width),
+			High: new(big.Int).Add(median, width),
+		}
+		for _, s := range revealed {
+			s.PrimaryBand = result.PrimaryBand.contains(s.Value)
+			s.SecondaryBand = result.SecondaryBand.contains(s.Value)
+		}
+	}
+
+	result.Finalized = true
+	if err := resultsRing(tx, result.Asset).Append(result); err != nil {
+		return nil, err
+	}
+	recordRingWrites(tx, resultsKey(result.Asset))
+	if err := tx.Delete(key); err != nil {
+		return nil, err
+	}
+	chain.RecordStateUpdate(key, nil)
+	return &result, nil
+}
+
+// weightedQuantile returns the value at the num/den weighted quantile of
+// submissions sorted by value. When the quantile falls exactly between two
+// submissions, it returns their average. Returns nil without any weight.
+func weightedQuantile(sorted []*Submission, total uint64, num, den uint64) *big.Int {
+	if len(sorted) == 0 || total == 0 {
+		return nil
+	}
+	target := new(big.Int).Mul(new(big.Int).SetUint64(total), new(big.Int).SetUint64(num))
+	cumulative := new(big.Int)
+	for i, s := range sorted {
+		cumulative.Add(cumulative, new(big.Int).SetUint64(s.Weight))
+		scaled := new(big.Int).Mul(cumulative, new(big.Int).SetUint64(den))
+		switch scaled.Cmp(target) {
+		case 0:
+			if i+1 < len(sorted) {
+				sum := new(big.Int).Add(s.Value, sorted[i+1].Value)
+				return sum.Quo(sum, big.NewInt(2))
+			}
+			return new(big.Int).Set(s.Value)
+		case 1:
+			return new(big.Int).Set(s.Value)
+		}
+	}
+	return new(big.Int).Set(sorted[len(sorted)-1].Value)
+}
+
+// GetRoundResult returns how the providers determined a feed's value in a voting
+// round: the finalized result, or the commits of a round still running. Returns
+// nil if the feed had no provider submissions in the round.
+func GetRoundResult(asset string, round uint64) (*RoundResult, error) {
+	asset, err := ResolveAsset(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := state.Get(commitsKey(asset, round))
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		var result RoundResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, err
+		}
+		return &result, nil
+	}
+
+	ring := resultsRing(state.GlobalState, asset)
+	idx, err := ring.Search(func(r RoundResult) bool { return r.VotingRoundID >= round })
+	if err != nil {
+		return nil, err
+	}
+	n, err := ring.Len()
+	if err != nil || idx == n {
+		return nil, err
+	}
+	result, err := ring.At(idx)
+	if err != nil || result.VotingRoundID != round {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetLatestRoundResult returns the most recently finalized round result of a
+// feed, or nil if providers never determined its value
+func GetLatestRoundResult(asset string) (*RoundResult, error) {
+	asset, err := ResolveAsset(asset)
+	if err != nil {
+		return nil, err
+	}
+	results, err := resultsRing(state.GlobalState, asset).Last(1)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return &results[0], nil
+}
@@ -0,0 +1,303 @@
+package ftso
+
+import (
+	"encoding/json"
+	"lfts/internal/state"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// submissions returns sorted submissions with the given values and weights
+func submissions(values []int64, weights []uint64) ([]*Submission, uint64) {
+	var sorted []*Submission
+	var total uint64
+	for i, v := range values {
+		sorted = append(sorted, &Submission{Value: big.NewInt(v), Weight: weights[i]})
+		total += weights[i]
+	}
+	return sorted, total
+}
+
+func TestWeightedQuantile(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []int64
+		weights  []uint64
+		num, den uint64
+		want     *big.Int
+	}{
+		{"even weight split averages", []int64{100, 200}, []uint64{1, 1}, 1, 2, big.NewInt(150)},
+		{"even split of four", []int64{100, 200, 300, 400}, []uint64{1, 1, 1, 1}, 1, 2, big.NewInt(250)},
+		{"heavier provider wins", []int64{100, 200, 300}, []uint64{1, 3, 1}, 1, 2, big.NewInt(200)},
+		{"lower quartile", []int64{100, 200, 300, 400}, []uint64{1, 1, 1, 1}, 1, 4, big.NewInt(150)},
+		{"single provider", []int64{100}, []uint64{5}, 1, 2, big.NewInt(100)},
+		{"single provider upper quartile", []int64{100}, []uint64{5}, 3, 4, big.NewInt(100)},
+		{"all offline", nil, nil, 1, 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted, total := submissions(tt.values, tt.weights)
+			got := weightedQuantile(sorted, total, tt.num, tt.den)
+			if (got == nil) != (tt.want == nil) || (got != nil && got.Cmp(tt.want) != 0) {
+				t.Errorf("weightedQuantile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// useMemoryState replaces the global state with an empty in-memory storage for
+// the duration of a test
+func useMemoryState(t *testing.T) {
+	t.Helper()
+	previous := state.GlobalState
+	state.GlobalState = state.NewMemoryStorage()
+	t.Cleanup(func() { state.GlobalState = previous })
+}
+
+// runRound commits the providers for a price submitted to a round and reveals
+// them, as the round's submission and finalization do
+func runRound(t *testing.T, list []Provider, round uint64, value int64) *RoundResult {
+	t.Helper()
+	price := FTSOPrice{Asset: "BTC", Value: big.NewInt(value), Decimals: 2, VotingRoundID: round}
+	err := state.Update(func(tx *state.Tx) error {
+		return commitProviders(tx, list, price)
+	})
+	if err != nil {
+		t.Fatalf("commitProviders() error = %v", err)
+	}
+
+	var result *RoundResult
+	err = state.Update(func(tx *state.Tx) error {
+		result, err = revealProviders(tx, price)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("revealProviders() error = %v", err)
+	}
+	return result
+}
+
+func TestProviderRound(t *testing.T) {
+	useMemoryState(t)
+	list := []Provider{
+		{Name: "alice", Weight: 3, Strategy: StrategyHonest},
+		{Name: "bob", Weight: 1, Strategy: StrategyBiased, Bias: 5},
+		{Name: "carol", Weight: 2, Strategy: StrategyLagging},
+		{Name: "dave", Weight: 1, Strategy: StrategyOffline},
+	}
+
+	// carol has no earlier round to lag behind yet
+	runRound(t, list, 9, 9000)
+	result := runRound(t, list, 10, 10000)
+	if result == nil {
+		t.Fatal("revealProviders() = nil, want a result")
+	}
+
+	tests := []struct {
+		name string
+		got  *big.Int
+		want int64
+	}{
+		{"median", result.Value, 10000},
+		{"primary band low", result.PrimaryBand.Low, 9000},
+		{"primary band high", result.PrimaryBand.High, 10000},
+		{"secondary band low", result.SecondaryBand.Low, 9950},
+		{"secondary band high", result.SecondaryBand.High, 10050},
+	}
+	for _, tt := range tests {
+		if tt.got == nil || tt.got.Int64() != tt.want {
+			t.Errorf("%s = %v, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+	if !result.Finalized || result.TotalWeight != 6 || result.Price != "100" {
+		t.Errorf("result = finalized %v, weight %d, price %s; want true, 6, 100", result.Finalized, result.TotalWeight, result.Price)
+	}
+
+	submissions := []struct {
+		provider  string
+		revealed  bool
+		value     int64
+		primary   bool
+		secondary bool
+	}{
+		{"alice", true, 10000, true, true},
+		{"bob", true, 10500, false, false},
+		{"carol", true, 9000, true, false},
+		{"dave", false, 0, false, false},
+	}
+	if len(result.Submissions) != len(submissions) {
+		t.Fatalf("got %d submissions, want %d", len(result.Submissions), len(submissions))
+	}
+	for i, want := range submissions {
+		s := result.Submissions[i]
+		if s.Provider != want.provider || s.Revealed != want.revealed || s.PrimaryBand != want.primary || s.SecondaryBand != want.secondary {
+			t.Errorf("submission %d = %+v, want %+v", i, s, want)
+		}
+		if want.revealed && (s.Value == nil || s.Value.Int64() != want.value || s.Random == "") {
+			t.Errorf("%s revealed value %v (random %q), want %d", s.Provider, s.Value, s.Random, want.value)
+		}
+		if !want.revealed && (s.Commit != "" || s.Value != nil) {
+			t.Errorf("%s submitted commit %q, value %v; want none", s.Provider, s.Commit, s.Value)
+		}
+		if s.Sealed != "" {
+			t.Errorf("%s still has a sealed secret after the reveal", s.Provider)
+		}
+	}
+
+	// The result is recorded and the commits are gone
+	if latest, err := GetLatestRoundResult("BTC"); err != nil || latest == nil || latest.VotingRoundID != 10 {
+		t.Errorf("GetLatestRoundResult() = %v, %v; want round 10", latest, err)
+	}
+	if data, _ := state.Get(commitsKey("BTC", 10)); data != nil {
+		t.Errorf("commits of round 10 were not deleted")
+	}
+}
+
+func TestProviderCommitsHideValues(t *testing.T) {
+	useMemoryState(t)
+	price := FTSOPrice{Asset: "BTC", Value: big.NewInt(10000), Decimals: 2, VotingRoundID: 1}
+	err := state.Update(func(tx *state.Tx) error {
+		return commitProviders(tx, []Provider{{Name: "alice", Weight: 1, Strategy: StrategyHonest}}, price)
+	})
+	if err != nil {
+		t.Fatalf("commitProviders() error = %v", err)
+	}
+
+	result, err := GetRoundResult("BTC", 1)
+	if err != nil || result == nil {
+		t.Fatalf("GetRoundResult() = %v, %v; want the commits", result, err)
+	}
+	s := result.Submissions[0]
+	if result.Finalized || result.Value != nil || s.Commit == "" || s.Sealed == "" || s.Value != nil || s.Random != "" {
+		t.Errorf("running round exposes %+v, want only the commit and sealed secret", s)
+	}
+}
+
+func TestProviderRevealChecksCommit(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(s *Submission) error
+	}{
+		{"secret of another value", func(s *Submission) error {
+			var err error
+			s.Sealed, err = sealSecret(s.Commit, big.NewInt(1), make([]byte, 32))
+			return err
+		}},
+		{"secret sealed for another commit", func(s *Submission) error {
+			var err error
+			s.Sealed, err = sealSecret("0x00", big.NewInt(10000), make([]byte, 32))
+			return err
+		}},
+		{"missing secret", func(s *Submission) error {
+			s.Sealed = ""
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemoryState(t)
+			price := FTSOPrice{Asset: "BTC", Value: big.NewInt(10000), Decimals: 2, VotingRoundID: 1}
+			list := []Provider{
+				{Name: "alice", Weight: 1, Strategy: StrategyHonest},
+				{Name: "bob", Weight: 1, Strategy: StrategyBiased, Bias: 10},
+			}
+			err := state.Update(func(tx *state.Tx) error {
+				if err := commitProviders(tx, list, price); err != nil {
+					return err
+				}
+				data, _ := tx.Get(commitsKey("BTC", 1))
+				var result RoundResult
+				if err := json.Unmarshal(data, &result); err != nil {
+					return err
+				}
+				if err := tt.tamper(&result.Submissions[0]); err != nil {
+					return err
+				}
+				data, _ = json.Marshal(result)
+				return tx.Set(commitsKey("BTC", 1), data)
+			})
+			if err != nil {
+				t.Fatalf("commit error = %v", err)
+			}
+
+			var result *RoundResult
+			err = state.Update(func(tx *state.Tx) error {
+				result, err = revealProviders(tx, price)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("revealProviders() error = %v", err)
+			}
+			alice, bob := result.Submissions[0], result.Submissions[1]
+			if alice.Revealed || alice.Value != nil || alice.Price != "" || alice.Random != "" {
+				t.Errorf("tampered submission revealed %+v", alice)
+			}
+			if !bob.Revealed || result.Value == nil || result.Value.Int64() != 11000 || result.TotalWeight != 1 {
+				t.Errorf("result = %v (weight %d), want only bob's 11000", result.Value, result.TotalWeight)
+			}
+		})
+	}
+}
+
+func TestProviderRevealAfterRestore(t *testing.T) {
+	useMemoryState(t)
+	price := FTSOPrice{Asset: "BTC", Value: big.NewInt(10000), Decimals: 2, VotingRoundID: 1}
+	err := state.Update(func(tx *state.Tx) error {
+		return commitProviders(tx, []Provider{{Name: "alice", Weight: 1, Strategy: StrategyHonest}}, price)
+	})
+	if err != nil {
+		t.Fatalf("commitProviders() error = %v", err)
+	}
+	state.GlobalState.Commit(1)
+	snap := state.GlobalState.Snapshot()
+
+	// Finalize the round, then revert it as evm_revert or a reorg would
+	for i := 0; i < 2; i++ {
+		var result *RoundResult
+		err := state.Update(func(tx *state.Tx) error {
+			result, err = revealProviders(tx, price)
+			return err
+		})
+		if err != nil || result == nil || result.Value == nil || result.Value.Int64() != 10000 {
+			t.Fatalf("reveal %d = %v, %v; want 10000", i, result, err)
+		}
+		state.GlobalState.Restore(snap)
+	}
+}
+
+func TestFinalizeRoundsWithoutReveals(t *testing.T) {
+	useMemoryState(t)
+	if err := ConfigureRounds(0, 90*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		roundsMu.Lock()
+		rounds = nil
+		roundsMu.Unlock()
+	})
+
+	// The only provider is offline, so nothing is revealed
+	price := FTSOPrice{Asset: "BTC", Value: big.NewInt(10000), Decimals: 2, Price: "100", VotingRoundID: 1}
+	err := state.Update(func(tx *state.Tx) error {
+		data, _ := json.Marshal(price)
+		if err := tx.Set(pendingKey("BTC", 1), data); err != nil {
+			return err
+		}
+		return commitProviders(tx, []Provider{{Name: "dave", Weight: 1, Strategy: StrategyOffline}}, price)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := FinalizeRounds(2, 180); err != nil {
+		t.Fatalf("FinalizeRounds() error = %v", err)
+	}
+	published, err := GetPrice("BTC")
+	if err != nil || published == nil || published.Value.Int64() != 10000 || published.BlockNum != 2 {
+		t.Errorf("GetPrice() = %+v, %v; want the submitted value in block 2", published, err)
+	}
+	if pending, _ := GetPendingPrices(); len(pending) != 0 {
+		t.Errorf("%d value(s) still pending", len(pending))
+	}
+}
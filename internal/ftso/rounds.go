@@ -0,0 +1,391 @@
+package ftso
+
+import (
+	"encoding/json"
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/network"
+	"lfts/internal/state"
+	"lfts/internal/utils"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VotingRound is an FTSO voting round. Values submitted during a round are
+// published by the first block sealed after it ends.
+type VotingRound struct {
+	ID        uint64 `json:"votingRoundId"`
+	StartTime int64  `json:"startTime"`
+	EndTime   int64  `json:"endTime"`
+}
+
+// schedule numbers voting rounds: round FirstRound starts at Start, and every
+// round lasts Epoch seconds (0 disables voting rounds)
+type schedule struct {
+	FirstRound uint64 `json:"firstRound"`
+	Start      int64  `json:"start"`
+	Epoch      int64  `json:"epoch"`
+}
+
+// roundAt returns the ID of the round running at the given time
+func (s schedule) roundAt(t int64) uint64 {
+	if s.Epoch == 0 || t < s.Start {
+		return s.FirstRound
+	}
+	return s.FirstRound + uint64((t-s.Start)/s.Epoch)
+}
+
+// round returns the round with the given ID (at least FirstRound)
+func (s schedule) round(id uint64) VotingRound {
+	start := s.Start + int64(id-s.FirstRound)*s.Epoch
+	return VotingRound{ID: id, StartTime: start, EndTime: start + s.Epoch}
+}
+
+// scheduleKey is the state key of the schedule set by SetVotingEpoch
+const scheduleKey = "config:ftso:rounds"
+
+var (
+	// roundsMu guards rounds
+	roundsMu sync.RWMutex
+
+	// rounds is the node's schedule, nil until ConfigureRounds is called
+	rounds *schedule
+)
+
+// currentSchedule returns the schedule set by SetVotingEpoch, stored in the
+// state so reverts and reorgs undo it, otherwise the node's schedule or the
+// one of the active network profile
+func currentSchedule() schedule {
+	data, err := state.Get(scheduleKey)
+	if err == nil && data != nil {
+		var s schedule
+		if err = json.Unmarshal(data, &s); err == nil {
+			return s
+		}
+	}
+	if err != nil {
+		utils.Error("Invalid voting round schedule in state: %v", err)
+	}
+
+	roundsMu.RLock()
+	defer roundsMu.RUnlock()
+	if rounds != nil {
+		return *rounds
+	}
+	profile := network.Active()
+	return schedule{Start: profile.FirstRoundStart, Epoch: int64(profile.VotingEpoch / time.Second)}
+}
+
+// epochSeconds validates a voting epoch length
+func epochSeconds(epoch time.Duration) (int64, error) {
+	if epoch < 0 || epoch%time.Second != 0 {
+		return 0, fmt.Errorf("voting epoch must be a non-negative whole number of seconds")
+	}
+	return int64(epoch / time.Second), nil
+}
+
+// ConfigureRounds sets the node's voting epoch length, counting rounds from
+// start, the unix start time of round 0. It applies until SetVotingEpoch
+// changes the epoch on chain. An epoch of 0 publishes values as soon as they
+// are submitted.
+func ConfigureRounds(start int64, epoch time.Duration) error {
+	seconds, err := epochSeconds(epoch)
+	if err != nil {
+		return err
+	}
+	roundsMu.Lock()
+	defer roundsMu.Unlock()
+	rounds = &schedule{Start: start, Epoch: seconds}
+	return nil
+}
+
+// SetVotingEpoch changes the voting epoch length of a running node. The current
+// round ends now, so its values are published by the next block, and rounds of
+// the new length follow it. An epoch of 0 publishes values as soon as they are
+// submitted.
+func SetVotingEpoch(epoch time.Duration) error {
+	seconds, err := epochSeconds(epoch)
+	if err != nil {
+		return err
+	}
+	now := chain.Now().Unix()
+	current := currentSchedule()
+
+	next := schedule{FirstRound: current.roundAt(now) + 1, Start: now, Epoch: seconds}
+	if current.Epoch == 0 {
+		if current.FirstRound == 0 {
+			// Rounds never ran, so count them from the start time of round 0
+			next = schedule{Start: current.Start, Epoch: seconds}
+		} else {
+			// Continue after the last round that ran
+			next.FirstRound = current.FirstRound
+		}
+	}
+
+	data, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+	return chain.WithPendingBlock(func(uint64) error {
+		return state.Update(func(tx *state.Tx) error {
+			return tx.Set(scheduleKey, data)
+		})
+	})
+}
+
+// VotingEpoch returns the voting epoch length (0 when voting rounds are disabled)
+func VotingEpoch() time.Duration {
+	return time.Duration(currentSchedule().Epoch) * time.Second
+}
+
+// CurrentRound returns the voting round running on the chain clock, or nil when
+// voting rounds are disabled
+func CurrentRound() *VotingRound {
+	s := currentSchedule()
+	if s.Epoch == 0 {
+		return nil
+	}
+	round := s.round(s.roundAt(chain.Now().Unix()))
+	return &round
+}
+
+// GetRound returns the voting round with the given ID, or nil when voting rounds
+// are disabled or the round precedes the last change of the epoch length
+func GetRound(id uint64) *VotingRound {
+	s := currentSchedule()
+	if s.Epoch == 0 || id < s.FirstRound {
+		return nil
+	}
+	round := s.round(id)
+	return &round
+}
+
+// pendingKey returns the state key of the value an asset has pending for a
+// voting round
+func pendingKey(asset string, round uint64) string {
+	return fmt.Sprintf("pending:ftso:%s:%d", asset, round)
+}
+
+// SubmitPrice submits a price for the current voting round, rounding it to the
+// feed's decimals. It becomes the latest price when the round ends; until then a
+// later submission in the same round replaces it. With voting rounds disabled it
+// is published right away, like SetPrice.
+func SubmitPrice(asset string, price float64) (*FTSOPrice, error) {
+	if math.IsNaN(price) || math.IsInf(price, 0) {
+		return nil, fmt.Errorf("invalid price %v", price)
+	}
+	return submitPrice(asset, new(big.Rat).SetFloat64(price), true)
+}
+
+// SubmitPriceDecimal is SubmitPrice with a decimal price ("65000.12"), which is
+// stored exactly; a price with more precision than the feed's decimals is rejected
+func SubmitPriceDecimal(asset string, price string) (*FTSOPrice, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(price))
+	if !ok {
+		return nil, fmt.Errorf("invalid price %q", price)
+	}
+	return submitPrice(asset, r, false)
+}
+
+// submitPrice records a price as pending for the voting round running now, or
+// publishes it when voting rounds are disabled
+func submitPrice(asset string, price *big.Rat, round bool) (*FTSOPrice, error) {
+	var pending *FTSOPrice
+	err := chain.WithPendingBlock(func(blockNum uint64) error {
+		var err error
+		pending, err = submitPriceIn(blockNum, asset, price, round)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// SubmitPriceInBlock is SubmitPriceDecimal for callers that hold the chain
+// exclusively (see chain.Exclusive) and seal the pending block blockNum themselves
+func SubmitPriceInBlock(blockNum uint64, asset string, price string) (*FTSOPrice, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(price))
+	if !ok {
+		return nil, fmt.Errorf("invalid price %q", price)
+	}
+	return submitPriceIn(blockNum, asset, r, false)
+}
+
+// submitPriceIn records a submitted price in the pending block blockNum
+func submitPriceIn(blockNum uint64, asset string, price *big.Rat, round bool) (*FTSOPrice, error) {
+	asset, err := ResolveAsset(asset)
+	if err != nil {
+		return nil, err
+	}
+	now := chain.Now().Unix()
+	s := currentSchedule()
+
+	// Values still pending from before rounds were disabled are older
+	var stale []*FTSOPrice
+	var providers []Provider
+	if s.Epoch == 0 {
+		if stale, err = pendingPrices("pending:ftso:" + asset + ":"); err != nil {
+			return nil, err
+		}
+	} else if providers, err = Providers(); err != nil {
+		return nil, err
+	}
+
+	var pending FTSOPrice
+	err = state.Update(func(tx *state.Tx) error {
+		pending, err = newPrice(tx, asset, price, round, now)
+		if err != nil {
+			return err
+		}
+		pending.BlockNum = blockNum
+		if s.Epoch == 0 {
+			for _, old := range stale {
+				key := pendingKey(asset, old.VotingRoundID)
+				if err := tx.Delete(key); err != nil {
+					return err
+				}
+				chain.RecordStateUpdate(key, nil)
+			}
+			return publish(tx, pending)
+		}
+		pending.VotingRoundID = s.roundAt(now)
+
+		key := pendingKey(asset, pending.VotingRoundID)
+		data, err := json.Marshal(pending)
+		if err != nil {
+			return err
+		}
+		if err := tx.Set(key, data); err != nil {
+			return err
+		}
+		chain.RecordStateUpdate(key, data)
+		return commitProviders(tx, providers, pending)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+// GetPendingPrices returns the values waiting for their voting round to end,
+// ordered by asset and round. Timestamp and BlockNum are those of the submission.
+func GetPendingPrices() ([]*FTSOPrice, error) {
+	return pendingPrices("pending:ftso:")
+}
+
+// pendingPrices decodes the pending values under a key prefix, ordered by asset
+// and round
+func pendingPrices(prefix string) ([]*FTSOPrice, error) {
+	var prices []*FTSOPrice
+	var decodeErr error
+	err := state.IteratePrefix(prefix, func(key string, value []byte) bool {
+		var price FTSOPrice
+		if decodeErr = json.Unmarshal(value, &price); decodeErr != nil {
+			return false
+		}
+		prices = append(prices, &price)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	// Keys sort round IDs as text
+	sort.Slice(prices, func(i, j int) bool {
+		if prices[i].Asset != prices[j].Asset {
+			return prices[i].Asset < prices[j].Asset
+		}
+		return prices[i].VotingRoundID < prices[j].VotingRoundID
+	})
+	return prices, nil
+}
+
+// RoundsDue reports whether values are waiting for a block to publish them at
+// the given time. The chain loop uses it in auto mining mode, where no block is
+// sealed when a round ends unless something is written.
+func RoundsDue(now int64) bool {
+	pending, err := GetPendingPrices()
+	if err != nil || len(pending) == 0 {
+		return false
+	}
+	s := currentSchedule()
+	if s.Epoch == 0 {
+		return true
+	}
+	current := s.roundAt(now)
+	for _, price := range pending {
+		if price.VotingRoundID < current {
+			return true
+		}
+	}
+	return false
+}
+
+// FinalizeRounds publishes the pending values of every voting round that ended
+// by the given block timestamp. The chain runs it as a seal hook, so published
+// values belong to the block being sealed.
+func FinalizeRounds(number uint64, timestamp int64) error {
+	pending, err := GetPendingPrices()
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	s := currentSchedule()
+	current := s.roundAt(timestamp)
+	finalized := make(map[uint64]int)
+	err = state.Update(func(tx *state.Tx) error {
+		// Publish each asset's values in round order, so the newest becomes latest
+		for _, price := range pending {
+			if s.Epoch != 0 && price.VotingRoundID >= current {
+				continue
+			}
+			key := pendingKey(price.Asset, price.VotingRoundID)
+			if err := tx.Delete(key); err != nil {
+				return err
+			}
+			chain.RecordStateUpdate(key, nil)
+
+			// With providers, the weighted median of their reveals is published
+			published := *price
+			result, err := revealProviders(tx, *price)
+			if err != nil {
+				return err
+			}
+			if result != nil && result.Value == nil {
+				utils.Info("Voting round %d: no provider revealed a value for %s, publishing the submitted value", price.VotingRoundID, price.Asset)
+			} else if result != nil {
+				published.Value = result.Value
+				published.Price = result.Price
+			}
+
+			published.Timestamp = timestamp
+			published.BlockNum = number
+			if err := publish(tx, published); err != nil {
+				return err
+			}
+			finalized[price.VotingRoundID]++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint64, 0, len(finalized))
+	for id := range finalized {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		utils.Info("Voting round %d finalized: %d value(s) published in block #%d", id, finalized[id], number)
+	}
+	return nil
+}
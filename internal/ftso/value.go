@@ -0,0 +1,152 @@
+package ftso
+
+import (
+	"encoding/json"
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/state"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultDecimals is the number of decimals of a feed's values unless changed
+// with SetDecimals
+const DefaultDecimals int8 = 8
+
+// pow10 returns 10^n as a rational (n may be negative)
+func pow10(n int) *big.Rat {
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(n))), nil)
+	if n < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), p)
+	}
+	return new(big.Rat).SetInt(p)
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// toValue scales a price to a fixed-point value with the given decimals. If
+// the price has more precision than the decimals allow, it is rounded half up
+// when round is set and rejected otherwise.
+func toValue(price *big.Rat, decimals int8, round bool) (*big.Int, error) {
+	if price.Sign() < 0 {
+		return nil, fmt.Errorf("price must not be negative")
+	}
+
+	scaled := new(big.Rat).Mul(price, pow10(int(decimals)))
+	if scaled.IsInt() {
+		return new(big.Int).Set(scaled.Num()), nil
+	}
+	if !round {
+		return nil, fmt.Errorf("price has more precision than the feed's %d decimals", decimals)
+	}
+
+	// floor(scaled + 1/2)
+	half := new(big.Rat).Add(scaled, big.NewRat(1, 2))
+	return new(big.Int).Quo(half.Num(), half.Denom()), nil
+}
+
+// ParsePrice converts a decimal price ("65000.12", "1e-7") to a fixed-point
+// value with the given decimals, rejecting prices with more precision
+func ParsePrice(price string, decimals int8) (*big.Int, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(price))
+	if !ok {
+		return nil, fmt.Errorf("invalid price %q", price)
+	}
+	value, err := toValue(r, decimals, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", price, err)
+	}
+	return value, nil
+}
+
+// FormatValue renders a fixed-point value as a decimal number without trailing zeros
+func FormatValue(value *big.Int, decimals int8) string {
+	if value == nil {
+		return "0"
+	}
+	if decimals <= 0 {
+		return new(big.Int).Mul(value, pow10(-int(decimals)).Num()).String()
+	}
+
+	s := new(big.Rat).SetFrac(value, pow10(int(decimals)).Num()).FloatString(int(decimals))
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// ScaleValue converts a fixed-point value between decimals, truncating digits
+// that do not fit
+func ScaleValue(value *big.Int, from, to int8) *big.Int {
+	scaled := new(big.Rat).Mul(new(big.Rat).SetInt(value), pow10(int(to)-int(from)))
+	return new(big.Int).Quo(scaled.Num(), scaled.Denom())
+}
+
+// valueFloat converts a fixed-point value to a float64 (for display and simulation)
+func valueFloat(value *big.Int, decimals int8) float64 {
+	f, _ := strconv.ParseFloat(FormatValue(value, decimals), 64)
+	return f
+}
+
+// legacyValue converts a price stored by earlier versions as a float to a
+// fixed-point value with the default decimals
+func legacyValue(price json.Number) (*big.Int, int8) {
+	r, ok := new(big.Rat).SetString(price.String())
+	if !ok {
+		return new(big.Int), DefaultDecimals
+	}
+	value, err := toValue(r, DefaultDecimals, true)
+	if err != nil {
+		return new(big.Int), DefaultDecimals
+	}
+	return value, DefaultDecimals
+}
+
+// decimalsKey returns the state key of an asset's decimals
+func decimalsKey(asset string) string {
+	return "decimals:ftso:" + asset
+}
+
+// SetDecimals sets the number of decimals of new values of a feed. Values
+// already stored keep the decimals they were written with.
+func SetDecimals(asset string, decimals int8) error {
+	asset, err := ResolveAsset(asset)
+	if err != nil {
+		return err
+	}
+	return chain.WithPendingBlock(func(uint64) error {
+		return state.Update(func(tx *state.Tx) error {
+			if decimals == DefaultDecimals {
+				return tx.Delete(decimalsKey(asset))
+			}
+			return tx.Set(decimalsKey(asset), []byte(strconv.Itoa(int(decimals))))
+		})
+	})
+}
+
+// GetDecimals returns the number of decimals of new values of a feed
+func GetDecimals(asset string) (int8, error) {
+	asset, err := ResolveAsset(asset)
+	if err != nil {
+		return 0, err
+	}
+	return readDecimals(state.GlobalState, asset)
+}
+
+// readDecimals reads the decimals of an asset (caller resolves the asset)
+func readDecimals(rw state.ReadWriter, asset string) (int8, error) {
+	data, err := rw.Get(decimalsKey(asset))
+	if err != nil || data == nil {
+		return DefaultDecimals, err
+	}
+	n, err := strconv.ParseInt(string(data), 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimals of %s: %v", asset, err)
+	}
+	return int8(n), nil
+}
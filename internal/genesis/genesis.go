@@ -0,0 +1,290 @@
+package genesis
+
+import (
+	"encoding/json"
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/contracts"
+	"lfts/internal/fdc"
+	"lfts/internal/feedid"
+	"lfts/internal/ftso"
+	"os"
+	"sort"
+	"time"
+)
+
+// Genesis declares the initial conditions of the sandbox
+type Genesis struct {
+	ChainID     uint64              `json:"chainId,omitempty"`
+	Timestamp   int64               `json:"timestamp,omitempty"`   // chain clock start (unix seconds, 0 = now)
+	BlockTime   int                 `json:"blockTime,omitempty"`   // milliseconds
+	VotingEpoch *int64              `json:"votingEpoch,omitempty"` // seconds (0 publishes FTSO values immediately)
+	FTSO        map[string]FTSOFeed `json:"ftso,omitempty"`        // keyed by asset
+	FDC         map[string]FDCFeed  `json:"fdc,omitempty"`         // keyed by feed name
+	Assets      map[string]string   `json:"assets,omitempty"`      // contract address -> asset
+	Contracts   map[string]string   `json:"contracts,omitempty"`   // registry name -> address
+	Feeds       []FeedMapping       `json:"feeds,omitempty"`       // FTSOv2 feeds stored under custom asset symbols
+	Providers   []ftso.Provider     `json:"providers,omitempty"`   // simulated FTSO data providers
+	FastUpdates *FastUpdates        `json:"fastUpdates,omitempty"`
+	AutoUpdate  *AutoUpdate         `json:"autoUpdate,omitempty"`
+}
+
+// FTSOFeed is an initial FTSO price with optional history (oldest first)
+type FTSOFeed struct {
+	Price     json.Number  `json:"price"` // stored exactly with the feed's decimals
+	Decimals  *int8        `json:"decimals,omitempty"`
+	History   []PricePoint `json:"history,omitempty"`
+	Retention int          `json:"retention,omitempty"` // history entries kept (0 = default)
+}
+
+// decimals returns the decimals the feed's prices are stored with
+func (f FTSOFeed) decimals() int8 {
+	if f.Decimals == nil {
+		return ftso.DefaultDecimals
+	}
+	return *f.Decimals
+}
+
+// PricePoint is a historical FTSO price
+type PricePoint struct {
+	Price     json.Number `json:"price"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// FeedMapping maps an FTSOv2 feed (category and name) to an asset symbol
+type FeedMapping struct {
+	Category string `json:"category"` // crypto, forex, commodity or stock
+	Name     string `json:"name"`     // e.g. "EUR/USD"
+	Asset    string `json:"asset"`
+}
+
+// FDCFeed is an initial FDC feed with optional history (oldest first)
+type FDCFeed struct {
+	Data       map[string]interface{} `json:"data"`
+	History    []FeedPoint            `json:"history,omitempty"`
+	Retention  int                    `json:"retention,omitempty"`  // history entries kept (0 = default)
+	TTL        int64                  `json:"ttl,omitempty"`        // seconds a value stays valid (0 = forever)
+	StaleAfter int64                  `json:"staleAfter,omitempty"` // seconds until a value is stale (0 = half the TTL)
+}
+
+// FeedPoint is a historical FDC feed entry
+type FeedPoint struct {
+	Data      map[string]interface{} `json:"data"`
+	Timestamp int64                  `json:"timestamp"`
+}
+
+// FastUpdates declares the block-latency fast-update settings
+type FastUpdates struct {
+	Submitters int     `json:"submitters"`          // per block (0 disables fast updates)
+	Precision  float64 `json:"precision,omitempty"` // percent per delta
+}
+
+// AutoUpdate declares the auto-updater settings
+type AutoUpdate struct {
+	Enabled    bool     `json:"enabled"`
+	Interval   int      `json:"interval,omitempty"` // milliseconds
+	Pattern    string   `json:"pattern,omitempty"`
+	Assets     []string `json:"assets,omitempty"`
+	Volatility float64  `json:"volatility,omitempty"`
+}
+
+// Load reads and validates a genesis file
+func Load(path string) (*Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var g Genesis
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("invalid genesis file %s: %v", path, err)
+	}
+
+	if err := g.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid genesis file %s: %v", path, err)
+	}
+	return &g, nil
+}
+
+// Validate checks the genesis for inconsistent values
+func (g *Genesis) Validate() error {
+	if g.BlockTime < 0 {
+		return fmt.Errorf("blockTime must not be negative")
+	}
+	if g.VotingEpoch != nil && *g.VotingEpoch < 0 {
+		return fmt.Errorf("votingEpoch must not be negative")
+	}
+	for i := range g.Providers {
+		if g.Providers[i].Strategy == "" {
+			g.Providers[i].Strategy = ftso.StrategyHonest
+		}
+		if err := g.Providers[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	for asset, feed := range g.FTSO {
+		if feed.Retention < 0 {
+			return fmt.Errorf("ftso feed %s: retention must not be negative", asset)
+		}
+		if _, err := ftso.ParsePrice(feed.Price.String(), feed.decimals()); err != nil {
+			return fmt.Errorf("ftso feed %s: %v", asset, err)
+		}
+		for i, point := range feed.History {
+			if _, err := ftso.ParsePrice(point.Price.String(), feed.decimals()); err != nil {
+				return fmt.Errorf("ftso feed %s: history entry %d: %v", asset, i, err)
+			}
+		}
+		if err := checkHistory(asset, len(feed.History), func(i int) int64 { return feed.History[i].Timestamp }, g.Timestamp); err != nil {
+			return err
+		}
+	}
+	for name, feed := range g.FDC {
+		if feed.Data == nil {
+			return fmt.Errorf("fdc feed %s: missing data", name)
+		}
+		if feed.Retention < 0 {
+			return fmt.Errorf("fdc feed %s: retention must not be negative", name)
+		}
+		if err := (fdc.FeedTTL{TTL: feed.TTL, StaleAfter: feed.StaleAfter}).Validate(); err != nil {
+			return fmt.Errorf("fdc feed %s: %v", name, err)
+		}
+		if err := checkHistory(name, len(feed.History), func(i int) int64 { return feed.History[i].Timestamp }, g.Timestamp); err != nil {
+			return err
+		}
+	}
+
+	if fu := g.FastUpdates; fu != nil {
+		config := ftso.FastUpdateConfig{Submitters: fu.Submitters, Precision: fu.Precision}
+		if config.Precision == 0 {
+			config.Precision = ftso.DefaultFastUpdatePrecision
+		}
+		if err := config.Validate(); err != nil {
+			return err
+		}
+	}
+	if g.AutoUpdate != nil && g.AutoUpdate.Interval < 0 {
+		return fmt.Errorf("autoUpdate.interval must not be negative")
+	}
+	return nil
+}
+
+// checkHistory ensures history timestamps are ascending and not after the genesis time
+func checkHistory(name string, n int, timestamp func(int) int64, genesisTime int64) error {
+	for i := 0; i < n; i++ {
+		ts := timestamp(i)
+		if i > 0 && ts < timestamp(i-1) {
+			return fmt.Errorf("%s: history must be in ascending timestamp order", name)
+		}
+		if genesisTime != 0 && ts > genesisTime {
+			return fmt.Errorf("%s: history entry %d is after the genesis timestamp", name, i)
+		}
+	}
+	return nil
+}
+
+// Apply configures the chain and seeds the state. Call it before the chain loop
+// starts so the seeded writes are sealed into the first block. BlockTime,
+// VotingEpoch, FastUpdates and AutoUpdate are node settings and are read by the start command instead.
+func (g *Genesis) Apply(chainInstance *chain.Chain) error {
+	if err := g.ApplySettings(chainInstance); err != nil {
+		return err
+	}
+
+	for _, mapping := range g.Feeds {
+		category, err := feedid.ParseCategory(mapping.Category)
+		if err != nil {
+			return err
+		}
+		if _, err := ftso.RegisterFeed(category, mapping.Name, mapping.Asset); err != nil {
+			return err
+		}
+	}
+
+	for _, provider := range g.Providers {
+		if err := ftso.SetProvider(provider); err != nil {
+			return err
+		}
+	}
+
+	if g.Timestamp != 0 {
+		chainInstance.Clock().SetTime(time.Unix(g.Timestamp, 0))
+		if err := chainInstance.SetNextBlockTimestamp(g.Timestamp); err != nil {
+			return err
+		}
+	}
+	now := chain.Now().Unix()
+
+	// Seed in a fixed order so every run produces the same genesis block
+	for _, asset := range sortedKeys(g.FTSO) {
+		feed := g.FTSO[asset]
+		if feed.Retention > 0 {
+			if err := ftso.SetHistoryRetention(asset, feed.Retention); err != nil {
+				return err
+			}
+		}
+		if feed.Decimals != nil {
+			if err := ftso.SetDecimals(asset, *feed.Decimals); err != nil {
+				return err
+			}
+		}
+		for _, point := range feed.History {
+			if _, err := ftso.SetPriceAt(asset, point.Price.String(), point.Timestamp); err != nil {
+				return err
+			}
+		}
+		if _, err := ftso.SetPriceAt(asset, feed.Price.String(), now); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedKeys(g.FDC) {
+		feed := g.FDC[name]
+		if feed.Retention > 0 {
+			if err := fdc.SetHistoryRetention(name, feed.Retention); err != nil {
+				return err
+			}
+		}
+		if feed.TTL > 0 {
+			if err := fdc.SetTTL(name, fdc.FeedTTL{TTL: feed.TTL, StaleAfter: feed.StaleAfter}); err != nil {
+				return err
+			}
+		}
+		for _, point := range feed.History {
+			if _, err := fdc.SetFeedAt(name, point.Data, point.Timestamp); err != nil {
+				return err
+			}
+		}
+		if _, err := fdc.SetFeedAt(name, feed.Data, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ApplySettings applies the parts of the genesis that are not stored on chain
+// (chain ID and asset addresses). Use it instead of Apply when resuming a
+// persisted chain.
+func (g *Genesis) ApplySettings(chainInstance *chain.Chain) error {
+	if g.ChainID != 0 {
+		chainInstance.SetChainID(g.ChainID)
+	}
+
+	for address, asset := range g.Assets {
+		if err := contracts.RegisterAssetAddress(address, asset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a map in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
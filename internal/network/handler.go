@@ -0,0 +1,44 @@
+package network
+
+import (
+	"encoding/json"
+	"lfts/internal/feedid"
+	"net/http"
+)
+
+// HandleNetwork handles GET /network
+func HandleNetwork(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile := Active()
+	feeds := make([]map[string]string, 0, len(profile.Feeds))
+	for _, name := range profile.Feeds {
+		id, err := feedid.New(feedid.CategoryCrypto, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		feeds = append(feeds, map[string]string{
+			"name": name,
+			"id":   id.String(),
+		})
+	}
+
+	response := map[string]interface{}{
+		"name":            profile.Name,
+		"chainId":         profile.ChainID,
+		"nativeSymbol":    profile.NativeSymbol,
+		"votingEpoch":     int64(profile.VotingEpoch.Seconds()),
+		"firstRoundStart": profile.FirstRoundStart,
+		"blockTime":       profile.BlockTime.Milliseconds(),
+		"registry":        profile.Registry,
+		"contracts":       profile.Contracts,
+		"feeds":           feeds,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,208 @@
+package network
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// FlareContractRegistryAddress is the address of the FlareContractRegistry on
+	// every Flare network; the periphery ContractRegistry library resolves all
+	// other system contracts through it
+	FlareContractRegistryAddress = "0xad67fe66660fb8dfe9d6b1b4240d8650e30f6019"
+
+	// MockFtsoV2Address is the sandbox FtsoV2 contract returned by the registry
+	MockFtsoV2Address = "0x0000000000000000000000000000000000000001"
+
+	// MockFdcVerificationAddress is the sandbox FdcVerification contract returned by the registry
+	MockFdcVerificationAddress = "0x0000000000000000000000000000000000000002"
+)
+
+// Registry names of the system contracts the sandbox implements. Calls to the
+// address a profile gives one of these names are served by its mock.
+const (
+	ContractFtsoV2          = "FtsoV2"
+	ContractFdcVerification = "FdcVerification"
+)
+
+// Profile describes the network the sandbox imitates
+type Profile struct {
+	Name            string
+	ChainID         uint64
+	NativeSymbol    string
+	VotingEpoch     time.Duration
+	FirstRoundStart int64 // unix start time of voting round 0
+	BlockTime       time.Duration
+	Registry        string            // FlareContractRegistry address
+	Contracts       map[string]string // registry name -> lowercase address
+	Feeds           []string          // FTSOv2 feed names, e.g. "FLR/USD"
+}
+
+// SetContract makes the registry resolve name to address, e.g. to serve a mock
+// at the address a contract was deployed to on the imitated network
+func (p *Profile) SetContract(name, address string) error {
+	if name == "" {
+		return fmt.Errorf("missing contract name")
+	}
+	if !isAddress(address) {
+		return fmt.Errorf("invalid address %q for contract %s", address, name)
+	}
+	if other, ok := p.ContractName(address); ok && other != name {
+		return fmt.Errorf("address %s of contract %s is already used by %s", address, name, other)
+	}
+	if p.Contracts == nil {
+		p.Contracts = make(map[string]string)
+	}
+	p.Contracts[name] = strings.ToLower(address)
+	return nil
+}
+
+// ContractName returns the registry name the profile gives an address
+func (p Profile) ContractName(address string) (string, bool) {
+	address = strings.ToLower(address)
+	for name, a := range p.Contracts {
+		if a == address {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// isAddress reports whether s is a 0x-prefixed 20-byte hex address
+func isAddress(s string) bool {
+	if len(s) != 42 || !strings.HasPrefix(s, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// mockContracts are the registry entries of the sandbox's mock contracts
+func mockContracts() map[string]string {
+	return map[string]string{
+		ContractFtsoV2:          MockFtsoV2Address,
+		ContractFdcVerification: MockFdcVerificationAddress,
+	}
+}
+
+// flareFeeds is the crypto feed set of Flare and Coston2
+var flareFeeds = []string{
+	"FLR/USD", "SGB/USD", "BTC/USD", "XRP/USD", "LTC/USD", "XLM/USD", "DOGE/USD",
+	"ADA/USD", "ALGO/USD", "ETH/USD", "FIL/USD", "ARB/USD", "AVAX/USD", "BNB/USD",
+	"POL/USD", "SOL/USD", "USDC/USD", "USDT/USD", "XDC/USD",
+}
+
+// songbirdFeeds is the crypto feed set of Songbird and Coston
+var songbirdFeeds = []string{
+	"SGB/USD", "FLR/USD", "BTC/USD", "XRP/USD", "LTC/USD", "XLM/USD", "DOGE/USD",
+	"ADA/USD", "ALGO/USD", "ETH/USD", "FIL/USD", "ARB/USD", "AVAX/USD", "BNB/USD",
+	"POL/USD", "SOL/USD", "USDC/USD", "USDT/USD", "XDC/USD",
+}
+
+// profiles lists the built-in network profiles. All Flare networks use 90 second
+// voting epochs and produce blocks roughly every 1.8 seconds. The local profile
+// publishes FTSO values immediately; when voting rounds are enabled on it, they
+// are counted from the Unix epoch. A profile's Contracts hold its own registry
+// entries; the mock contracts keep their sandbox addresses for names it does
+// not set.
+var profiles = map[string]Profile{
+	"local": {
+		Name:         "local",
+		ChainID:      31337,
+		NativeSymbol: "FLR",
+		BlockTime:    time.Second,
+		Feeds:        flareFeeds,
+	},
+	"flare": {
+		Name:            "flare",
+		ChainID:         14,
+		NativeSymbol:    "FLR",
+		VotingEpoch:     90 * time.Second,
+		FirstRoundStart: 1658430000,
+		BlockTime:       1800 * time.Millisecond,
+		Feeds:           flareFeeds,
+	},
+	"songbird": {
+		Name:            "songbird",
+		ChainID:         19,
+		NativeSymbol:    "SGB",
+		VotingEpoch:     90 * time.Second,
+		FirstRoundStart: 1658429955,
+		BlockTime:       1800 * time.Millisecond,
+		Feeds:           songbirdFeeds,
+	},
+	"coston": {
+		Name:            "coston",
+		ChainID:         16,
+		NativeSymbol:    "CFLR",
+		VotingEpoch:     90 * time.Second,
+		FirstRoundStart: 1658429955,
+		BlockTime:       1800 * time.Millisecond,
+		Feeds:           songbirdFeeds,
+	},
+	"coston2": {
+		Name:            "coston2",
+		ChainID:         114,
+		NativeSymbol:    "C2FLR",
+		VotingEpoch:     90 * time.Second,
+		FirstRoundStart: 1658430000,
+		BlockTime:       1800 * time.Millisecond,
+		Feeds:           flareFeeds,
+	},
+}
+
+// DefaultProfile is the profile used when no network is selected
+const DefaultProfile = "local"
+
+// Lookup returns the named profile
+func Lookup(name string) (Profile, error) {
+	profile, exists := profiles[strings.ToLower(name)]
+	if !exists {
+		return Profile{}, fmt.Errorf("unknown network %q (expected one of %s)", name, strings.Join(Names(), ", "))
+	}
+	profile.Registry = FlareContractRegistryAddress
+	contracts := mockContracts()
+	for contract, address := range profile.Contracts {
+		contracts[contract] = address
+	}
+	profile.Contracts = contracts
+	profile.Feeds = append([]string{}, profile.Feeds...)
+	return profile, nil
+}
+
+// Names returns the names of all built-in profiles
+func Names() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	activeMu sync.RWMutex
+	active   Profile
+)
+
+func init() {
+	active, _ = Lookup(DefaultProfile)
+}
+
+// Active returns the profile the sandbox currently imitates
+func Active() Profile {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active
+}
+
+// SetActive selects the profile the sandbox imitates
+func SetActive(profile Profile) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	active = profile
+}
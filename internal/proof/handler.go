@@ -0,0 +1,45 @@
+package proof
+
+import (
+	"encoding/json"
+	"errors"
+	"lfts/internal/chain"
+	"net/http"
+)
+
+// HandleProof handles GET /state/proof?key=<key>[&block=<number|hash|tag>]
+func HandleProof(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if chain.GetInstance() == nil {
+		http.Error(w, "Chain not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "Missing key parameter", http.StatusBadRequest)
+		return
+	}
+
+	ref := r.URL.Query().Get("block")
+	if ref == "" {
+		ref = "latest"
+	}
+
+	result, err := Get(key, ref)
+	if errors.Is(err, ErrKeyNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), chain.ReadErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
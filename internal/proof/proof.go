@@ -0,0 +1,44 @@
+package proof
+
+import (
+	"errors"
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/state"
+)
+
+// ErrKeyNotFound is returned when the key is not part of the state at the block
+var ErrKeyNotFound = errors.New("key not found")
+
+// Result is an inclusion proof together with the block whose header commits to it
+type Result struct {
+	*state.Proof
+	BlockHash string `json:"blockHash"`
+}
+
+// Get returns an inclusion proof for key in the state of the block referenced by
+// ref (number, hash or tag). The proof's root is the block's stateRoot.
+func Get(key, ref string) (*Result, error) {
+	number, err := chain.ResolveBlockNumber(ref)
+	if err != nil {
+		return nil, err
+	}
+	block := chain.GetInstance().GetBlockByNumber(number)
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s", chain.ErrBlockNotFound, ref)
+	}
+
+	p, err := state.GlobalState.Proof(key, number)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("%w at block %d: %s", ErrKeyNotFound, number, key)
+	}
+
+	if p.StateRoot != block.StateRoot.String() {
+		return nil, fmt.Errorf("state root of block %d does not match the state (%s != %s)", number, block.StateRoot, p.StateRoot)
+	}
+
+	return &Result{Proof: p, BlockHash: block.Hash.String()}, nil
+}
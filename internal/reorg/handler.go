@@ -0,0 +1,29 @@
+package reorg
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HandleReorg handles POST /chain/reorg with a JSON Request body
+func HandleReorg(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := Reorg(req)
+	if err != nil {
+		http.Error(w, "Reorg failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,124 @@
+package reorg
+
+import (
+	"encoding/json"
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/fdc"
+	"lfts/internal/ftso"
+	"lfts/internal/state"
+)
+
+// Update is an FTSO or FDC write replayed on the alternate chain. FTSO prices are
+// submitted like injected ones: with voting rounds enabled they are pending until
+// their round ends, and data providers vote on them.
+type Update struct {
+	Type  string                 `json:"type"` // "ftso" or "fdc"
+	Asset string                 `json:"asset,omitempty"`
+	Price json.Number            `json:"price,omitempty"` // stored exactly with the feed's decimals
+	Name  string                 `json:"name,omitempty"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+// Request describes a simulated reorganization
+type Request struct {
+	Depth   uint64   `json:"depth"`            // number of blocks to orphan
+	Blocks  uint64   `json:"blocks,omitempty"` // length of the alternate chain (default: depth)
+	Updates []Update `json:"updates,omitempty"`
+}
+
+// Result reports the blocks that left and joined the canonical chain
+type Result struct {
+	Removed []*chain.Block `json:"removed"` // newest first
+	Added   []*chain.Block `json:"added"`
+}
+
+// validate checks the replayed updates before any block is orphaned
+func (u Update) validate() error {
+	switch u.Type {
+	case "ftso":
+		if u.Asset == "" {
+			return fmt.Errorf("ftso update missing asset")
+		}
+		decimals, err := ftso.GetDecimals(u.Asset)
+		if err != nil {
+			return err
+		}
+		if _, err := ftso.ParsePrice(u.Price.String(), decimals); err != nil {
+			return fmt.Errorf("ftso update of %s: %v", u.Asset, err)
+		}
+	case "fdc":
+		if u.Name == "" || u.Data == nil {
+			return fmt.Errorf("fdc update missing name or data")
+		}
+	default:
+		return fmt.Errorf("unknown update type %q (expected ftso or fdc)", u.Type)
+	}
+	return nil
+}
+
+// apply writes the update through the regular FTSO/FDC paths into the pending
+// block blockNum (the caller holds the chain exclusively)
+func (u Update) apply(blockNum uint64) error {
+	if u.Type == "ftso" {
+		_, err := ftso.SubmitPriceInBlock(blockNum, u.Asset, u.Price.String())
+		return err
+	}
+	_, err := fdc.SetFeedInBlock(blockNum, u.Name, u.Data)
+	return err
+}
+
+// Reorg orphans the last Depth blocks, restoring the state as it was before
+// them, then replays the alternate updates and mines the replacement chain of
+// exactly Blocks blocks. It holds the chain exclusively throughout, so neither
+// the chain loop nor auto mining seals a block in between. If an update cannot
+// be replayed, the chain and state are restored as they were before the reorg.
+func Reorg(req Request) (*Result, error) {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return nil, fmt.Errorf("chain not initialized")
+	}
+
+	for _, update := range req.Updates {
+		if err := update.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	blocks := req.Blocks
+	if blocks == 0 {
+		blocks = req.Depth
+	}
+
+	var result *Result
+	var err error
+	chainInstance.Exclusive(func() {
+		stateSnap := state.GlobalState.Snapshot()
+		chainSnap := chainInstance.Snapshot()
+
+		var removed []*chain.Block
+		removed, err = chainInstance.Rewind(req.Depth)
+		if err != nil {
+			return
+		}
+
+		forkBlock := chainInstance.GetHeight() + 1
+		for _, update := range req.Updates {
+			if err = update.apply(forkBlock); err != nil {
+				err = fmt.Errorf("replaying %s update: %v", update.Type, err)
+				state.GlobalState.Restore(stateSnap)
+				chainInstance.Restore(chainSnap)
+				return
+			}
+		}
+
+		result = &Result{
+			Removed: removed,
+			Added:   chainInstance.SealBlocks(blocks),
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
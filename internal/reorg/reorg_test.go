@@ -0,0 +1,173 @@
+package reorg
+
+import (
+	"fmt"
+	"lfts/internal/chain"
+	"lfts/internal/ftso"
+	"lfts/internal/state"
+	"math"
+	"testing"
+)
+
+// useChain installs a fresh chain over an in-memory state with blocks 1..height,
+// each setting a new FLR price
+func useChain(t *testing.T, height int) *chain.Chain {
+	t.Helper()
+	previous := state.GlobalState
+	state.GlobalState = state.NewMemoryStorage()
+	c := chain.NewChain(1000)
+	chain.SetInstance(c)
+	t.Cleanup(func() {
+		chain.SetInstance(nil)
+		state.GlobalState = previous
+	})
+
+	for i := 1; i <= height; i++ {
+		if _, err := ftso.SetPriceDecimal("FLR", fmt.Sprintf("0.0%d", i)); err != nil {
+			t.Fatal(err)
+		}
+		c.CreateBlock()
+	}
+	return c
+}
+
+func TestReorg(t *testing.T) {
+	c := useChain(t, 5)
+	events, cancel := c.SubscribeBlocks()
+	defer cancel()
+
+	result, err := Reorg(Request{
+		Depth:   2,
+		Blocks:  3,
+		Updates: []Update{{Type: "ftso", Asset: "FLR", Price: "0.09"}},
+	})
+	if err != nil {
+		t.Fatalf("Reorg() error = %v", err)
+	}
+
+	if len(result.Removed) != 2 || result.Removed[0].Number != 5 || result.Removed[1].Number != 4 {
+		t.Errorf("Removed = %v, want blocks 5 and 4", blockNumbers(result.Removed))
+	}
+	if len(result.Added) != 3 || result.Added[0].Number != 4 || result.Added[2].Number != 6 {
+		t.Errorf("Added = %v, want blocks 4 to 6", blockNumbers(result.Added))
+	}
+	if got := c.GetHeight(); got != 6 {
+		t.Errorf("height = %d, want 6", got)
+	}
+	if len(result.Added[0].Data.StateUpdates) == 0 {
+		t.Error("the fork block does not record the replayed update")
+	}
+	if _, err := c.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	price, err := ftso.GetPriceAtBlock("FLR", 4)
+	if err != nil || price == nil || price.Price != "0.09" {
+		t.Errorf("FLR at block 4 = %v, %v, want 0.09", price, err)
+	}
+	price, err = ftso.GetPriceAtBlock("FLR", 3)
+	if err != nil || price == nil || price.Price != "0.03" {
+		t.Errorf("FLR at block 3 = %v, %v, want 0.03", price, err)
+	}
+
+	want := []string{
+		chain.EventRemovedBlock, chain.EventRemovedBlock,
+		chain.EventNewBlock, chain.EventNewBlock, chain.EventNewBlock,
+	}
+	got := drain(events)
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+	for i, typ := range want {
+		if got[i].Type != typ {
+			t.Errorf("event %d = %s #%d, want %s", i, got[i].Type, got[i].Block.Number, typ)
+		}
+	}
+}
+
+func TestReorgReplayFailure(t *testing.T) {
+	c := useChain(t, 5)
+	head := c.GetLatestBlock()
+	root, err := state.GlobalState.Root(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := c.SubscribeBlocks()
+	defer cancel()
+
+	// The FTSO update is replayed before the FDC one fails to encode
+	_, err = Reorg(Request{
+		Depth: 2,
+		Updates: []Update{
+			{Type: "ftso", Asset: "FLR", Price: "0.09"},
+			{Type: "fdc", Name: "weather", Data: map[string]interface{}{"temp": math.NaN()}},
+		},
+	})
+	if err == nil {
+		t.Fatal("Reorg() succeeded, want a replay error")
+	}
+
+	if got := c.GetLatestBlock(); got.Hash != head.Hash {
+		t.Errorf("head = #%d %s, want #%d %s", got.Number, got.Hash, head.Number, head.Hash)
+	}
+	if got, err := state.GlobalState.Root(5); err != nil || got != root {
+		t.Errorf("state root of block 5 = %x, %v, want %x", got, err, root)
+	}
+	if price, err := ftso.GetPrice("FLR"); err != nil || price == nil || price.Price != "0.05" {
+		t.Errorf("FLR = %v, %v, want 0.05", price, err)
+	}
+
+	// The next block carries none of the replayed writes
+	if block := c.CreateBlock(); len(block.Data.StateUpdates) != 0 {
+		t.Errorf("block %d records %d leftover updates", block.Number, len(block.Data.StateUpdates))
+	}
+
+	// Subscribers see the orphaned blocks return before the new block
+	want := []struct {
+		typ    string
+		number uint64
+	}{
+		{chain.EventRemovedBlock, 5}, {chain.EventRemovedBlock, 4},
+		{chain.EventNewBlock, 4}, {chain.EventNewBlock, 5}, {chain.EventNewBlock, 6},
+	}
+	got := drain(events)
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Type != w.typ || got[i].Block.Number != w.number {
+			t.Errorf("event %d = %s #%d, want %s #%d", i, got[i].Type, got[i].Block.Number, w.typ, w.number)
+		}
+	}
+}
+
+func TestReorgInvalidDepth(t *testing.T) {
+	c := useChain(t, 2)
+	if _, err := Reorg(Request{Depth: 2}); err == nil {
+		t.Error("Reorg() of the whole chain succeeded")
+	}
+	if got := c.GetHeight(); got != 2 {
+		t.Errorf("height = %d, want 2", got)
+	}
+}
+
+func blockNumbers(blocks []*chain.Block) []uint64 {
+	numbers := make([]uint64, len(blocks))
+	for i, block := range blocks {
+		numbers[i] = block.Number
+	}
+	return numbers
+}
+
+// drain returns the buffered block events; they are published synchronously
+func drain(events <-chan chain.BlockEvent) []chain.BlockEvent {
+	var got []chain.BlockEvent
+	for {
+		select {
+		case event := <-events:
+			got = append(got, event)
+		default:
+			return got
+		}
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"encoding/json"
 	"lfts/internal/chain"
 	"lfts/internal/contracts"
+	"lfts/internal/dump"
 	"lfts/internal/fdc"
 	"lfts/internal/ftso"
+	"lfts/internal/network"
+	"lfts/internal/proof"
+	"lfts/internal/reorg"
+	"lfts/internal/snapshot"
+	"lfts/internal/state"
 	"net/http"
-	"strconv"
 )
 
 // HandleStatus handles GET /status
@@ -19,9 +24,13 @@ func HandleStatus(w http.ResponseWriter, r *http.Request) {
 
 	chainInstance := chain.GetInstance()
 	response := map[string]interface{}{
+		"network":       network.Active().Name,
+		"chainId":       chainInstance.GetChainID(),
 		"running":       chainInstance.IsRunning(),
 		"height":        chainInstance.GetHeight(),
 		"lastBlockTime": chainInstance.GetLastBlockTime(),
+		"miningMode":    chainInstance.GetMiningMode(),
+		"blockTime":     chainInstance.GetBlockTime().Milliseconds(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -48,13 +57,113 @@ func HandleLatestBlock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := map[string]interface{}{
-		"number":    latestBlock.Number,
-		"timestamp": latestBlock.Timestamp,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(latestBlock)
+}
+
+// HandleBlock delegates to chain package handler
+func HandleBlock(w http.ResponseWriter, r *http.Request) {
+	chain.HandleBlock(w, r)
+}
+
+// HandleBlocks delegates to chain package handler
+func HandleBlocks(w http.ResponseWriter, r *http.Request) {
+	chain.HandleBlocks(w, r)
+}
+
+// HandleChainVerify delegates to chain package handler
+func HandleChainVerify(w http.ResponseWriter, r *http.Request) {
+	chain.HandleVerify(w, r)
+}
+
+// HandleChainMine delegates to chain package handler
+func HandleChainMine(w http.ResponseWriter, r *http.Request) {
+	chain.HandleMine(w, r)
+}
+
+// HandleChainMining delegates to chain package handler
+func HandleChainMining(w http.ResponseWriter, r *http.Request) {
+	chain.HandleMining(w, r)
+}
+
+// HandleTime delegates to chain package handler
+func HandleTime(w http.ResponseWriter, r *http.Request) {
+	chain.HandleTime(w, r)
+}
+
+// HandleIncreaseTime delegates to chain package handler
+func HandleIncreaseTime(w http.ResponseWriter, r *http.Request) {
+	chain.HandleIncreaseTime(w, r)
+}
+
+// HandleNextBlockTimestamp delegates to chain package handler
+func HandleNextBlockTimestamp(w http.ResponseWriter, r *http.Request) {
+	chain.HandleNextBlockTimestamp(w, r)
+}
+
+// HandleFreezeTime delegates to chain package handler
+func HandleFreezeTime(w http.ResponseWriter, r *http.Request) {
+	chain.HandleFreezeTime(w, r)
+}
+
+// HandleChainEvents delegates to chain package handler
+func HandleChainEvents(w http.ResponseWriter, r *http.Request) {
+	chain.HandleEvents(w, r)
+}
+
+// HandleChainControl delegates to chain package handler
+func HandleChainControl(w http.ResponseWriter, r *http.Request) {
+	chain.HandleControl(w, r)
+}
+
+// HandleChainReorg delegates to reorg package handler
+func HandleChainReorg(w http.ResponseWriter, r *http.Request) {
+	reorg.HandleReorg(w, r)
+}
+
+// HandleNetwork delegates to network package handler
+func HandleNetwork(w http.ResponseWriter, r *http.Request) {
+	network.HandleNetwork(w, r)
+}
+
+// HandleStateProof delegates to proof package handler
+func HandleStateProof(w http.ResponseWriter, r *http.Request) {
+	proof.HandleProof(w, r)
+}
+
+// HandleStateEvents delegates to state package handler
+func HandleStateEvents(w http.ResponseWriter, r *http.Request) {
+	state.HandleEvents(w, r)
+}
+
+// HandleDebugStateKeys delegates to state package handler
+func HandleDebugStateKeys(w http.ResponseWriter, r *http.Request) {
+	state.HandleDebugKeys(w, r)
+}
+
+// HandleDebugStateKey delegates to state package handler
+func HandleDebugStateKey(w http.ResponseWriter, r *http.Request) {
+	state.HandleDebugKey(w, r)
+}
+
+// HandleStateExport delegates to dump package handler
+func HandleStateExport(w http.ResponseWriter, r *http.Request) {
+	dump.HandleExport(w, r)
+}
+
+// HandleStateImport delegates to dump package handler
+func HandleStateImport(w http.ResponseWriter, r *http.Request) {
+	dump.HandleImport(w, r)
+}
+
+// HandleSnapshots delegates to snapshot package handler
+func HandleSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshot.HandleSnapshots(w, r)
+}
+
+// HandleSnapshotRevert delegates to snapshot package handler
+func HandleSnapshotRevert(w http.ResponseWriter, r *http.Request) {
+	snapshot.HandleRevert(w, r)
 }
 
 // HandleFTSOPrice delegates to ftso package handler
@@ -67,6 +176,46 @@ func HandleFTSOPriceHistory(w http.ResponseWriter, r *http.Request) {
 	ftso.HandlePriceHistory(w, r)
 }
 
+// HandleFTSORetention delegates to ftso package handler
+func HandleFTSORetention(w http.ResponseWriter, r *http.Request) {
+	ftso.HandleRetention(w, r)
+}
+
+// HandleFTSODecimals delegates to ftso package handler
+func HandleFTSODecimals(w http.ResponseWriter, r *http.Request) {
+	ftso.HandleDecimals(w, r)
+}
+
+// HandleFTSORound delegates to ftso package handler
+func HandleFTSORound(w http.ResponseWriter, r *http.Request) {
+	ftso.HandleRound(w, r)
+}
+
+// HandleFTSOAnchor delegates to ftso package handler
+func HandleFTSOAnchor(w http.ResponseWriter, r *http.Request) {
+	ftso.HandleAnchor(w, r)
+}
+
+// HandleFTSOFastUpdates delegates to ftso package handler
+func HandleFTSOFastUpdates(w http.ResponseWriter, r *http.Request) {
+	ftso.HandleFastUpdates(w, r)
+}
+
+// HandleFTSOProviders delegates to ftso package handler
+func HandleFTSOProviders(w http.ResponseWriter, r *http.Request) {
+	ftso.HandleProviders(w, r)
+}
+
+// HandleFTSOSubmissions delegates to ftso package handler
+func HandleFTSOSubmissions(w http.ResponseWriter, r *http.Request) {
+	ftso.HandleSubmissions(w, r)
+}
+
+// HandleFTSOFeeds delegates to ftso package handler
+func HandleFTSOFeeds(w http.ResponseWriter, r *http.Request) {
+	ftso.HandleFeeds(w, r)
+}
+
 // HandleFTSOAllPrices handles GET /ftso/prices - returns all FTSO prices
 func HandleFTSOAllPrices(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -103,21 +252,32 @@ func HandleInjectFTSO(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	price, err := strconv.ParseFloat(priceStr, 64)
+	decimals, err := ftso.GetDecimals(asset)
 	if err != nil {
-		http.Error(w, "Invalid price format", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := ftso.ParsePrice(priceStr, decimals); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err = ftso.SetPrice(asset, price)
+	priceObj, err := ftso.SubmitPriceDecimal(asset, priceStr)
 	if err != nil {
 		http.Error(w, "Error setting price", http.StatusInternalServerError)
 		return
 	}
 
-	priceObj, _ := ftso.GetPrice(asset)
+	// Respond with the block that includes the write. With voting rounds the
+	// price is pending until its round ends (round is null otherwise).
+	response := map[string]interface{}{
+		"price": priceObj,
+		"round": ftso.GetRound(priceObj.VotingRoundID),
+		"block": chain.WaitForInclusion(priceObj.BlockNum),
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(priceObj)
+	json.NewEncoder(w).Encode(response)
 }
 
 // HandleFDCFeed delegates to fdc package handler
@@ -135,6 +295,16 @@ func HandleFDCHistory(w http.ResponseWriter, r *http.Request) {
 	fdc.HandleFeedHistory(w, r)
 }
 
+// HandleFDCRetention delegates to fdc package handler
+func HandleFDCRetention(w http.ResponseWriter, r *http.Request) {
+	fdc.HandleRetention(w, r)
+}
+
+// HandleFDCTTL delegates to fdc package handler
+func HandleFDCTTL(w http.ResponseWriter, r *http.Request) {
+	fdc.HandleTTL(w, r)
+}
+
 // HandleFDCList delegates to fdc package handler
 func HandleFDCList(w http.ResponseWriter, r *http.Request) {
 	fdc.HandleListFeeds(w, r)
@@ -16,14 +16,48 @@ func NewServer(port string) *Server {
 
 	// Register routes
 	mux.HandleFunc("/status", HandleStatus)
+	mux.HandleFunc("/network", HandleNetwork)
 	mux.HandleFunc("/block/latest", HandleLatestBlock)
+	mux.HandleFunc("/block/{number}", HandleBlock)
+	mux.HandleFunc("/blocks", HandleBlocks)
+	mux.HandleFunc("/chain/verify", HandleChainVerify)
+	mux.HandleFunc("/chain/mine", HandleChainMine)
+	mux.HandleFunc("/chain/mining", HandleChainMining)
+	mux.HandleFunc("/chain/events", HandleChainEvents)
+	mux.HandleFunc("/chain/pause", HandleChainControl)
+	mux.HandleFunc("/chain/resume", HandleChainControl)
+	mux.HandleFunc("/chain/restart", HandleChainControl)
+	mux.HandleFunc("/chain/reorg", HandleChainReorg)
+	mux.HandleFunc("/state/proof", HandleStateProof)
+	mux.HandleFunc("/state/events", HandleStateEvents)
+	mux.HandleFunc("/state/export", HandleStateExport)
+	mux.HandleFunc("/state/import", HandleStateImport)
+	mux.HandleFunc("/debug/state/keys", HandleDebugStateKeys)
+	mux.HandleFunc("/debug/state/{key...}", HandleDebugStateKey)
+	mux.HandleFunc("/snapshot", HandleSnapshots)
+	mux.HandleFunc("/snapshot/revert", HandleSnapshotRevert)
+	mux.HandleFunc("/time", HandleTime)
+	mux.HandleFunc("/time/increase", HandleIncreaseTime)
+	mux.HandleFunc("/time/next-block", HandleNextBlockTimestamp)
+	mux.HandleFunc("/time/freeze", HandleFreezeTime)
+	mux.HandleFunc("/time/unfreeze", HandleFreezeTime)
 	mux.HandleFunc("/ftso/price", HandleFTSOPrice)
 	mux.HandleFunc("/ftso/prices", HandleFTSOAllPrices)
 	mux.HandleFunc("/ftso/history", HandleFTSOPriceHistory)
+	mux.HandleFunc("/ftso/retention", HandleFTSORetention)
 	mux.HandleFunc("/ftso/inject", HandleInjectFTSO)
+	mux.HandleFunc("/ftso/feeds", HandleFTSOFeeds)
+	mux.HandleFunc("/ftso/decimals", HandleFTSODecimals)
+	mux.HandleFunc("/ftso/round", HandleFTSORound)
+	mux.HandleFunc("/ftso/anchor", HandleFTSOAnchor)
+	mux.HandleFunc("/ftso/fast-updates", HandleFTSOFastUpdates)
+	mux.HandleFunc("/ftso/providers", HandleFTSOProviders)
+	mux.HandleFunc("/ftso/submissions", HandleFTSOSubmissions)
 	mux.HandleFunc("/fdc/feed", HandleFDCFeed)
 	mux.HandleFunc("/fdc/inject", HandleFDCInject)
 	mux.HandleFunc("/fdc/history", HandleFDCHistory)
+	mux.HandleFunc("/fdc/retention", HandleFDCRetention)
+	mux.HandleFunc("/fdc/ttl", HandleFDCTTL)
 	mux.HandleFunc("/fdc/list", HandleFDCList)
 	mux.HandleFunc("/rpc", HandleJSONRPC)
 
@@ -0,0 +1,104 @@
+package snapshot
+
+import (
+	"fmt"
+	"lfts/internal/autoupdate"
+	"lfts/internal/chain"
+	"lfts/internal/state"
+	"sort"
+	"sync"
+)
+
+// Snapshot captures the whole sandbox: state storage, chain and auto-updater
+type Snapshot struct {
+	ID         uint64
+	State      *state.Snapshot
+	Chain      *chain.Snapshot
+	BasePrices map[string]float64
+}
+
+var (
+	mu        sync.Mutex
+	nextID    uint64 = 1
+	snapshots        = make(map[uint64]*Snapshot)
+)
+
+// Take captures the current sandbox and returns the snapshot ID
+func Take() (uint64, error) {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return 0, fmt.Errorf("chain not initialized")
+	}
+
+	snap := &Snapshot{}
+	chainInstance.Exclusive(func() {
+		snap.State = state.GlobalState.Snapshot()
+		snap.Chain = chainInstance.Snapshot()
+		snap.BasePrices = autoupdate.GetBasePrices()
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	snap.ID = nextID
+	nextID++
+	snapshots[snap.ID] = snap
+	return snap.ID, nil
+}
+
+// Revert restores the sandbox to the given snapshot. Like evm_revert, the
+// snapshot and every snapshot taken after it are discarded.
+func Revert(id uint64) error {
+	chainInstance := chain.GetInstance()
+	if chainInstance == nil {
+		return fmt.Errorf("chain not initialized")
+	}
+
+	mu.Lock()
+	snap, exists := snapshots[id]
+	if exists {
+		for other := range snapshots {
+			if other >= id {
+				delete(snapshots, other)
+			}
+		}
+	}
+	mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("snapshot not found: %d", id)
+	}
+
+	chainInstance.Exclusive(func() {
+		state.GlobalState.Restore(snap.State)
+		chainInstance.Restore(snap.Chain)
+		if snap.BasePrices != nil {
+			autoupdate.SetBasePrices(snap.BasePrices)
+		}
+	})
+	return nil
+}
+
+// Info describes a stored snapshot
+type Info struct {
+	ID     uint64 `json:"id"`
+	Height uint64 `json:"height"`
+	Keys   int    `json:"keys"`
+}
+
+// List returns the stored snapshots in ascending ID order
+func List() []Info {
+	mu.Lock()
+	defer mu.Unlock()
+	infos := make([]Info, 0, len(snapshots))
+	for _, snap := range snapshots {
+		infos = append(infos, Info{
+			ID:     snap.ID,
+			Height: snap.Chain.Height(),
+			Keys:   snap.State.Len(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
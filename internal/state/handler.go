@@ -0,0 +1,162 @@
+package state
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+// KeyInfo is a state key and the size of its current value
+type KeyInfo struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+}
+
+// KeyValue is a state key and its current value. JSON values are embedded as
+// JSON, other text as a string and binary values as 0x-prefixed hex.
+type KeyValue struct {
+	Key      string          `json:"key"`
+	Size     int             `json:"size"`
+	Encoding string          `json:"encoding"` // json, text or hex
+	Value    json.RawMessage `json:"value"`
+}
+
+// NewKeyValue describes a raw state value for display (JSON values are
+// pretty-printed when the KeyValue is encoded with indentation)
+func NewKeyValue(key string, value []byte) KeyValue {
+	kv := KeyValue{Key: key, Size: len(value)}
+	switch {
+	case json.Valid(value):
+		kv.Encoding, kv.Value = "json", value
+	case utf8.Valid(value):
+		kv.Encoding, kv.Value = "text", mustMarshal(string(value))
+	default:
+		kv.Encoding, kv.Value = "hex", mustMarshal("0x"+hex.EncodeToString(value))
+	}
+	return kv
+}
+
+// mustMarshal encodes a string as JSON
+func mustMarshal(s string) json.RawMessage {
+	data, _ := json.Marshal(s)
+	return data
+}
+
+// writeIndented writes v as indented JSON
+func writeIndented(w http.ResponseWriter, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// HandleDebugKeys handles GET /debug/state/keys?prefix=<prefix>&limit=<n> and
+// lists the keys in GlobalState starting with prefix in ascending order,
+// including pending writes
+func HandleDebugKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	keys := []KeyInfo{}
+	truncated := false
+	err := GlobalState.IteratePrefix(prefix, func(key string, value []byte) bool {
+		if limit > 0 && len(keys) == limit {
+			truncated = true
+			return false
+		}
+		keys = append(keys, KeyInfo{Key: key, Size: len(value)})
+		return true
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeIndented(w, map[string]interface{}{
+		"prefix":    prefix,
+		"count":     len(keys),
+		"truncated": truncated,
+		"keys":      keys,
+	})
+}
+
+// HandleDebugKey handles GET /debug/state/{key} and returns the raw value of a
+// key in GlobalState (including pending writes), pretty-printed if it is JSON
+func HandleDebugKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := r.PathValue("key")
+	value, err := GlobalState.Get(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if value == nil {
+		http.Error(w, "Key not found: "+key, http.StatusNotFound)
+		return
+	}
+
+	writeIndented(w, NewKeyValue(key, value))
+}
+
+// HandleEvents handles GET /state/events?prefix=<prefix> and streams the state
+// changes of matching keys as server-sent events
+func HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	changes, cancel := GlobalState.Subscribe(r.URL.Query().Get("prefix"))
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(change)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: change\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lfts/internal/utils"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultCompactEvery is the number of log records after which the state log is
+// rewritten as a single checkpoint
+const DefaultCompactEvery = 10000
+
+// Log record operations
+const (
+	opSet        = "set"
+	opDelete     = "delete"
+	opBatch      = "batch"
+	opCommit     = "commit"
+	opRollback   = "rollback"
+	opCheckpoint = "checkpoint"
+)
+
+// logRecord is one line of the state log
+type logRecord struct {
+	Op       string    `json:"op"`
+	Key      string    `json:"key,omitempty"`
+	Value    []byte    `json:"value,omitempty"`
+	Writes   []Write   `json:"writes,omitempty"`
+	Block    uint64    `json:"block,omitempty"`
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+}
+
+// LogStorage is a persistent Storage backed by an append-only log of JSON
+// records. The state is kept in memory and rebuilt by replaying the log on
+// open. The log is synced on every block commit and periodically compacted
+// into a single checkpoint record.
+type LogStorage struct {
+	mu           sync.Mutex // serializes log appends with the in-memory updates
+	mem          *MemoryStorage
+	path         string
+	file         *os.File
+	w            *bufio.Writer
+	records      int
+	compactEvery int
+}
+
+// OpenLogStorage opens (or creates) the state log at path and replays it,
+// keeping historyDepth blocks of history (0 keeps every version). A torn record
+// at the end of the log (from a crash mid-write) is discarded.
+func OpenLogStorage(path string, historyDepth uint64) (*LogStorage, error) {
+	s := &LogStorage{
+		mem:          NewMemoryStorage(),
+		path:         path,
+		compactEvery: DefaultCompactEvery,
+	}
+	s.mem.SetHistoryDepth(historyDepth)
+
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.openForAppend(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay applies every complete record in the log to the in-memory state
+func (s *LogStorage) replay() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open state log: %v", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return s.truncateTail(offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read state log: %v", err)
+		}
+
+		var record logRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+			return s.truncateTail(offset)
+		}
+		if err := s.apply(record); err != nil {
+			return fmt.Errorf("state log record %d: %v", s.records+1, err)
+		}
+		s.records++
+		offset += int64(len(line))
+	}
+}
+
+// truncateTail drops an incomplete record at the end of the log
+func (s *LogStorage) truncateTail(offset int64) error {
+	utils.Info("Discarding incomplete record at the end of %s", s.path)
+	if err := os.Truncate(s.path, offset); err != nil {
+		return fmt.Errorf("failed to truncate state log: %v", err)
+	}
+	return nil
+}
+
+// apply replays a single record against the in-memory state
+func (s *LogStorage) apply(record logRecord) error {
+	switch record.Op {
+	case opSet:
+		return s.mem.Set(record.Key, record.Value)
+	case opDelete:
+		return s.mem.Delete(record.Key)
+	case opBatch:
+		return s.mem.Update(func(tx *Tx) error {
+			for _, w := range record.Writes {
+				tx.write(w.Key, w.Value)
+			}
+			return nil
+		})
+	case opCommit:
+		s.mem.Commit(record.Block)
+	case opRollback:
+		return s.mem.Rollback(record.Block)
+	case opCheckpoint:
+		if record.Snapshot == nil {
+			return fmt.Errorf("checkpoint without snapshot")
+		}
+		s.mem.Restore(record.Snapshot)
+	default:
+		return fmt.Errorf("unknown operation %q", record.Op)
+	}
+	return nil
+}
+
+// openForAppend opens the log file for appending new records
+func (s *LogStorage) openForAppend() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open state log: %v", err)
+	}
+	s.file = f
+	s.w = bufio.NewWriter(f)
+	return nil
+}
+
+// append writes a record to the log buffer (caller holds the lock)
+func (s *LogStorage) append(record logRecord) error {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := s.w.Write(append(encoded, '\n')); err != nil {
+		return fmt.Errorf("failed to write state log: %v", err)
+	}
+	s.records++
+	return nil
+}
+
+// sync flushes buffered records and syncs the log to disk (caller holds the lock)
+func (s *LogStorage) sync() error {
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write state log: %v", err)
+	}
+	return s.file.Sync()
+}
+
+// Set stores a value for the given key
+func (s *LogStorage) Set(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(logRecord{Op: opSet, Key: key, Value: value}); err != nil {
+		return err
+	}
+	return s.mem.Set(key, value)
+}
+
+// Delete removes the key in the pending block
+func (s *LogStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(logRecord{Op: opDelete, Key: key}); err != nil {
+		return err
+	}
+	return s.mem.Delete(key)
+}
+
+// Update runs fn in a transaction. Its writes are logged as a single record, so
+// a crash never leaves part of a transaction in the log.
+func (s *LogStorage) Update(fn func(tx *Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mem.update(fn, func(writes []Write) error {
+		return s.append(logRecord{Op: opBatch, Writes: writes})
+	})
+}
+
+// CompareAndSwap sets the key to new if its current value is old and reports whether it did
+func (s *LogStorage) CompareAndSwap(key string, old, new []byte) (bool, error) {
+	return compareAndSwap(s, key, old, new)
+}
+
+// Get retrieves the current value (including pending writes) for the given key
+func (s *LogStorage) Get(key string) ([]byte, error) {
+	return s.mem.Get(key)
+}
+
+// GetAt retrieves the value the key had after the given block was sealed
+func (s *LogStorage) GetAt(key string, number uint64) ([]byte, error) {
+	return s.mem.GetAt(key, number)
+}
+
+// Has checks if a key exists
+func (s *LogStorage) Has(key string) bool {
+	return s.mem.Has(key)
+}
+
+// GetAllKeys returns all keys in the storage in ascending order (for debugging/status)
+func (s *LogStorage) GetAllKeys() []string {
+	return s.mem.GetAllKeys()
+}
+
+// IterateRange calls fn with every key in [start, end) and its current value in ascending key order
+func (s *LogStorage) IterateRange(start, end string, fn func(key string, value []byte) bool) error {
+	return s.mem.IterateRange(start, end, fn)
+}
+
+// IteratePrefix calls fn with every key starting with prefix and its current value in ascending key order
+func (s *LogStorage) IteratePrefix(prefix string, fn func(key string, value []byte) bool) error {
+	return s.mem.IteratePrefix(prefix, fn)
+}
+
+// Root returns the Merkle state root after the given block was sealed
+func (s *LogStorage) Root(number uint64) ([32]byte, error) {
+	return s.mem.Root(number)
+}
+
+// Proof returns an inclusion proof for the key in the state after the given block was sealed
+func (s *LogStorage) Proof(key string, number uint64) (*Proof, error) {
+	return s.mem.Proof(key, number)
+}
+
+// Head returns the number of the last committed block
+func (s *LogStorage) Head() uint64 {
+	return s.mem.Head()
+}
+
+// SetHistoryDepth sets how many recent blocks stay readable (0 keeps every version)
+func (s *LogStorage) SetHistoryDepth(blocks uint64) {
+	s.mem.SetHistoryDepth(blocks)
+}
+
+// Snapshot returns a copy of the storage, including history and pending writes
+func (s *LogStorage) Snapshot() *Snapshot {
+	return s.mem.Snapshot()
+}
+
+// Restore replaces the storage contents with a copy of the snapshot
+func (s *LogStorage) Restore(snap *Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mem.Restore(snap)
+	err := s.append(logRecord{Op: opCheckpoint, Snapshot: snap})
+	if err == nil {
+		err = s.sync()
+	}
+	if err != nil {
+		utils.Error("State log restore failed: %v", err)
+	}
+}
+
+// Commit seals the writes made since the last commit as belonging to the given
+// block and syncs the log. The log is compacted once it grows past the
+// compaction threshold.
+func (s *LogStorage) Commit(number uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mem.Commit(number)
+	err := s.append(logRecord{Op: opCommit, Block: number})
+	if err == nil {
+		err = s.sync()
+	}
+	if err != nil {
+		utils.Error("State log commit of block %d failed: %v", number, err)
+		return
+	}
+
+	if s.records >= s.compactEvery {
+		if err := s.compact(); err != nil {
+			utils.Error("State log compaction failed: %v", err)
+		}
+	}
+}
+
+// Rollback undoes all uncommitted writes and the writes of every committed block
+// after the given block number
+func (s *LogStorage) Rollback(number uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.Rollback(number); err != nil {
+		return err
+	}
+	if err := s.append(logRecord{Op: opRollback, Block: number}); err != nil {
+		return err
+	}
+	return s.sync()
+}
+
+// compact rewrites the log as a single checkpoint of the current versions
+// (caller holds the lock)
+func (s *LogStorage) compact() error {
+	encoded, err := json.Marshal(logRecord{Op: opCheckpoint, Snapshot: s.mem.Snapshot()})
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := writeFileSync(tmpPath, append(encoded, '\n')); err != nil {
+		return err
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(s.path))
+
+	s.records = 1
+	return s.openForAppend()
+}
+
+// Subscribe returns a channel receiving the changes of keys starting with prefix
+func (s *LogStorage) Subscribe(prefix string) (<-chan Change, func()) {
+	return s.mem.Subscribe(prefix)
+}
+
+// Close flushes the log and closes the file
+func (s *LogStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.sync(); err != nil {
+		return err
+	}
+	return s.file.Close()
+}
+
+// writeFileSync writes data to path and syncs it to disk
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir syncs a directory so a rename within it is durable
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
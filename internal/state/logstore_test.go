@@ -0,0 +1,169 @@
+package state
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openLog opens the state log at path, failing the test on error
+func openLog(t *testing.T, path string) *LogStorage {
+	t.Helper()
+	s, err := OpenLogStorage(path, 0)
+	if err != nil {
+		t.Fatalf("OpenLogStorage() error = %v", err)
+	}
+	return s
+}
+
+// writeBlocks writes and commits three blocks: a set, a transaction with a
+// deletion, and an uncommitted write
+func writeBlocks(t *testing.T, s *LogStorage) {
+	t.Helper()
+	s.Set("a", []byte("1"))
+	s.Set("b", []byte("2"))
+	s.Commit(1)
+	err := s.Update(func(tx *Tx) error {
+		tx.Set("a", []byte("3"))
+		return tx.Delete("b")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Commit(2)
+	s.Set("c", []byte("uncommitted"))
+}
+
+func TestLogStorageReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.log")
+	s := openLog(t, path)
+	writeBlocks(t, s)
+	root, _ := s.Root(2)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openLog(t, path)
+	defer s.Close()
+	tests := []struct {
+		key   string
+		block uint64
+		want  []byte
+	}{
+		{"a", 1, []byte("1")},
+		{"b", 1, []byte("2")},
+		{"a", 2, []byte("3")},
+		{"b", 2, nil},
+	}
+	for _, tt := range tests {
+		if got, err := s.GetAt(tt.key, tt.block); err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("GetAt(%s, %d) = %q, %v; want %q", tt.key, tt.block, got, err, tt.want)
+		}
+	}
+	if s.Head() != 2 {
+		t.Errorf("Head() = %d, want 2", s.Head())
+	}
+	if got, _ := s.Root(2); got != root {
+		t.Errorf("Root(2) = %x after replay, want %x", got, root)
+	}
+
+	// Writes after the last commit are replayed as pending; the chain rolls
+	// them back to the last persisted block on open
+	if err := s.Rollback(2); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Get("c"); got != nil {
+		t.Errorf("Get(c) = %q after rollback, want nil", got)
+	}
+}
+
+func TestLogStorageRecoversTail(t *testing.T) {
+	tests := []struct {
+		name string
+		tail string
+	}{
+		{"torn record", `{"op":"set","key":"d","val`},
+		{"corrupt last line", "not json\n"},
+		{"torn commit", `{"op":"com`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.log")
+			s := openLog(t, path)
+			writeBlocks(t, s)
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			intact, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString(tt.tail)
+			f.Close()
+
+			s = openLog(t, path)
+			if got, _ := s.GetAt("a", 2); string(got) != "3" {
+				t.Errorf("GetAt(a, 2) = %q, want 3", got)
+			}
+			if s.Head() != 2 {
+				t.Errorf("Head() = %d, want 2", s.Head())
+			}
+
+			// The tail is cut off, so new records follow the last complete one
+			if data, _ := os.ReadFile(path); !bytes.Equal(data, intact) {
+				t.Errorf("log was not truncated to its last complete record")
+			}
+			s.Set("d", []byte("4"))
+			s.Commit(3)
+			s.Close()
+
+			s = openLog(t, path)
+			defer s.Close()
+			if got, _ := s.GetAt("d", 3); string(got) != "4" {
+				t.Errorf("GetAt(d, 3) = %q after reopening, want 4", got)
+			}
+		})
+	}
+}
+
+func TestLogStorageCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.log")
+	s := openLog(t, path)
+	s.compactEvery = 10
+	for block := uint64(1); block <= 20; block++ {
+		s.Set("counter", []byte{byte(block)})
+		s.Set("block:"+string(rune('a'+block)), []byte{byte(block)})
+		s.Commit(block)
+	}
+	root, _ := s.Root(20)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) >= 10 || !strings.Contains(lines[0], `"op":"checkpoint"`) {
+		t.Fatalf("log has %d records starting with %.40s, want a compacted checkpoint", len(lines), lines[0])
+	}
+
+	s = openLog(t, path)
+	defer s.Close()
+	if got, _ := s.Root(20); got != root {
+		t.Errorf("Root(20) = %x after compaction, want %x", got, root)
+	}
+	for _, block := range []uint64{5, 12, 20} {
+		if got, _ := s.GetAt("counter", block); !bytes.Equal(got, []byte{byte(block)}) {
+			t.Errorf("GetAt(counter, %d) = %v, want %d", block, got, block)
+		}
+	}
+}
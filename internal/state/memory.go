@@ -0,0 +1,508 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultHistoryDepth is the number of recent blocks whose state stays readable
+// (and can be rolled back to)
+const DefaultHistoryDepth = 256
+
+// pruneInterval is the number of blocks between collapses of old versions
+const pruneInterval = 64
+
+// Version is the value of a key as written in a block. A nil value marks the
+// key as deleted in that block.
+type Version struct {
+	Block uint64   `json:"block"`
+	Value []byte   `json:"value"`
+	leaf  [32]byte // Merkle leaf of the key and value (see LeafHash)
+}
+
+// MemoryStorage provides thread-safe in-memory key-value storage. Every key
+// keeps one version per block it was written in, so past blocks can be read
+// (GetAt) and recent blocks rolled back (Rollback).
+type MemoryStorage struct {
+	mu           sync.RWMutex
+	versions     map[string][]Version // committed versions, oldest first
+	pending      map[string][]byte    // writes since the last commit
+	keys         []string             // every key (committed or pending), sorted
+	head         uint64               // last committed block
+	floor        uint64               // oldest block whose state is still readable
+	historyDepth uint64               // 0 keeps every version
+	tree         *merkleTree          // Merkle tree of the head state
+	roots        map[uint64][32]byte  // state roots of the readable blocks
+	subs         subscribers
+}
+
+// NewMemoryStorage creates a new in-memory storage instance
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		versions:     make(map[string][]Version),
+		pending:      make(map[string][]byte),
+		historyDepth: DefaultHistoryDepth,
+		tree:         newMerkleTree(nil, nil),
+		roots:        make(map[uint64][32]byte),
+	}
+}
+
+// Set stores a value for the given key in the pending block
+func (s *MemoryStorage) Set(key string, value []byte) error {
+	if value == nil {
+		value = []byte{} // nil is reserved for deletions
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, value)
+	return nil
+}
+
+// set stores a value in the pending block (caller holds the write lock)
+func (s *MemoryStorage) set(key string, value []byte) {
+	if _, exists := s.pending[key]; !exists && len(s.versions[key]) == 0 {
+		s.insertKey(key)
+	}
+	s.pending[key] = value
+}
+
+// Delete removes the key in the pending block. The state of earlier blocks
+// still contains it.
+func (s *MemoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(key)
+	return nil
+}
+
+// delete removes a key in the pending block (caller holds the write lock)
+func (s *MemoryStorage) delete(key string) {
+	if len(s.versions[key]) > 0 {
+		s.pending[key] = nil
+		return
+	}
+	if _, exists := s.pending[key]; exists {
+		// Never committed, so there is nothing to keep a tombstone for
+		delete(s.pending, key)
+		s.removeKey(key)
+	}
+}
+
+// Update runs fn in a transaction and applies its writes to the pending block
+// if fn returns nil. Transactions are serialized with all other access, so fn
+// must only use tx, not the storage itself.
+func (s *MemoryStorage) Update(fn func(tx *Tx) error) error {
+	return s.update(fn, nil)
+}
+
+// update runs a transaction, calling persist with its writes before they are
+// applied; an error from persist aborts the transaction
+func (s *MemoryStorage) update(fn func(tx *Tx) error, persist func(writes []Write) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := newTx(s.current)
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	writes := tx.Writes()
+	if persist != nil && len(writes) > 0 {
+		if err := persist(writes); err != nil {
+			return err
+		}
+	}
+	for _, w := range writes {
+		if w.Value == nil {
+			s.delete(w.Key)
+		} else {
+			s.set(w.Key, w.Value)
+		}
+	}
+	return nil
+}
+
+// CompareAndSwap sets the key to new if its current value is old (nil means
+// missing; a nil new deletes the key) and reports whether it did
+func (s *MemoryStorage) CompareAndSwap(key string, old, new []byte) (bool, error) {
+	return compareAndSwap(s, key, old, new)
+}
+
+// insertKey adds a new key to the sorted key index (caller holds the write lock)
+func (s *MemoryStorage) insertKey(key string) {
+	i := sort.SearchStrings(s.keys, key)
+	s.keys = append(s.keys, "")
+	copy(s.keys[i+1:], s.keys[i:])
+	s.keys[i] = key
+}
+
+// removeKey drops a key from the sorted key index (caller holds the write lock)
+func (s *MemoryStorage) removeKey(key string) {
+	i := sort.SearchStrings(s.keys, key)
+	if i < len(s.keys) && s.keys[i] == key {
+		s.keys = append(s.keys[:i], s.keys[i+1:]...)
+	}
+}
+
+// rebuildKeys recreates the sorted key index from the versions and pending
+// writes (caller holds the write lock)
+func (s *MemoryStorage) rebuildKeys() {
+	keys := make([]string, 0, len(s.versions)+len(s.pending))
+	for k := range s.versions {
+		keys = append(keys, k)
+	}
+	for k := range s.pending {
+		if _, exists := s.versions[k]; !exists {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	s.keys = keys
+}
+
+// Get retrieves the current value (including pending writes) for the given key
+func (s *MemoryStorage) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current(key), nil
+}
+
+// GetAt retrieves the value the key had after the given block was sealed
+func (s *MemoryStorage) GetAt(key string, number uint64) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.checkReadable(number); err != nil {
+		return nil, err
+	}
+
+	versions := s.versions[key]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Block <= number {
+			return versions[i].Value, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkReadable reports whether the state at a block is available (caller holds the lock)
+func (s *MemoryStorage) checkReadable(number uint64) error {
+	if number > s.head {
+		return fmt.Errorf("block %d has not been sealed yet (head is %d)", number, s.head)
+	}
+	if number < s.floor {
+		return fmt.Errorf("%w: state at block %d (history starts at block %d)", ErrHistoryPruned, number, s.floor)
+	}
+	return nil
+}
+
+// Has checks if a key exists
+func (s *MemoryStorage) Has(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if value, exists := s.pending[key]; exists {
+		return value != nil
+	}
+	versions := s.versions[key]
+	return len(versions) > 0 && versions[len(versions)-1].Value != nil
+}
+
+// current returns the current value of a key, nil if missing or deleted (caller
+// holds the lock)
+func (s *MemoryStorage) current(key string) []byte {
+	if value, exists := s.pending[key]; exists {
+		return value
+	}
+	if versions := s.versions[key]; len(versions) > 0 {
+		return versions[len(versions)-1].Value
+	}
+	return nil
+}
+
+// GetAllKeys returns all keys in the storage in ascending order (for debugging/status)
+func (s *MemoryStorage) GetAllKeys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.keys))
+	for _, key := range s.keys {
+		if s.current(key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// IterateRange calls fn with every key in [start, end) and its current value
+// (including pending writes) in ascending key order, until fn returns false. An
+// empty end means no upper bound. The range is read up front, so fn may use the
+// storage.
+func (s *MemoryStorage) IterateRange(start, end string, fn func(key string, value []byte) bool) error {
+	s.mu.RLock()
+	from := sort.SearchStrings(s.keys, start)
+	to := len(s.keys)
+	if end != "" {
+		to = from + sort.SearchStrings(s.keys[from:], end)
+	}
+	keys := make([]string, 0, to-from)
+	values := make([][]byte, 0, to-from)
+	for _, key := range s.keys[from:to] {
+		if value := s.current(key); value != nil {
+			keys = append(keys, key)
+			values = append(values, value)
+		}
+	}
+	s.mu.RUnlock()
+
+	for i, key := range keys {
+		if !fn(key, values[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+// IteratePrefix calls fn with every key starting with prefix and its current
+// value in ascending key order, until fn returns false
+func (s *MemoryStorage) IteratePrefix(prefix string, fn func(key string, value []byte) bool) error {
+	return s.IterateRange(prefix, PrefixEnd(prefix), fn)
+}
+
+// Head returns the number of the last committed block
+func (s *MemoryStorage) Head() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.head
+}
+
+// SetHistoryDepth sets how many recent blocks stay readable (0 keeps every version)
+func (s *MemoryStorage) SetHistoryDepth(blocks uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.historyDepth = blocks
+	s.prune()
+}
+
+// Commit seals the writes made since the last commit as the given block's versions
+func (s *MemoryStorage) Commit(number uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes []Change
+	notify := s.subs.active()
+	leaves := make(map[string][32]byte, len(s.pending))
+	for key, value := range s.pending {
+		version := Version{Block: number, Value: value, leaf: LeafHash(key, value)}
+		leaves[key] = version.leaf
+		versions := s.versions[key]
+		if notify {
+			var old []byte
+			if n := len(versions); n > 0 {
+				old = versions[n-1].Value
+			}
+			if changed(old, value) {
+				changes = append(changes, Change{Key: key, Old: old, New: value, Block: number})
+			}
+		}
+		if n := len(versions); n > 0 && versions[n-1].Block == number {
+			versions[n-1] = version
+		} else {
+			s.versions[key] = append(versions, version)
+		}
+	}
+	s.tree.update(s.pending, leaves)
+	s.roots[number] = s.tree.root()
+	s.pending = make(map[string][]byte)
+	s.head = number
+
+	if number%pruneInterval == 0 {
+		s.prune()
+	}
+	if len(changes) > 0 {
+		s.subs.publish(changes)
+	}
+}
+
+// prune collapses the versions older than the history window into one version
+// per key (caller holds the write lock)
+func (s *MemoryStorage) prune() {
+	if s.historyDepth == 0 || s.head <= s.historyDepth {
+		return
+	}
+	boundary := s.head - s.historyDepth
+	if boundary <= s.floor {
+		return
+	}
+
+	removed := false
+	for key, versions := range s.versions {
+		// Keep the newest version at or before the boundary and everything after it
+		keep := 0
+		for keep+1 < len(versions) && versions[keep+1].Block <= boundary {
+			keep++
+		}
+		if keep == len(versions)-1 && versions[keep].Value == nil {
+			// Deleted before the window: no readable block has the key
+			delete(s.versions, key)
+			removed = true
+			continue
+		}
+		if keep > 0 {
+			s.versions[key] = append([]Version{}, versions[keep:]...)
+		}
+	}
+	if removed {
+		s.rebuildKeys()
+	}
+	for number := range s.roots {
+		if number < boundary {
+			delete(s.roots, number)
+		}
+	}
+	s.floor = boundary
+}
+
+// Rollback undoes all uncommitted writes and the writes of every committed block
+// after the given block number
+func (s *MemoryStorage) Rollback(number uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if number > s.head {
+		return fmt.Errorf("cannot roll back to future block %d", number)
+	}
+	if number < s.floor {
+		return fmt.Errorf("cannot roll back to block %d: history only covers blocks from %d", number, s.floor)
+	}
+
+	var changes []Change
+	notify := s.subs.active()
+	s.pending = make(map[string][]byte)
+	for key, versions := range s.versions {
+		keep := len(versions)
+		for keep > 0 && versions[keep-1].Block > number {
+			keep--
+		}
+		if notify && keep < len(versions) {
+			var restored []byte
+			if keep > 0 {
+				restored = versions[keep-1].Value
+			}
+			if old := versions[len(versions)-1].Value; changed(old, restored) {
+				changes = append(changes, Change{Key: key, Old: old, New: restored, Block: number, Rollback: true})
+			}
+		}
+		switch {
+		case keep == 0:
+			delete(s.versions, key)
+		case keep < len(versions):
+			s.versions[key] = versions[:keep:keep]
+		}
+	}
+	s.rebuildKeys()
+	s.head = number
+	for block := range s.roots {
+		if block > number {
+			delete(s.roots, block)
+		}
+	}
+	s.resetTree()
+	if len(changes) > 0 {
+		s.subs.publish(changes)
+	}
+	return nil
+}
+
+// Snapshot returns a copy of the storage, including history and pending writes
+func (s *MemoryStorage) Snapshot() *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Values are never modified in place, so they can be shared
+	snap := &Snapshot{
+		Versions: make(map[string][]Version, len(s.versions)),
+		Pending:  make(map[string][]byte, len(s.pending)),
+		Head:     s.head,
+		Floor:    s.floor,
+	}
+	for k, versions := range s.versions {
+		snap.Versions[k] = append([]Version(nil), versions...)
+	}
+	for k, v := range s.pending {
+		snap.Pending[k] = v
+	}
+	return snap
+}
+
+// Restore replaces the storage contents with a copy of the snapshot
+func (s *MemoryStorage) Restore(snap *Snapshot) {
+	versions := make(map[string][]Version, len(snap.Versions))
+	for k, v := range snap.Versions {
+		copied := append([]Version(nil), v...)
+		// Leaves are not serialized, so recompute them for decoded snapshots
+		for i := range copied {
+			if copied[i].leaf == ([32]byte{}) {
+				copied[i].leaf = LeafHash(k, copied[i].Value)
+			}
+		}
+		versions[k] = copied
+	}
+	pending := make(map[string][]byte, len(snap.Pending))
+	for k, v := range snap.Pending {
+		pending[k] = v
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var changes []Change
+	if s.subs.active() {
+		changes = diffLatest(s.versions, versions, snap.Head)
+	}
+	s.versions = versions
+	s.pending = pending
+	s.head = snap.Head
+	s.floor = snap.Floor
+	s.rebuildKeys()
+	s.roots = make(map[uint64][32]byte)
+	s.resetTree()
+	if len(changes) > 0 {
+		s.subs.publish(changes)
+	}
+}
+
+// diffLatest returns the changes between the latest committed values of two
+// version sets, reported as a rollback to block
+func diffLatest(before, after map[string][]Version, block uint64) []Change {
+	latest := func(versions []Version) []byte {
+		if len(versions) == 0 {
+			return nil
+		}
+		return versions[len(versions)-1].Value
+	}
+
+	var changes []Change
+	for key, versions := range before {
+		if old, restored := latest(versions), latest(after[key]); changed(old, restored) {
+			changes = append(changes, Change{Key: key, Old: old, New: restored, Block: block, Rollback: true})
+		}
+	}
+	for key, versions := range after {
+		if _, seen := before[key]; !seen && latest(versions) != nil {
+			changes = append(changes, Change{Key: key, New: latest(versions), Block: block, Rollback: true})
+		}
+	}
+	return changes
+}
+
+// Subscribe returns a channel receiving the changes of keys starting with prefix
+// ("" for all keys) and a function that cancels the subscription. Changes are
+// delivered when blocks are committed or rolled back; slow subscribers miss
+// changes rather than stall the chain.
+func (s *MemoryStorage) Subscribe(prefix string) (<-chan Change, func()) {
+	return s.subs.subscribe(prefix)
+}
+
+// Close is a no-op for in-memory storage
+func (s *MemoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,375 @@
+package state
+
+import (
+	"encoding/hex"
+	"fmt"
+	"lfts/internal/utils"
+	"sort"
+	"strings"
+)
+
+// The state root is a binary Merkle tree over all keys in ascending order:
+//
+//	leaf = keccak256(0x00 || keccak256(key) || keccak256(value))
+//	node = keccak256(0x01 || left || right)
+//
+// A node without a sibling on its level is promoted to the next level
+// unchanged. The root of an empty state is EmptyRoot.
+
+// EmptyRoot is the root of an empty state (keccak256(rlp("")), as reported for
+// an empty trie)
+var EmptyRoot = utils.Keccak256([]byte{0x80})
+
+// Proof sides
+const (
+	SideLeft  = "left"
+	SideRight = "right"
+)
+
+// ProofNode is a sibling hash on the path from a leaf to the root
+type ProofNode struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"` // side of the sibling: "left" or "right"
+}
+
+// Proof shows that a key had a value in the state committed by a block
+type Proof struct {
+	Key       string      `json:"key"`
+	Value     string      `json:"value"` // 0x-prefixed hex of the stored bytes
+	Block     uint64      `json:"block"`
+	StateRoot string      `json:"stateRoot"`
+	Leaf      string      `json:"leaf"`
+	Index     int         `json:"index"`
+	LeafCount int         `json:"leafCount"`
+	Siblings  []ProofNode `json:"siblings"`
+}
+
+// LeafHash returns the Merkle leaf of a key-value pair
+func LeafHash(key string, value []byte) [32]byte {
+	keyHash := utils.Keccak256([]byte(key))
+	valueHash := utils.Keccak256(value)
+	return utils.Keccak256([]byte{0x00}, keyHash[:], valueHash[:])
+}
+
+// nodeHash returns the parent of two Merkle nodes
+func nodeHash(left, right [32]byte) [32]byte {
+	return utils.Keccak256([]byte{0x01}, left[:], right[:])
+}
+
+// merkleRoot computes the root over leaves and, if index >= 0, the sibling path
+// of the leaf at index
+func merkleRoot(leaves [][32]byte, index int) ([32]byte, []ProofNode) {
+	if len(leaves) == 0 {
+		return EmptyRoot, nil
+	}
+
+	var path []ProofNode
+	level := leaves
+	for len(level) > 1 {
+		next := make([][32]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, nodeHash(level[i], level[i+1]))
+
+			switch index {
+			case i:
+				path = append(path, ProofNode{Hash: hexHash(level[i+1]), Position: SideRight})
+			case i + 1:
+				path = append(path, ProofNode{Hash: hexHash(level[i]), Position: SideLeft})
+			}
+		}
+		if index >= 0 {
+			index /= 2
+		}
+		level = next
+	}
+	return level[0], path
+}
+
+// parentNode returns node j of the level above level: the hash of its two
+// children, or its only child promoted unchanged
+func parentNode(level [][32]byte, j int) [32]byte {
+	if 2*j+1 < len(level) {
+		return nodeHash(level[2*j], level[2*j+1])
+	}
+	return level[2*j]
+}
+
+// merkleTree is the Merkle tree of the head state. Commit updates it with the
+// keys written in the block, so sealing a block rehashes only the nodes above
+// changed leaves instead of the whole state.
+type merkleTree struct {
+	keys   []string     // keys of the leaves, ascending
+	levels [][][32]byte // levels[0] are the leaves, the last level holds the root
+}
+
+// newMerkleTree builds the tree over sorted keys and their leaves
+func newMerkleTree(keys []string, leaves [][32]byte) *merkleTree {
+	t := &merkleTree{keys: keys}
+	t.rebuild(leaves, 0)
+	return t
+}
+
+// rebuild replaces the leaves and rehashes the nodes covering leaves at or
+// after index from; the nodes before it are kept
+func (t *merkleTree) rebuild(leaves [][32]byte, from int) {
+	old := t.levels
+	t.levels = [][][32]byte{leaves}
+	level := leaves
+	for depth := 1; len(level) > 1; depth++ {
+		from /= 2
+		next := make([][32]byte, (len(level)+1)/2)
+		kept := 0
+		if depth < len(old) {
+			kept = copy(next[:min(from, len(next))], old[depth])
+		}
+		for j := kept; j < len(next); j++ {
+			next[j] = parentNode(level, j)
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+}
+
+// setLeaf replaces the leaf at index and rehashes its path to the root
+func (t *merkleTree) setLeaf(index int, leaf [32]byte) {
+	t.levels[0][index] = leaf
+	for depth := 1; depth < len(t.levels); depth++ {
+		index /= 2
+		t.levels[depth][index] = parentNode(t.levels[depth-1], index)
+	}
+}
+
+// update applies the writes of a block (nil values delete keys) given with
+// their leaves
+func (t *merkleTree) update(writes map[string][]byte, leaves map[string][32]byte) {
+	keys := make([]string, 0, len(writes))
+	structural := false
+	for key, value := range writes {
+		i := sort.SearchStrings(t.keys, key)
+		present := i < len(t.keys) && t.keys[i] == key
+		if value == nil && !present {
+			continue
+		}
+		if value == nil || !present {
+			structural = true
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	// Only values changed: the shape of the tree is the same
+	if !structural {
+		for _, key := range keys {
+			t.setLeaf(sort.SearchStrings(t.keys, key), leaves[key])
+		}
+		return
+	}
+
+	// Merge the changes into the sorted leaves; nodes covering leaves before
+	// the first change keep their hashes
+	sort.Strings(keys)
+	oldLeaves := t.levels[0]
+	newKeys := make([]string, 0, len(t.keys)+len(keys))
+	newLeaves := make([][32]byte, 0, len(t.keys)+len(keys))
+	from := -1
+	i := 0
+	for _, key := range keys {
+		for i < len(t.keys) && t.keys[i] < key {
+			newKeys = append(newKeys, t.keys[i])
+			newLeaves = append(newLeaves, oldLeaves[i])
+			i++
+		}
+		if from < 0 {
+			from = len(newKeys)
+		}
+		if i < len(t.keys) && t.keys[i] == key {
+			i++
+		}
+		if writes[key] != nil {
+			newKeys = append(newKeys, key)
+			newLeaves = append(newLeaves, leaves[key])
+		}
+	}
+	newKeys = append(newKeys, t.keys[i:]...)
+	newLeaves = append(newLeaves, oldLeaves[i:]...)
+
+	t.keys = newKeys
+	t.rebuild(newLeaves, from)
+}
+
+// root returns the root of the tree
+func (t *merkleTree) root() [32]byte {
+	if len(t.keys) == 0 {
+		return EmptyRoot
+	}
+	return t.levels[len(t.levels)-1][0]
+}
+
+// proof returns the sibling path of the leaf at index
+func (t *merkleTree) proof(index int) []ProofNode {
+	path := []ProofNode{}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			position := SideRight
+			if sibling < index {
+				position = SideLeft
+			}
+			path = append(path, ProofNode{Hash: hexHash(level[sibling]), Position: position})
+		}
+		index /= 2
+	}
+	return path
+}
+
+// VerifyProof checks that the proof links its key and value to its state root
+func VerifyProof(p *Proof) error {
+	value, err := decodeHex(p.Value)
+	if err != nil {
+		return fmt.Errorf("invalid value: %v", err)
+	}
+
+	node := LeafHash(p.Key, value)
+	if p.Leaf != "" && p.Leaf != hexHash(node) {
+		return fmt.Errorf("leaf mismatch: have %s, want %s", p.Leaf, hexHash(node))
+	}
+
+	for i, sibling := range p.Siblings {
+		raw, err := decodeHex(sibling.Hash)
+		if err != nil || len(raw) != 32 {
+			return fmt.Errorf("invalid sibling %d", i)
+		}
+		var hash [32]byte
+		copy(hash[:], raw)
+
+		switch sibling.Position {
+		case SideLeft:
+			node = nodeHash(hash, node)
+		case SideRight:
+			node = nodeHash(node, hash)
+		default:
+			return fmt.Errorf("invalid sibling %d position: %q", i, sibling.Position)
+		}
+	}
+
+	if root := hexHash(node); root != strings.ToLower(p.StateRoot) {
+		return fmt.Errorf("root mismatch: proof yields %s, want %s", root, p.StateRoot)
+	}
+	return nil
+}
+
+// sortedLeaves returns the keys and leaves of the state at a block in ascending
+// key order by scanning every key's versions (caller holds the lock). Used to
+// rebuild the head tree and for roots and proofs of blocks no longer cached.
+func (s *MemoryStorage) sortedLeaves(number uint64) ([]string, [][32]byte) {
+	keys := make([]string, 0, len(s.keys))
+	leaves := make([][32]byte, 0, len(s.keys))
+	for _, key := range s.keys {
+		versions := s.versions[key]
+		for i := len(versions) - 1; i >= 0; i-- {
+			if versions[i].Block <= number {
+				if versions[i].Value != nil {
+					keys = append(keys, key)
+					leaves = append(leaves, versions[i].leaf)
+				}
+				break
+			}
+		}
+	}
+	return keys, leaves
+}
+
+// resetTree rebuilds the head tree from the committed versions and caches the
+// head root (caller holds the write lock)
+func (s *MemoryStorage) resetTree() {
+	s.tree = newMerkleTree(s.sortedLeaves(s.head))
+	s.roots[s.head] = s.tree.root()
+}
+
+// Root returns the state root after the given block was sealed. Roots are
+// computed when blocks are committed; only blocks whose root is no longer
+// cached (such as those before a restored snapshot's head) are recomputed.
+func (s *MemoryStorage) Root(number uint64) ([32]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.checkReadable(number); err != nil {
+		return [32]byte{}, err
+	}
+
+	if root, ok := s.roots[number]; ok {
+		return root, nil
+	}
+	_, leaves := s.sortedLeaves(number)
+	root, _ := merkleRoot(leaves, -1)
+	return root, nil
+}
+
+// Proof returns an inclusion proof for the key in the state after the given
+// block was sealed, or nil if the key did not exist then
+func (s *MemoryStorage) Proof(key string, number uint64) (*Proof, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.checkReadable(number); err != nil {
+		return nil, err
+	}
+
+	var (
+		keys     []string
+		leaves   [][32]byte
+		root     [32]byte
+		siblings []ProofNode
+	)
+	if number == s.head {
+		keys, leaves = s.tree.keys, s.tree.levels[0]
+	} else {
+		keys, leaves = s.sortedLeaves(number)
+	}
+	index := sort.SearchStrings(keys, key)
+	if index == len(keys) || keys[index] != key {
+		return nil, nil
+	}
+	if number == s.head {
+		root, siblings = s.tree.root(), s.tree.proof(index)
+	} else {
+		root, siblings = merkleRoot(leaves, index)
+	}
+
+	var value []byte
+	versions := s.versions[key]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Block <= number {
+			value = versions[i].Value
+			break
+		}
+	}
+
+	if siblings == nil {
+		siblings = []ProofNode{}
+	}
+	return &Proof{
+		Key:       key,
+		Value:     "0x" + hex.EncodeToString(value),
+		Block:     number,
+		StateRoot: hexHash(root),
+		Leaf:      hexHash(leaves[index]),
+		Index:     index,
+		LeafCount: len(leaves),
+		Siblings:  siblings,
+	}, nil
+}
+
+// hexHash formats a hash as 0x-prefixed hex
+func hexHash(h [32]byte) string {
+	return "0x" + hex.EncodeToString(h[:])
+}
+
+// decodeHex decodes 0x-prefixed hex
+func decodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+}
@@ -0,0 +1,72 @@
+package state
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestIncrementalRoot(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   int
+		writes int
+	}{
+		{"single key", 1, 1},
+		{"value updates", 8, 4},
+		{"inserts and deletes", 64, 16},
+		{"odd sizes", 37, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			s := NewMemoryStorage()
+			s.SetHistoryDepth(0)
+			for block := uint64(1); block <= 50; block++ {
+				for i := 0; i < tt.writes; i++ {
+					key := fmt.Sprintf("key:%d", rng.Intn(tt.keys))
+					if rng.Intn(4) == 0 {
+						s.Delete(key)
+					} else {
+						s.Set(key, []byte(fmt.Sprintf("%d:%d", block, i)))
+					}
+				}
+				s.Commit(block)
+
+				_, leaves := s.sortedLeaves(block)
+				want, _ := merkleRoot(leaves, -1)
+				if got, err := s.Root(block); err != nil || got != want {
+					t.Fatalf("block %d: Root() = %x, %v; want %x", block, got, err, want)
+				}
+				for _, key := range s.GetAllKeys() {
+					proof, err := s.Proof(key, block)
+					if err != nil || proof == nil {
+						t.Fatalf("block %d: Proof(%s) = %v, %v", block, key, proof, err)
+					}
+					if err := VerifyProof(proof); err != nil {
+						t.Fatalf("block %d: proof of %s: %v", block, key, err)
+					}
+				}
+			}
+
+			// Earlier roots are cached, and a rollback restores the head tree
+			for block := uint64(1); block <= 50; block += 7 {
+				_, leaves := s.sortedLeaves(block)
+				want, _ := merkleRoot(leaves, -1)
+				if got, _ := s.Root(block); got != want {
+					t.Errorf("block %d: cached Root() = %x, want %x", block, got, want)
+				}
+			}
+			if err := s.Rollback(20); err != nil {
+				t.Fatal(err)
+			}
+			s.Set("key:new", []byte("after rollback"))
+			s.Commit(21)
+			_, leaves := s.sortedLeaves(21)
+			want, _ := merkleRoot(leaves, -1)
+			if got, _ := s.Root(21); got != want {
+				t.Errorf("after rollback: Root() = %x, want %x", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ringHeader is the header of a ring, stored at the ring's key
+type ringHeader struct {
+	Retention int    `json:"retention"`
+	Start     uint64 `json:"start"` // sequence number of the oldest retained entry
+	Next      uint64 `json:"next"`  // sequence number of the next entry
+}
+
+// Ring is a fixed-capacity history of typed entries kept in state. The header is
+// stored at the ring's key and entry n at "<key>:<n % retention>", so an append
+// writes two small keys however long the history is. Once the ring is full,
+// each append replaces the oldest entry.
+type Ring[T any] struct {
+	rw        ReadWriter
+	key       string
+	retention int // used when the ring does not exist yet
+}
+
+// NewRing returns the ring stored at key in rw (a Storage, or a Tx to change
+// the ring atomically with other writes). retention is the capacity of a new
+// ring; an existing ring keeps its own (see SetRetention).
+func NewRing[T any](rw ReadWriter, key string, retention int) *Ring[T] {
+	return &Ring[T]{rw: rw, key: key, retention: retention}
+}
+
+// header reads the ring header (a new header if the ring does not exist)
+func (r *Ring[T]) header() (ringHeader, error) {
+	data, err := r.rw.Get(r.key)
+	if err != nil {
+		return ringHeader{}, err
+	}
+	if data == nil {
+		return ringHeader{Retention: r.retention}, nil
+	}
+
+	var h ringHeader
+	if err := json.Unmarshal(data, &h); err != nil {
+		return ringHeader{}, fmt.Errorf("invalid ring header at %s: %v", r.key, err)
+	}
+	return h, nil
+}
+
+// slotKey returns the key holding the entry with the given sequence number
+func (r *Ring[T]) slotKey(h ringHeader, seq uint64) string {
+	return r.key + ":" + strconv.FormatUint(seq%uint64(h.Retention), 10)
+}
+
+// entry reads the entry with the given sequence number
+func (r *Ring[T]) entry(h ringHeader, seq uint64) (T, error) {
+	var entry T
+	data, err := r.rw.Get(r.slotKey(h, seq))
+	if err != nil {
+		return entry, err
+	}
+	if data == nil {
+		return entry, fmt.Errorf("ring %s is missing entry %d", r.key, seq)
+	}
+	err = json.Unmarshal(data, &entry)
+	return entry, err
+}
+
+// Append adds an entry, replacing the oldest one if the ring is full
+func (r *Ring[T]) Append(entry T) error {
+	h, err := r.header()
+	if err != nil {
+		return err
+	}
+	if h.Retention <= 0 {
+		return fmt.Errorf("ring %s has no capacity", r.key)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := r.rw.Set(r.slotKey(h, h.Next), data); err != nil {
+		return err
+	}
+
+	h.Next++
+	if h.Next-h.Start > uint64(h.Retention) {
+		h.Start = h.Next - uint64(h.Retention)
+	}
+	return r.writeHeader(h)
+}
+
+// Prepend adds entries (oldest first) before the retained ones, keeping the
+// newest entries if they do not all fit. The ring is renumbered from 0, so this
+// is O(capacity); it is meant for seeding a ring with older history.
+func (r *Ring[T]) Prepend(entries []T) error {
+	h, err := r.header()
+	if err != nil {
+		return err
+	}
+	if h.Retention <= 0 {
+		return fmt.Errorf("ring %s has no capacity", r.key)
+	}
+
+	existing, err := r.read(h, h.Start, h.Next)
+	if err != nil {
+		return err
+	}
+	all := append(append([]T(nil), entries...), existing...)
+	if len(all) > h.Retention {
+		all = all[len(all)-h.Retention:]
+	}
+
+	renumbered := ringHeader{Retention: h.Retention, Next: uint64(len(all))}
+	for seq, entry := range all {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := r.rw.Set(r.slotKey(renumbered, uint64(seq)), data); err != nil {
+			return err
+		}
+	}
+
+	// Remove the old slots that are not rewritten
+	for slot := len(all); slot < h.Retention; slot++ {
+		key := r.key + ":" + strconv.Itoa(slot)
+		if r.rw.Has(key) {
+			if err := r.rw.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return r.writeHeader(renumbered)
+}
+
+// writeHeader stores the ring header
+func (r *Ring[T]) writeHeader(h ringHeader) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return r.rw.Set(r.key, data)
+}
+
+// Len returns the number of retained entries
+func (r *Ring[T]) Len() (int, error) {
+	h, err := r.header()
+	if err != nil {
+		return 0, err
+	}
+	return int(h.Next - h.Start), nil
+}
+
+// Retention returns the capacity of the ring
+func (r *Ring[T]) Retention() (int, error) {
+	h, err := r.header()
+	if err != nil {
+		return 0, err
+	}
+	return h.Retention, nil
+}
+
+// Last returns the newest n entries, oldest first (all entries if n <= 0)
+func (r *Ring[T]) Last(n int) ([]T, error) {
+	h, err := r.header()
+	if err != nil {
+		return nil, err
+	}
+
+	start := h.Start
+	if n > 0 && h.Next-start > uint64(n) {
+		start = h.Next - uint64(n)
+	}
+	return r.read(h, start, h.Next)
+}
+
+// Slice returns the entries with indexes in [i, j) (0 = oldest), clamped to the
+// retained entries
+func (r *Ring[T]) Slice(i, j int) ([]T, error) {
+	h, err := r.header()
+	if err != nil {
+		return nil, err
+	}
+
+	length := int(h.Next - h.Start)
+	i, j = max(i, 0), min(j, length)
+	if i >= j {
+		return []T{}, nil
+	}
+	return r.read(h, h.Start+uint64(i), h.Start+uint64(j))
+}
+
+// read returns the entries with sequence numbers in [from, to)
+func (r *Ring[T]) read(h ringHeader, from, to uint64) ([]T, error) {
+	entries := make([]T, 0, to-from)
+	for seq := from; seq < to; seq++ {
+		entry, err := r.entry(h, seq)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Search returns the index (0 = oldest) of the first entry for which f is true,
+// or Len if there is none. Like sort.Search, it assumes f is false for a prefix
+// of the entries and true for the rest, and reads only O(log n) entries.
+func (r *Ring[T]) Search(f func(T) bool) (int, error) {
+	h, err := r.header()
+	if err != nil {
+		return 0, err
+	}
+
+	var searchErr error
+	index := sort.Search(int(h.Next-h.Start), func(i int) bool {
+		entry, err := r.entry(h, h.Start+uint64(i))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return f(entry)
+	})
+	return index, searchErr
+}
+
+// At returns the entry at index i (0 = oldest)
+func (r *Ring[T]) At(i int) (T, error) {
+	h, err := r.header()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if i < 0 || uint64(i) >= h.Next-h.Start {
+		var zero T
+		return zero, fmt.Errorf("ring %s index %d out of range", r.key, i)
+	}
+	return r.entry(h, h.Start+uint64(i))
+}
+
+// SetRetention changes the capacity of the ring, dropping the oldest entries
+// if it shrinks. Entries are moved to their new slots, so this is O(capacity).
+func (r *Ring[T]) SetRetention(retention int) error {
+	if retention <= 0 {
+		return fmt.Errorf("retention must be positive")
+	}
+
+	h, err := r.header()
+	if err != nil {
+		return err
+	}
+	if h.Retention == retention {
+		// Persist the header so a new ring keeps the retention
+		return r.writeHeader(h)
+	}
+
+	// Read the entries that survive before moving any of them
+	start := h.Start
+	if h.Next-start > uint64(retention) {
+		start = h.Next - uint64(retention)
+	}
+	moved := make(map[string][]byte, h.Next-start)
+	for seq := start; seq < h.Next; seq++ {
+		data, err := r.rw.Get(r.slotKey(h, seq))
+		if err != nil {
+			return err
+		}
+		moved[r.slotKey(ringHeader{Retention: retention}, seq)] = data
+	}
+
+	// Remove the old slots that are not rewritten
+	for slot := 0; slot < h.Retention; slot++ {
+		key := r.key + ":" + strconv.Itoa(slot)
+		if _, keep := moved[key]; !keep && r.rw.Has(key) {
+			if err := r.rw.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	for key, data := range moved {
+		if err := r.rw.Set(key, data); err != nil {
+			return err
+		}
+	}
+
+	h.Retention = retention
+	h.Start = start
+	return r.writeHeader(h)
+}
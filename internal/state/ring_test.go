@@ -0,0 +1,207 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+// appendAll appends 1..n to a ring
+func appendAll(t *testing.T, r *Ring[int], n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		if err := r.Append(i); err != nil {
+			t.Fatalf("Append(%d) error = %v", i, err)
+		}
+	}
+}
+
+// seq returns the integers in [from, to]
+func seq(from, to int) []int {
+	out := []int{}
+	for i := from; i <= to; i++ {
+		out = append(out, i)
+	}
+	return out
+}
+
+func TestRingAppend(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention int
+		appends   int
+		want      []int
+	}{
+		{"empty", 3, 0, []int{}},
+		{"partly filled", 3, 2, seq(1, 2)},
+		{"exactly full", 3, 3, seq(1, 3)},
+		{"wrapped once", 3, 4, seq(2, 4)},
+		{"wrapped many times", 3, 11, seq(9, 11)},
+		{"single slot", 1, 5, seq(5, 5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStorage()
+			r := NewRing[int](s, "ring", tt.retention)
+			appendAll(t, r, tt.appends)
+
+			got, err := r.Last(0)
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Last(0) = %v, %v; want %v", got, err, tt.want)
+			}
+			if n, _ := r.Len(); n != len(tt.want) {
+				t.Errorf("Len() = %d, want %d", n, len(tt.want))
+			}
+
+			// The ring never uses more slots than its retention
+			slots := 0
+			s.IteratePrefix("ring:", func(string, []byte) bool { slots++; return true })
+			if slots > tt.retention {
+				t.Errorf("ring uses %d slots, retention is %d", slots, tt.retention)
+			}
+		})
+	}
+}
+
+func TestRingRead(t *testing.T) {
+	r := NewRing[int](NewMemoryStorage(), "ring", 5)
+	appendAll(t, r, 8) // holds 4..8
+
+	tests := []struct {
+		name string
+		read func() ([]int, error)
+		want []int
+	}{
+		{"last two", func() ([]int, error) { return r.Last(2) }, seq(7, 8)},
+		{"last more than held", func() ([]int, error) { return r.Last(10) }, seq(4, 8)},
+		{"slice", func() ([]int, error) { return r.Slice(1, 3) }, seq(5, 6)},
+		{"slice clamped", func() ([]int, error) { return r.Slice(-2, 20) }, seq(4, 8)},
+		{"empty slice", func() ([]int, error) { return r.Slice(3, 3) }, []int{}},
+		{"at oldest", func() ([]int, error) { v, err := r.At(0); return []int{v}, err }, []int{4}},
+		{"search", func() ([]int, error) {
+			i, err := r.Search(func(v int) bool { return v >= 6 })
+			return []int{i}, err
+		}, []int{2}},
+		{"search past the end", func() ([]int, error) {
+			i, err := r.Search(func(v int) bool { return v > 100 })
+			return []int{i}, err
+		}, []int{5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.read()
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+	if _, err := r.At(5); err == nil {
+		t.Errorf("At(5) on a ring of 5 entries did not fail")
+	}
+}
+
+func TestRingSetRetention(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention int
+		appends   int
+		resize    int
+		want      []int
+		afterNext []int // entries after appending one more
+	}{
+		{"shrink full ring", 5, 5, 3, seq(3, 5), seq(4, 6)},
+		{"shrink wrapped ring", 5, 12, 2, seq(11, 12), seq(12, 13)},
+		{"shrink below length", 5, 3, 2, seq(2, 3), seq(3, 4)},
+		{"shrink partly filled ring", 5, 2, 3, seq(1, 2), seq(1, 3)},
+		{"grow wrapped ring", 3, 7, 5, seq(5, 7), seq(5, 8)},
+		{"same retention", 3, 4, 3, seq(2, 4), seq(3, 5)},
+		{"new ring", 3, 0, 2, []int{}, seq(1, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStorage()
+			r := NewRing[int](s, "ring", tt.retention)
+			appendAll(t, r, tt.appends)
+
+			if err := r.SetRetention(tt.resize); err != nil {
+				t.Fatalf("SetRetention() error = %v", err)
+			}
+			if got, err := r.Last(0); err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("after resize: Last(0) = %v, %v; want %v", got, err, tt.want)
+			}
+			if got, _ := r.Retention(); got != tt.resize {
+				t.Errorf("Retention() = %d, want %d", got, tt.resize)
+			}
+
+			// Only the retained entries occupy slots
+			slots := 0
+			s.IteratePrefix("ring:", func(string, []byte) bool { slots++; return true })
+			if slots != len(tt.want) {
+				t.Errorf("ring uses %d slots for %d entries", slots, len(tt.want))
+			}
+
+			next := tt.appends + 1
+			if err := r.Append(next); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := r.Last(0); !reflect.DeepEqual(got, tt.afterNext) {
+				t.Errorf("after append: Last(0) = %v, want %v", got, tt.afterNext)
+			}
+		})
+	}
+
+	// A new ring keeps the retention set before its first append
+	s := NewMemoryStorage()
+	if err := NewRing[int](s, "ring", 10).SetRetention(2); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := NewRing[int](s, "ring", 10).Retention(); got != 2 {
+		t.Errorf("Retention() of a resized new ring = %d, want 2", got)
+	}
+	if err := NewRing[int](s, "ring", 10).SetRetention(0); err == nil {
+		t.Errorf("SetRetention(0) did not fail")
+	}
+}
+
+func TestRingPrepend(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention int
+		appends   int
+		prepend   []int
+		want      []int
+		afterNext []int // entries after appending one more
+	}{
+		{"new ring", 5, 0, seq(-2, 0), seq(-2, 0), seq(-2, 1)},
+		{"before entries", 5, 2, seq(-2, 0), seq(-2, 2), seq(-1, 3)},
+		{"keeps the newest", 5, 3, seq(-4, 0), seq(-1, 3), seq(0, 4)},
+		{"wrapped ring", 3, 7, seq(-1, 0), seq(5, 7), seq(6, 8)},
+		{"nothing", 3, 2, nil, seq(1, 2), seq(1, 3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStorage()
+			r := NewRing[int](s, "ring", tt.retention)
+			appendAll(t, r, tt.appends)
+
+			if err := r.Prepend(tt.prepend); err != nil {
+				t.Fatalf("Prepend() error = %v", err)
+			}
+			if got, err := r.Last(0); err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("after prepend: Last(0) = %v, %v; want %v", got, err, tt.want)
+			}
+
+			slots := 0
+			s.IteratePrefix("ring:", func(string, []byte) bool { slots++; return true })
+			if slots != len(tt.want) {
+				t.Errorf("ring uses %d slots for %d entries", slots, len(tt.want))
+			}
+
+			if err := r.Append(tt.appends + 1); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := r.Last(0); !reflect.DeepEqual(got, tt.afterNext) {
+				t.Errorf("after append: Last(0) = %v, want %v", got, tt.afterNext)
+			}
+		})
+	}
+}
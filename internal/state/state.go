@@ -1,13 +1,24 @@
 package state
 
-// GlobalState is the singleton state instance
-var GlobalState = NewStorage()
+// GlobalState is the singleton state instance. It is in-memory unless replaced
+// with a persistent backend (see OpenLogStorage) before the chain starts.
+var GlobalState Storage = NewMemoryStorage()
 
 // Set stores a value in global state
 func Set(key string, value []byte) error {
 	return GlobalState.Set(key, value)
 }
 
+// Delete removes a key from global state
+func Delete(key string) error {
+	return GlobalState.Delete(key)
+}
+
+// Update runs fn in a transaction on global state
+func Update(fn func(tx *Tx) error) error {
+	return GlobalState.Update(fn)
+}
+
 // Get retrieves a value from global state
 func Get(key string) ([]byte, error) {
 	return GlobalState.Get(key)
@@ -18,3 +29,14 @@ func Has(key string) bool {
 	return GlobalState.Has(key)
 }
 
+// GetAt retrieves a value from global state as of the given block
+func GetAt(key string, number uint64) ([]byte, error) {
+	return GlobalState.GetAt(key, number)
+}
+
+// IteratePrefix calls fn with every key in global state starting with prefix, in
+// ascending key order
+func IteratePrefix(prefix string, fn func(key string, value []byte) bool) error {
+	return GlobalState.IteratePrefix(prefix, fn)
+}
+
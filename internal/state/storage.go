@@ -1,57 +1,111 @@
 package state
 
-import (
-	"sync"
-)
-
-// Storage provides thread-safe in-memory key-value storage
-type Storage struct {
-	mu    sync.RWMutex
-	store map[string][]byte
-}
+import "errors"
 
-// NewStorage creates a new storage instance
-func NewStorage() *Storage {
-	return &Storage{
-		store: make(map[string][]byte),
-	}
-}
+// ErrHistoryPruned is returned when reading the state of a block that is older
+// than the retained history
+var ErrHistoryPruned = errors.New("state history pruned")
 
-// Set stores a value for the given key
-func (s *Storage) Set(key string, value []byte) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.store[key] = value
-	return nil
-}
+// Storage is a versioned key-value store. Writes made since the last Commit
+// belong to the pending block; every commit keeps the block's values as a new
+// version, so the state of recent blocks can be read (GetAt) or rolled back to.
+type Storage interface {
+	// Set stores a value for the given key in the pending block
+	Set(key string, value []byte) error
 
-// Get retrieves a value for the given key
-func (s *Storage) Get(key string) ([]byte, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	value, exists := s.store[key]
-	if !exists {
-		return nil, nil
-	}
-	return value, nil
+	// Delete removes the key in the pending block (earlier blocks still contain it)
+	Delete(key string) error
+
+	// Update runs fn in a transaction: its writes are applied to the pending
+	// block all together if fn returns nil and discarded otherwise. Transactions
+	// are serialized, and fn must only access the state through tx.
+	Update(fn func(tx *Tx) error) error
+
+	// CompareAndSwap sets the key to new if its current value is old (nil means
+	// missing; a nil new deletes the key) and reports whether it did
+	CompareAndSwap(key string, old, new []byte) (bool, error)
+
+	// Get retrieves the current value (including pending writes) for the given key (nil if missing)
+	Get(key string) ([]byte, error)
+
+	// GetAt retrieves the value the key had after the given block was sealed (nil if missing)
+	GetAt(key string, number uint64) ([]byte, error)
+
+	// Has checks if a key exists
+	Has(key string) bool
+
+	// GetAllKeys returns all keys in the storage in ascending order (for debugging/status)
+	GetAllKeys() []string
+
+	// IterateRange calls fn with every key in [start, end) and its current value
+	// in ascending key order, until fn returns false (an empty end is unbounded)
+	IterateRange(start, end string, fn func(key string, value []byte) bool) error
+
+	// IteratePrefix calls fn with every key starting with prefix and its current
+	// value in ascending key order, until fn returns false
+	IteratePrefix(prefix string, fn func(key string, value []byte) bool) error
+
+	// Root returns the Merkle state root after the given block was sealed
+	Root(number uint64) ([32]byte, error)
+
+	// Proof returns an inclusion proof for the key in the state after the given
+	// block was sealed (nil if the key did not exist)
+	Proof(key string, number uint64) (*Proof, error)
+
+	// Head returns the number of the last committed block
+	Head() uint64
+
+	// SetHistoryDepth sets how many recent blocks stay readable (0 keeps every version)
+	SetHistoryDepth(blocks uint64)
+
+	// Snapshot returns a copy of the storage, including history and pending writes
+	Snapshot() *Snapshot
+
+	// Restore replaces the storage contents with a copy of the snapshot
+	Restore(snap *Snapshot)
+
+	// Commit seals the writes since the last commit as the given block's versions
+	Commit(number uint64)
+
+	// Rollback undoes uncommitted writes and the writes of every block after number
+	Rollback(number uint64) error
+
+	// Subscribe returns a channel receiving the changes of keys starting with
+	// prefix as blocks are committed or rolled back, and a cancel function
+	Subscribe(prefix string) (<-chan Change, func())
+
+	// Close flushes and releases the storage
+	Close() error
 }
 
-// Has checks if a key exists
-func (s *Storage) Has(key string) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	_, exists := s.store[key]
-	return exists
+// Snapshot is a copy of a storage's versions and pending writes
+type Snapshot struct {
+	Versions map[string][]Version `json:"versions"`
+	Pending  map[string][]byte    `json:"pending,omitempty"`
+	Head     uint64               `json:"head"`
+	Floor    uint64               `json:"floor"`
 }
 
-// GetAllKeys returns all keys in the storage (for debugging/status)
-func (s *Storage) GetAllKeys() []string {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	keys := make([]string, 0, len(s.store))
-	for k := range s.store {
-		keys = append(keys, k)
+// Len returns the number of keys in the snapshot
+func (s *Snapshot) Len() int {
+	n := len(s.Versions)
+	for k := range s.Pending {
+		if _, exists := s.Versions[k]; !exists {
+			n++
+		}
 	}
-	return keys
+	return n
 }
 
+// PrefixEnd returns the smallest key greater than every key starting with prefix,
+// or "" if there is none (the prefix is empty or all 0xff bytes)
+func PrefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
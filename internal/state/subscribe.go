@@ -0,0 +1,112 @@
+package state
+
+import (
+	"encoding/json"
+	"lfts/internal/utils"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// changeBuffer is the number of changes buffered per subscriber before further
+// changes are dropped
+const changeBuffer = 1024
+
+// Change reports a key whose value changed when a block was committed, or when
+// the state was rolled back to a block (Rollback is then true). Old is nil for a
+// created key, New is nil for a deleted one.
+type Change struct {
+	Key      string
+	Old      []byte
+	New      []byte
+	Block    uint64
+	Rollback bool
+}
+
+// MarshalJSON encodes the values as text (null when missing)
+func (c Change) MarshalJSON() ([]byte, error) {
+	text := func(value []byte) *string {
+		if value == nil {
+			return nil
+		}
+		s := string(value)
+		return &s
+	}
+	return json.Marshal(struct {
+		Key      string  `json:"key"`
+		Old      *string `json:"old"`
+		New      *string `json:"new"`
+		Block    uint64  `json:"block"`
+		Rollback bool    `json:"rollback,omitempty"`
+	}{c.Key, text(c.Old), text(c.New), c.Block, c.Rollback})
+}
+
+// subscription is a subscriber to the changes of keys with a prefix
+type subscription struct {
+	prefix string
+	ch     chan Change
+}
+
+// subscribers holds the active change subscriptions of a storage
+type subscribers struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]*subscription
+}
+
+// subscribe registers a subscription for keys starting with prefix
+func (s *subscribers) subscribe(prefix string) (<-chan Change, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subs == nil {
+		s.subs = make(map[int]*subscription)
+	}
+	id := s.nextID
+	s.nextID++
+	sub := &subscription{prefix: prefix, ch: make(chan Change, changeBuffer)}
+	s.subs[id] = sub
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if sub, ok := s.subs[id]; ok {
+			delete(s.subs, id)
+			close(sub.ch)
+		}
+	}
+	return sub.ch, cancel
+}
+
+// active reports whether anyone is subscribed, so changes need not be computed
+func (s *subscribers) active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs) > 0
+}
+
+// publish delivers changes, in key order, to every subscriber whose prefix
+// matches without blocking
+func (s *subscribers) publish(changes []Change) {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sub := range s.subs {
+		for _, change := range changes {
+			if !strings.HasPrefix(change.Key, sub.prefix) {
+				continue
+			}
+			select {
+			case sub.ch <- change:
+			default:
+				utils.Error("State subscriber %d is full, dropping change of %s at block %d", id, change.Key, change.Block)
+			}
+		}
+	}
+}
+
+// changed reports whether two values differ (a missing value differs from an empty one)
+func changed(old, new []byte) bool {
+	return (old == nil) != (new == nil) || string(old) != string(new)
+}
@@ -0,0 +1,185 @@
+package state
+
+import (
+	"fmt"
+	"testing"
+)
+
+// received returns the changes buffered in ch without waiting; changes are
+// published before Commit, Rollback and Restore return
+func received(ch <-chan Change) []Change {
+	var got []Change
+	for {
+		select {
+		case change, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, change)
+		default:
+			return got
+		}
+	}
+}
+
+// describe renders a change compactly for comparison
+func describe(c Change) string {
+	value := func(v []byte) string {
+		if v == nil {
+			return "<nil>"
+		}
+		return string(v)
+	}
+	return fmt.Sprintf("%s:%s->%s@%d rollback=%v", c.Key, value(c.Old), value(c.New), c.Block, c.Rollback)
+}
+
+func checkChanges(t *testing.T, got []Change, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		descs := make([]string, len(got))
+		for i, c := range got {
+			descs[i] = describe(c)
+		}
+		t.Fatalf("got changes %q, want %q", descs, want)
+	}
+	for i := range want {
+		if d := describe(got[i]); d != want[i] {
+			t.Errorf("change %d = %q, want %q", i, d, want[i])
+		}
+	}
+}
+
+func TestSubscribeCommit(t *testing.T) {
+	s := NewMemoryStorage()
+	btc, cancelBTC := s.Subscribe("ftso:BTC")
+	defer cancelBTC()
+	all, cancelAll := s.Subscribe("")
+	defer cancelAll()
+
+	s.Set("ftso:ETH", []byte("1"))
+	s.Set("ftso:BTC:latest", []byte("1"))
+	s.Set("ftso:BTC", []byte("1"))
+	s.Commit(1)
+	checkChanges(t, received(btc), []string{
+		"ftso:BTC:<nil>->1@1 rollback=false",
+		"ftso:BTC:latest:<nil>->1@1 rollback=false",
+	})
+	checkChanges(t, received(all), []string{
+		"ftso:BTC:<nil>->1@1 rollback=false",
+		"ftso:BTC:latest:<nil>->1@1 rollback=false",
+		"ftso:ETH:<nil>->1@1 rollback=false",
+	})
+
+	// Rewriting the same value is no change; deletions report a nil New
+	s.Set("ftso:BTC", []byte("1"))
+	s.Set("ftso:BTC:latest", []byte("2"))
+	s.Delete("ftso:ETH")
+	s.Commit(2)
+	checkChanges(t, received(btc), []string{
+		"ftso:BTC:latest:1->2@2 rollback=false",
+	})
+	checkChanges(t, received(all), []string{
+		"ftso:BTC:latest:1->2@2 rollback=false",
+		"ftso:ETH:1-><nil>@2 rollback=false",
+	})
+
+	// An empty block reports nothing
+	s.Commit(3)
+	checkChanges(t, received(all), nil)
+}
+
+func TestSubscribeRollback(t *testing.T) {
+	s := NewMemoryStorage()
+	s.Set("a", []byte("1"))
+	s.Set("b", []byte("1"))
+	s.Commit(1)
+	s.Set("a", []byte("2"))
+	s.Delete("b")
+	s.Set("c", []byte("2"))
+	s.Commit(2)
+	s.Set("d", []byte("3"))
+	s.Commit(3)
+
+	ch, cancel := s.Subscribe("")
+	defer cancel()
+	if err := s.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+	checkChanges(t, received(ch), []string{
+		"a:2->1@1 rollback=true",
+		"b:<nil>->1@1 rollback=true",
+		"c:2-><nil>@1 rollback=true",
+		"d:3-><nil>@1 rollback=true",
+	})
+
+	// Rolling back to the head changes nothing
+	if err := s.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+	checkChanges(t, received(ch), nil)
+}
+
+func TestSubscribeRestore(t *testing.T) {
+	s := NewMemoryStorage()
+	s.Set("a", []byte("1"))
+	s.Commit(1)
+	snap := s.Snapshot()
+	s.Set("a", []byte("2"))
+	s.Set("b", []byte("2"))
+	s.Commit(2)
+
+	ch, cancel := s.Subscribe("")
+	defer cancel()
+	s.Restore(snap)
+	checkChanges(t, received(ch), []string{
+		"a:2->1@1 rollback=true",
+		"b:2-><nil>@1 rollback=true",
+	})
+}
+
+func TestSubscribeDropsWhenFull(t *testing.T) {
+	s := NewMemoryStorage()
+	ch, cancel := s.Subscribe("")
+	defer cancel()
+
+	// Commit must not block on a subscriber that does not read
+	for i := 0; i < changeBuffer+10; i++ {
+		s.Set(fmt.Sprintf("key:%05d", i), []byte("1"))
+	}
+	s.Commit(1)
+
+	got := received(ch)
+	if len(got) != changeBuffer {
+		t.Fatalf("got %d changes, want the first %d", len(got), changeBuffer)
+	}
+	if got[0].Key != "key:00000" || got[changeBuffer-1].Key != fmt.Sprintf("key:%05d", changeBuffer-1) {
+		t.Errorf("kept %s..%s, want the first keys in order", got[0].Key, got[changeBuffer-1].Key)
+	}
+
+	// Room frees up for later blocks
+	s.Set("key:00000", []byte("2"))
+	s.Commit(2)
+	checkChanges(t, received(ch), []string{"key:00000:1->2@2 rollback=false"})
+}
+
+func TestSubscribeCancel(t *testing.T) {
+	s := NewMemoryStorage()
+	ch, cancel := s.Subscribe("")
+	other, cancelOther := s.Subscribe("")
+	defer cancelOther()
+
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel still open after cancel")
+	}
+	cancel() // cancelling twice is harmless
+
+	s.Set("a", []byte("1"))
+	s.Commit(1)
+	checkChanges(t, received(other), []string{"a:<nil>->1@1 rollback=false"})
+
+	cancelOther()
+	if s.subs.active() {
+		t.Error("subscribers still active after every subscription was cancelled")
+	}
+}
@@ -0,0 +1,97 @@
+package state
+
+import "bytes"
+
+// ReadWriter is the key-value access shared by a Storage and a transaction
+type ReadWriter interface {
+	Get(key string) ([]byte, error)
+	Has(key string) bool
+	Set(key string, value []byte) error
+	Delete(key string) error
+}
+
+// Write is a single change made by a transaction (a nil Value deletes the key)
+type Write struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Tx is a transaction on a Storage. Its reads see its own writes; its writes are
+// buffered and applied together when the transaction function returns nil.
+type Tx struct {
+	read   func(key string) []byte
+	writes map[string][]byte
+	order  []string // keys in the order they were first written
+}
+
+// newTx creates a transaction reading the underlying state through read
+func newTx(read func(key string) []byte) *Tx {
+	return &Tx{read: read, writes: make(map[string][]byte)}
+}
+
+// Get retrieves the value of the key as seen by the transaction (nil if missing)
+func (tx *Tx) Get(key string) ([]byte, error) {
+	if value, written := tx.writes[key]; written {
+		return value, nil
+	}
+	return tx.read(key), nil
+}
+
+// Has checks if the key exists as seen by the transaction
+func (tx *Tx) Has(key string) bool {
+	value, _ := tx.Get(key)
+	return value != nil
+}
+
+// Set stores a value for the key when the transaction commits
+func (tx *Tx) Set(key string, value []byte) error {
+	if value == nil {
+		value = []byte{} // nil is reserved for deletions
+	}
+	tx.write(key, value)
+	return nil
+}
+
+// Delete removes the key when the transaction commits
+func (tx *Tx) Delete(key string) error {
+	tx.write(key, nil)
+	return nil
+}
+
+// write buffers a change
+func (tx *Tx) write(key string, value []byte) {
+	if _, written := tx.writes[key]; !written {
+		tx.order = append(tx.order, key)
+	}
+	tx.writes[key] = value
+}
+
+// Writes returns the buffered changes in the order the keys were first written
+func (tx *Tx) Writes() []Write {
+	writes := make([]Write, len(tx.order))
+	for i, key := range tx.order {
+		writes[i] = Write{Key: key, Value: tx.writes[key]}
+	}
+	return writes
+}
+
+// compareAndSwap runs a compare-and-swap as a transaction on s
+func compareAndSwap(s Storage, key string, old, new []byte) (bool, error) {
+	swapped := false
+	err := s.Update(func(tx *Tx) error {
+		current, err := tx.Get(key)
+		if err != nil {
+			return err
+		}
+		if (current == nil) != (old == nil) || !bytes.Equal(current, old) {
+			return nil
+		}
+
+		swapped = true
+		if new == nil {
+			return tx.Delete(key)
+		}
+		return tx.Set(key, new)
+	})
+	return swapped, err
+}
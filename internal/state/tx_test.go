@@ -0,0 +1,104 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	errAbort := errors.New("abort")
+	tests := []struct {
+		name    string
+		fn      func(tx *Tx) error
+		wantErr error
+		want    map[string][]byte // nil values are missing keys
+	}{
+		{"applies every write", func(tx *Tx) error {
+			tx.Set("a", []byte("new"))
+			tx.Set("c", []byte("3"))
+			return tx.Delete("b")
+		}, nil, map[string][]byte{"a": []byte("new"), "b": nil, "c": []byte("3")}},
+		{"rolls back on error", func(tx *Tx) error {
+			tx.Set("a", []byte("new"))
+			tx.Delete("b")
+			tx.Set("c", []byte("3"))
+			return errAbort
+		}, errAbort, map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": nil}},
+		{"reads its own writes", func(tx *Tx) error {
+			tx.Set("a", []byte("new"))
+			tx.Delete("b")
+			if got, _ := tx.Get("a"); string(got) != "new" || tx.Has("b") {
+				return errAbort
+			}
+			return nil
+		}, nil, map[string][]byte{"a": []byte("new"), "b": nil}},
+		{"last write wins", func(tx *Tx) error {
+			tx.Delete("a")
+			tx.Set("a", []byte("again"))
+			tx.Set("c", []byte("3"))
+			return tx.Delete("c")
+		}, nil, map[string][]byte{"a": []byte("again"), "c": nil}},
+		{"empty value is not a deletion", func(tx *Tx) error {
+			return tx.Set("a", nil)
+		}, nil, map[string][]byte{"a": {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStorage()
+			s.Set("a", []byte("1"))
+			s.Set("b", []byte("2"))
+			s.Commit(1)
+
+			if err := s.Update(tt.fn); err != tt.wantErr {
+				t.Fatalf("Update() error = %v, want %v", err, tt.wantErr)
+			}
+			for key, want := range tt.want {
+				got, _ := s.Get(key)
+				if (got == nil) != (want == nil) || !bytes.Equal(got, want) {
+					t.Errorf("Get(%s) = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new []byte
+		want     bool
+		value    []byte // value of the key afterwards (nil if missing)
+	}{
+		{"matching value", []byte("1"), []byte("2"), true, []byte("2")},
+		{"conflicting value", []byte("0"), []byte("2"), false, []byte("1")},
+		{"expects missing key", nil, []byte("2"), false, []byte("1")},
+		{"expects empty value", []byte{}, []byte("2"), false, []byte("1")},
+		{"deletes", []byte("1"), nil, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStorage()
+			s.Set("key", []byte("1"))
+			s.Commit(1)
+
+			swapped, err := s.CompareAndSwap("key", tt.old, tt.new)
+			if err != nil || swapped != tt.want {
+				t.Fatalf("CompareAndSwap() = %v, %v; want %v", swapped, err, tt.want)
+			}
+			got, _ := s.Get("key")
+			if (got == nil) != (tt.value == nil) || !bytes.Equal(got, tt.value) {
+				t.Errorf("value = %q, want %q", got, tt.value)
+			}
+		})
+	}
+
+	// Creating a missing key only succeeds once
+	s := NewMemoryStorage()
+	if swapped, _ := s.CompareAndSwap("lock", nil, []byte("a")); !swapped {
+		t.Errorf("first CompareAndSwap() on a missing key did not swap")
+	}
+	if swapped, _ := s.CompareAndSwap("lock", nil, []byte("b")); swapped {
+		t.Errorf("second CompareAndSwap() on a missing key swapped")
+	}
+}